@@ -96,7 +96,7 @@ func (c *Context) RouteArgs() []string {
 
 // RouteName实现了router.Context接口，所以我们能用Router
 func (c *Context) UI() ui.Ui {
-	return c.UI()
+	return c.Ui
 }
 
 type CompileResult struct {
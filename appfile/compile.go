@@ -2,6 +2,7 @@ package appfile
 
 import (
 	_ "bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -37,12 +38,75 @@ type Compiled struct {
 	// Graph 是DAG(Directed Acyclic Graph) 有向无环图，包括所以依赖。
 	// This is already verified to have no cycles. Each vertex is a *CompiledGraphVertex.
 	Graph *dag.AcyclicGraph
+
+	// Hash 是编译时root Appfile文件内容的SHA256，用来检查
+	// 编译的结果是否过期
+	Hash string
 }
 
 func (c *Compiled) Validate() error {
 	return nil
 }
 
+// MarshalJSON 把编译的Appfile序列化为JSON。因为dag.AcyclicGraph
+// 不能直接序列化，这里把图拆分成顶点和边分别保存
+func (c *Compiled) MarshalJSON() ([]byte, error) {
+	raw := &compiledJSON{File: c.File, Hash: c.Hash}
+	if c.Graph != nil {
+		for _, v := range c.Graph.Vertices() {
+			raw.Vertices = append(raw.Vertices, v.(*CompiledGraphVertex))
+		}
+		for _, e := range c.Graph.Edges() {
+			raw.Edges = append(raw.Edges, map[string]string{
+				dag.VertexName(e.Source()): dag.VertexName(e.Target()),
+			})
+		}
+	}
+
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON 从MarshalJSON的结果重建编译的Appfile和依赖图
+func (c *Compiled) UnmarshalJSON(data []byte) error {
+	var raw compiledJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	c.File = raw.File
+	c.Hash = raw.Hash
+	c.Graph = new(dag.AcyclicGraph)
+	vertices := make(map[string]*CompiledGraphVertex, len(raw.Vertices))
+	for _, v := range raw.Vertices {
+		vertices[v.Name()] = v
+		c.Graph.Add(v)
+	}
+	for _, e := range raw.Edges {
+		for source, target := range e {
+			sourceV, ok := vertices[source]
+			if !ok {
+				return fmt.Errorf("编译的Appfile中没有找到顶点: %s", source)
+			}
+			targetV, ok := vertices[target]
+			if !ok {
+				return fmt.Errorf("编译的Appfile中没有找到顶点: %s", target)
+			}
+
+			c.Graph.Connect(dag.BasicEdge(sourceV, targetV))
+		}
+	}
+
+	return nil
+}
+
+// compiledJSON 是Compiled在磁盘上的JSON格式
+type compiledJSON struct {
+	File     *File
+	Vertices []*CompiledGraphVertex
+	Edges    []map[string]string
+	Hash     string
+}
+
 // CompileGraphVertex is the type of the vertex within the Graph of Compiled.
 type CompiledGraphVertex struct {
 	// File 是原始Appfile
@@ -57,6 +121,11 @@ type CompiledGraphVertex struct {
 	NameValue string
 }
 
+// Name 实现了dag.NamedVertex
+func (v *CompiledGraphVertex) Name() string {
+	return v.NameValue
+}
+
 // CompileOpts 是编译选项
 type CompileOpts struct {
 	// Dir是所有编译数据存放目录
@@ -69,6 +138,14 @@ type CompileOpts struct {
 	// Callbak 是在编译期间接收事件通知的选项。参数CompileEvent需要
 	// 用Type switch决定
 	Callback func(CompileEvent)
+
+	// Flavors 返回一种infrastructure类型支持的flavor。设置了的时候
+	// 编译会拒绝Appfile中未知的flavor，参看File.ValidateFlavors
+	Flavors func(infraType string) []string
+
+	// Variables 返回一种infrastructure类型的flavor声明的变量。设置了
+	// 的时候编译会拒绝Appfile中未声明的变量，参看File.ValidateVariables
+	Variables func(infraType, flavor string) []string
 }
 
 // CompileEvent 是Callback可能接收的事件
@@ -101,6 +178,13 @@ func Compile(f *File, opts *CompileOpts) (*Compiled, error) {
 	// 开始构建编译的Appfile
 	compiled := &Compiled{File: f, Graph: new(dag.AcyclicGraph)}
 
+	// 记录Appfile的hash，以便之后检查编译是否过期
+	hash, err := f.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("计算Appfile hash错误：%s", err)
+	}
+	compiled.Hash = hash
+
 	// 检查是否有ID.如果没有那么需要写入一个
 	// 前提是文件有路径
 	if f.Path != "" {
@@ -139,6 +223,16 @@ func Compile(f *File, opts *CompileOpts) (*Compiled, error) {
 	if err := f.Validate(); err != nil {
 		return nil, err
 	}
+	if opts.Flavors != nil {
+		if err := f.ValidateFlavors(opts.Flavors); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Variables != nil {
+		if err := f.ValidateVariables(opts.Variables); err != nil {
+			return nil, err
+		}
+	}
 
 	// 在Appfile加入root定点
 	vertex := &CompiledGraphVertex{File: f, NameValue: f.Application.Name}
@@ -186,7 +280,37 @@ func compileVersion(dir string) error {
 }
 
 func compileWrite(dir string, compiled *Compiled) error {
-	return nil
+	// 格式化JSON数据，以便容易检查
+	data, err := json.MarshalIndent(compiled, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(dir, CompileFilename))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
+
+// LoadCompiled 从给定目录装载之前编译的Appfile。目录应该是
+// CompileOpts.Dir，如果Appfile没有编译过将返回错误
+func LoadCompiled(dir string) (*Compiled, error) {
+	f, err := os.Open(filepath.Join(dir, CompileFilename))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c Compiled
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
 }
 
 type compileImportOpts struct {
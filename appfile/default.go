@@ -1,7 +1,7 @@
 package appfile
 
 import (
-	"fmt"
+	"log"
 	"path/filepath"
 
 	"github.com/kuuyee/otto-learn/appfile/detect"
@@ -12,7 +12,7 @@ import (
 // 作为决定applicaiton的名字，路径必须是绝对路径
 func Default(dir string, det *detect.Config) (*File, error) {
 	appName := filepath.Base(dir)
-	fmt.Printf("[KuuYee]====> appName:", appName)
+	log.Printf("[DEBUG] default Appfile for app: %s", appName)
 	appType, err := detect.App(dir, det)
 	if err != nil {
 		return nil, err
@@ -1,6 +1,7 @@
 package appfile
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -59,6 +60,20 @@ type Dependency struct {
 type Project struct {
 	Name           string
 	Infrastructure string
+
+	// Directory 选择保存这个项目数据的目录后端，覆盖全局配置中
+	// 的设置。为nil时使用全局配置
+	Directory *Directory
+}
+
+// Directory 是Project中目录后端的设置
+type Directory struct {
+	// Backend 是后端的类型: "bolt"、"file" 或 "http"
+	Backend string
+
+	// Path 是后端保存数据的目录。相对路径相对于Appfile所在的目录，
+	// 所以可以指向和代码一起共享的目录。http后端的Path是服务端的地址
+	Path string
 }
 
 // Infrastructure是定义Infrastructure的结构，App运行在其上
@@ -67,6 +82,16 @@ type Infrastructure struct {
 	Type   string
 	Flavor string
 
+	// TerraformVersion 固定这个infrastructure使用的Terraform版本，
+	// 优先于全局配置。这样团队中每个人都使用同样的版本。在Appfile中
+	// 是 terraform_version
+	TerraformVersion string `mapstructure:"terraform_version"`
+
+	// Variables 是传递给Terraform的变量，比如region、实例类型和CIDR。
+	// 变量必须是flavor声明了的，值优先于环境变量和infrastructure的默认值。
+	// 在Appfile中是infrastructure里的 variables 块
+	Variables map[string]string
+
 	Foundations []*Foundation
 }
 
@@ -123,6 +148,19 @@ func (f *File) Merge(other *File) error {
 		if len(i.Foundations) == 0 {
 			i.Foundations = old.Foundations
 		}
+		if i.TerraformVersion == "" {
+			i.TerraformVersion = old.TerraformVersion
+		}
+		if len(old.Variables) > 0 {
+			vars := make(map[string]string)
+			for k, v := range old.Variables {
+				vars[k] = v
+			}
+			for k, v := range i.Variables {
+				vars[k] = v
+			}
+			i.Variables = vars
+		}
 
 		f.Infrastructure[idx] = i
 	}
@@ -145,6 +183,25 @@ func (app *Application) Merge(other *Application) {
 	}
 }
 
+// Hash 返回Appfile文件内容的SHA256。如果没有Appfile文件(比如
+// 使用发现的默认配置)，返回空字符串
+func (f *File) Hash() (string, error) {
+	if f.Path == "" {
+		return "", nil
+	}
+
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
 // hasID 检查是否有ID文件，如果文件系统错误则直接返回
 func (f *File) hasID() (bool, error) {
 	path := filepath.Join(filepath.Dir(f.Path), IDFile)
@@ -1,13 +1,55 @@
 package appfile
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+
+	"github.com/hashicorp/hcl"
+	hclobj "github.com/hashicorp/hcl/hcl"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/mitchellh/mapstructure"
 )
 
+// Parse 解析Appfile
+//
+// 由于当前HCL的限制，Appfile的内容在解析前会先拷贝到内存
 func Parse(r io.Reader) (*File, error) {
-	return nil, nil
+	// 在使用HCL进行解析之前，首先拷贝文件内容到内存缓冲
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return nil, err
+	}
+
+	obj, err := hcl.Parse(buf.String())
+	if err != nil {
+		return nil, i18n.Errorf("appfile.parse_err", err)
+	}
+	buf.Reset()
+
+	var result File
+
+	// 按顺序解析每种块
+	for _, p := range []struct {
+		Name  string
+		Parse func(*File, *hclobj.Object) error
+	}{
+		{"import", parseImport},
+		{"application", parseApplication},
+		{"project", parseProject},
+		{"infrastructure", parseInfra},
+		{"customization", parseCustomization},
+	} {
+		if o := obj.Get(p.Name, false); o != nil {
+			if err := p.Parse(&result, o); err != nil {
+				return nil, i18n.Errorf("appfile.block_err", p.Name, err)
+			}
+		}
+	}
+
+	return &result, nil
 }
 
 // ParseFile 解析Appfile
@@ -32,3 +74,227 @@ func ParseFile(path string) (*File, error) {
 	}
 	return result, err
 }
+
+// parseImport 解析 import "source" {} 块
+func parseImport(result *File, obj *hclobj.Object) error {
+	for _, o := range parseLabeled(obj) {
+		result.Imports = append(result.Imports, &Import{Source: o.Key})
+	}
+
+	return nil
+}
+
+// parseApplication 解析 application 块，只能有一个
+func parseApplication(result *File, obj *hclobj.Object) error {
+	m, err := parseSingle("application", obj)
+	if err != nil {
+		return err
+	}
+
+	var app Application
+	if err := mapstructure.WeakDecode(m, &app); err != nil {
+		return err
+	}
+
+	result.Application = &app
+	return nil
+}
+
+// parseProject 解析 project 块，只能有一个。directory 是project中
+// 的一个块，选择项目的目录后端
+func parseProject(result *File, obj *hclobj.Object) error {
+	m, err := parseSingle("project", obj)
+	if err != nil {
+		return err
+	}
+
+	var project Project
+	if raw, ok := m["directory"]; ok {
+		delete(m, "directory")
+
+		dm, err := decodeBlock("directory", raw)
+		if err != nil {
+			return err
+		}
+
+		project.Directory = new(Directory)
+		if err := mapstructure.WeakDecode(dm, project.Directory); err != nil {
+			return err
+		}
+	}
+	if err := mapstructure.WeakDecode(m, &project); err != nil {
+		return err
+	}
+
+	result.Project = &project
+	return nil
+}
+
+// parseInfra 解析 infrastructure "name" {} 块。没有设置type时使用
+// 名字作为类型
+func parseInfra(result *File, obj *hclobj.Object) error {
+	seen := make(map[string]struct{})
+	for _, o := range parseLabeled(obj) {
+		if _, ok := seen[o.Key]; ok {
+			return i18n.Errorf("appfile.duplicate", "infrastructure", o.Key)
+		}
+		seen[o.Key] = struct{}{}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o); err != nil {
+			return err
+		}
+
+		// 变量和foundation单独解析，剩下的是简单的字段
+		var infra Infrastructure
+		if raw, ok := m["variables"]; ok {
+			delete(m, "variables")
+
+			vars, err := parseVariables(raw)
+			if err != nil {
+				return fmt.Errorf("%s: %s", o.Key, err)
+			}
+			infra.Variables = vars
+		}
+		delete(m, "foundation")
+		if err := mapstructure.WeakDecode(m, &infra); err != nil {
+			return fmt.Errorf("%s: %s", o.Key, err)
+		}
+
+		infra.Name = o.Key
+		if infra.Type == "" {
+			infra.Type = infra.Name
+		}
+
+		if o2 := o.Get("foundation", false); o2 != nil {
+			if err := parseFoundations(&infra, o2); err != nil {
+				return fmt.Errorf("%s: %s", o.Key, err)
+			}
+		}
+
+		result.Infrastructure = append(result.Infrastructure, &infra)
+	}
+
+	return nil
+}
+
+// parseFoundations 解析infrastructure中的 foundation "name" {} 块，
+// 块的内容是foundation的配置
+func parseFoundations(infra *Infrastructure, obj *hclobj.Object) error {
+	for _, o := range parseLabeled(obj) {
+		var config map[string]interface{}
+		if err := hcl.DecodeObject(&config, o); err != nil {
+			return err
+		}
+
+		infra.Foundations = append(infra.Foundations, &Foundation{
+			Name:   o.Key,
+			Config: config,
+		})
+	}
+
+	return nil
+}
+
+// parseCustomization 解析 customization "type" {} 块，只能有一个
+func parseCustomization(result *File, obj *hclobj.Object) error {
+	objects := parseLabeled(obj)
+	if len(objects) > 1 {
+		return i18n.Errorf("appfile.block_once", "customization")
+	}
+
+	for _, o := range objects {
+		var config map[string]interface{}
+		if err := hcl.DecodeObject(&config, o); err != nil {
+			return err
+		}
+
+		result.Customization = &Customization{
+			Type:   o.Key,
+			Config: config,
+		}
+	}
+
+	return nil
+}
+
+// parseVariables 把infrastructure中的 variables 块转换成Terraform的
+// 变量。值可以是字符串、数字或者布尔值，都按字符串传给Terraform
+func parseVariables(raw interface{}) (map[string]string, error) {
+	m, err := decodeBlock("variables", raw)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		switch v.(type) {
+		case string, int, int64, float64, bool:
+			result[k] = fmt.Sprintf("%v", v)
+		default:
+			return nil, i18n.Errorf("appfile.variable_type_err", k)
+		}
+	}
+
+	return result, nil
+}
+
+// parseLabeled 返回所有带名字的块，例如 infrastructure "aws" {}。
+// 同样的块可以写多次
+func parseLabeled(obj *hclobj.Object) []*hclobj.Object {
+	var result []*hclobj.Object
+	for _, o1 := range obj.Elem(false) {
+		for _, o2 := range o1.Elem(true) {
+			result = append(result, o2)
+		}
+	}
+
+	return result
+}
+
+// parseSingle 解码一个只能写一次的块
+func parseSingle(name string, obj *hclobj.Object) (map[string]interface{}, error) {
+	if obj.Len() > 1 {
+		return nil, i18n.Errorf("appfile.block_once", name)
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, obj); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// decodeBlock 把HCL解码出来的块合并成一个map。HCL把块解码成map的
+// 列表，同一个块写了多次时列表中有多个map
+func decodeBlock(name string, raw interface{}) (map[string]interface{}, error) {
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case []map[string]interface{}:
+		result := make(map[string]interface{})
+		for _, m := range v {
+			for k, x := range m {
+				result[k] = x
+			}
+		}
+
+		return result, nil
+	case []interface{}:
+		result := make(map[string]interface{})
+		for _, elem := range v {
+			m, ok := elem.(map[string]interface{})
+			if !ok {
+				return nil, i18n.Errorf("appfile.block_type_err", name)
+			}
+			for k, x := range m {
+				result[k] = x
+			}
+		}
+
+		return result, nil
+	default:
+		return nil, i18n.Errorf("appfile.block_type_err", name)
+	}
+}
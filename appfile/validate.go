@@ -1,7 +1,11 @@
 package appfile
 
 import (
-	_ "fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/kuuyee/otto-learn/helper/i18n"
 )
 
 // Validate validates the Appfile
@@ -9,3 +13,84 @@ func (f *File) Validate() error {
 	var result error
 	return result
 }
+
+// ValidateFlavors 验证Appfile中每个infrastructure的flavor是否被支持
+//
+// flavors返回一种infrastructure类型支持的flavor。返回nil表示不知道
+// 这种类型支持哪些flavor，这时不做检查
+func (f *File) ValidateFlavors(flavors func(infraType string) []string) error {
+	var result error
+	for _, infra := range f.Infrastructure {
+		if err := infra.ValidateFlavor(flavors(infra.Type)); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result
+}
+
+// ValidateFlavor 验证infrastructure的flavor是不是valid中的一个。valid
+// 为空表示不知道支持哪些flavor，这时不做检查
+func (i *Infrastructure) ValidateFlavor(valid []string) error {
+	if len(valid) == 0 {
+		return nil
+	}
+
+	for _, v := range valid {
+		if v == i.Flavor {
+			return nil
+		}
+	}
+
+	return i18n.Errorf("core.infra.flavor_err",
+		i.Flavor, i.Type, strings.Join(valid, ", "))
+}
+
+// ValidateVariables 验证Appfile中每个infrastructure设置的变量是否是
+// 它的flavor声明了的，这样写错的变量名不会被Terraform悄悄忽略
+//
+// variables返回一种infrastructure类型的flavor声明的变量。返回nil表示
+// 不知道声明了哪些变量，这时不做检查
+func (f *File) ValidateVariables(variables func(infraType, flavor string) []string) error {
+	var result error
+	for _, infra := range f.Infrastructure {
+		if len(infra.Variables) == 0 {
+			continue
+		}
+
+		if err := infra.ValidateVariables(variables(infra.Type, infra.Flavor)); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result
+}
+
+// ValidateVariables 验证infrastructure设置的变量都在declared中。declared
+// 为空表示不知道flavor声明了哪些变量，这时不做检查
+func (i *Infrastructure) ValidateVariables(declared []string) error {
+	if len(declared) == 0 {
+		return nil
+	}
+
+	valid := make(map[string]struct{}, len(declared))
+	for _, v := range declared {
+		valid[v] = struct{}{}
+	}
+
+	names := make([]string, 0, len(i.Variables))
+	for k := range i.Variables {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var result error
+	for _, k := range names {
+		if _, ok := valid[k]; !ok {
+			result = multierror.Append(result, i18n.Errorf(
+				"core.infra.variable_err", k, i.Flavor, strings.Join(declared, ", ")))
+		}
+	}
+
+	return result
+}
@@ -30,6 +30,7 @@ func Infra() (infrastructure.Infrastructure, error) {
 		Variables: map[string]string{
 			"aws_region": "us-east-1",
 		},
+		Sensitive: []string{"aws_access_key", "aws_secret_key"},
 	}, nil
 }
 
@@ -46,6 +47,7 @@ func creds(ctx *infrastructure.Context) (map[string]string, error) {
 			Query:       "AWS Secret Key",
 			Description: "AWS secret key used for API calls",
 			EnvVars:     []string{"AWS_SECRET_KEY_ID"},
+			Hide:        true,
 		},
 		&ui.InputOpts{
 			Id:          "ssh_public_key_path",
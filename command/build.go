@@ -0,0 +1,69 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// BuildCommand 是一个构建命令，为应用构建
+// 可部署的产物
+type BuildCommand struct {
+	Meta
+}
+
+func (c *BuildCommand) Run(args []string) int {
+	fs := c.FlagSet("build", FlagSetNone)
+	fs.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	// 装载编译的Appfile
+	app, err := c.Appfile()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	// 取得一个Core
+	core, err := c.Core(app)
+	if err != nil {
+		c.Ui.Error(i18n.T("command.core_load_err", err))
+		return 1
+	}
+
+	// 构建
+	if err := core.Build(); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func (c *BuildCommand) Synopsis() string {
+	return "Build the deployable artifact for the app"
+}
+
+func (c *BuildCommand) Help() string {
+	helpText := `
+Usage: otto build [options]
+
+  Builds the deployable artifact for the app.
+
+  This command will build the deployable artifact for the app, such as
+  an AMI. The artifact is built for the infrastructure configured in the
+  Appfile, so credentials for that infrastructure will be requested and
+  verified before the build starts.
+
+  The Appfile must be compiled with 'otto compile' before this command
+  can be run.
+
+Exit codes:
+
+  0  The artifact was built successfully
+  1  An error occurred loading the Appfile, the credentials or building
+`
+	return strings.TrimSpace(helpText)
+}
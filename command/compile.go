@@ -1,17 +1,17 @@
 package command
 
 import (
-	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
 
 	//"github.com/hashicorp/otto/appfile"
 	"github.com/kuuyee/otto-learn/appfile"
 	//"github.com/hashicorp/otto/appfile/detect"
 	"github.com/hashicorp/otto/ui"
 	"github.com/kuuyee/otto-learn/appfile/detect"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/mitchellh/go-homedir"
 )
 
 // CompileCommand是一个编译命令，要来把
@@ -19,6 +19,10 @@ import (
 type CompileCommand struct {
 	Meta
 	Detectors []*detect.Detector //在main.commands.go中初始化
+
+	// DetectorDirs 是全局配置中额外的detector配置目录，优先于
+	// 内置的Detectors
+	DetectorDirs []string
 }
 
 func (c *CompileCommand) Run(args []string) int {
@@ -33,25 +37,18 @@ func (c *CompileCommand) Run(args []string) int {
 
 	// Load a UI
 	ui := c.OttoUi()
-	ui.Header("装载 Appfile...")
+	ui.Header(i18n.T("compile.loading"))
 
-	fmt.Printf("[KuuYee]====> flagAppfile: %+v\n", flagAppfile)
 	app, appPath, err := loadAppfile(flagAppfile)
 	if err != nil {
 		c.Ui.Error(err.Error())
 		return 1
 	}
-	fmt.Printf("[KuuYee]====> appPath: %+v\n", appPath)
 
 	// 如果没有Appfile，告诉用户发生了什么
 	if app == nil {
-		ui.Header("没有发现Appfile! Detecting project information...")
-		ui.Message(fmt.Sprintf(
-			"No Appfile was found. If there is no Appfile, Otto will do its best\n" +
-				"to detect the type of application this is and set reasonable defaults.\n" +
-				"This is a good way to get started with Otto, but over time we recommend\n" +
-				"writing a real Appfile since this will allow more complex customizations,\n" +
-				"the ability to reference dependencies, versioning, and more."))
+		ui.Header(i18n.T("compile.no_appfile_head"))
+		ui.Message(i18n.T("compile.no_appfile"))
 	}
 
 	// 解析
@@ -60,9 +57,7 @@ func (c *CompileCommand) Run(args []string) int {
 		c.Ui.Error(err.Error())
 		return 1
 	}
-	fmt.Printf("[KuuYee]====> dataDir: %+v\n", dataDir)
 	detectorDir := filepath.Join(dataDir, DefaultLocalDataDetectorDir)
-	fmt.Printf("[KuuYee]====> detectorDir: %+v\n", detectorDir)
 	log.Printf("[DEBUG] loading detectors from: %s", detectorDir)
 	detectConfig, err := detect.ParseDir(detectorDir) //如果没有找到定制配置，则从这里开始分析
 	if err != nil {
@@ -71,7 +66,26 @@ func (c *CompileCommand) Run(args []string) int {
 	}
 	if detectConfig == nil {
 		detectConfig = &detect.Config{}
-		fmt.Printf("[KuuYee]====> detectConfig: %+v\n", detectConfig)
+	}
+	for _, dir := range c.DetectorDirs {
+		dir, err := homedir.Expand(dir)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+
+		log.Printf("[DEBUG] loading detectors from: %s", dir)
+		dirConfig, err := detect.ParseDir(dir)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+		if dirConfig != nil {
+			if err := detectConfig.Merge(dirConfig); err != nil {
+				c.Ui.Error(i18n.T("compile.detectors_err", dir, err))
+				return 1
+			}
+		}
 	}
 	err = detectConfig.Merge(&detect.Config{Detectors: c.Detectors})
 	if err != nil {
@@ -80,54 +94,52 @@ func (c *CompileCommand) Run(args []string) int {
 	}
 	//打印能够发现的类型
 	for i, v := range detectConfig.Detectors {
-		fmt.Printf("[KuuYee]====> detectConfig %d : %+v\n", i, v)
+		log.Printf("[DEBUG] detector %d: %+v", i, v)
 	}
 
 	// 装载默认Appfile，我们可以合并任何的默认
 	// appfile到已经装载的Appfile
 	appDef, err := appfile.Default(appPath, detectConfig)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf(
-			"装载Appfile报错：%s", err))
+		c.Ui.Error(i18n.T("compile.load_err", err))
 		return 1
 	}
-	fmt.Printf("[KuuYee]====> appDef: %+v\n", appDef)
+	log.Printf("[DEBUG] default Appfile: %+v", appDef)
 
 	// 如果没有加载到appfile，那么认为没有可用的应用
 	if app == nil && appDef.Application.Type == "" {
-		c.Ui.Error(strings.TrimSpace(errCantDetectType))
+		c.Ui.Error(i18n.T("compile.cant_detect"))
 		return 1
 	}
 
 	// 合并应用
 	if app != nil {
 		if err := appDef.Merge(app); err != nil {
-			c.Ui.Error(fmt.Sprintf(
-				"装载Appfile报错： %s", err))
+			c.Ui.Error(i18n.T("compile.load_err", err))
 			return 1
 		}
 	}
 	app = appDef
-	fmt.Printf("[KuuYee]====> app: %+v\n", app)
+	log.Printf("[DEBUG] Appfile: %+v", app)
 
 	// 编译Appfile
-	ui.Header("获取所有的Appfile依赖...")
+	ui.Header(i18n.T("compile.deps"))
 	capp, err := appfile.Compile(app, &appfile.CompileOpts{
-		Dir:      filepath.Join(filepath.Dir(app.Path), DefaultOutputDir, DefaultOutputDirCompiledAppfile),
-		Detect:   detectConfig,
-		Callback: c.compileCallback(ui),
+		Dir:       filepath.Join(filepath.Dir(app.Path), DefaultOutputDir, DefaultOutputDirCompiledAppfile),
+		Detect:    detectConfig,
+		Callback:  c.compileCallback(ui),
+		Flavors:   c.CoreConfig.Flavors,
+		Variables: c.CoreConfig.FlavorVariables,
 	})
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf(
-			"编译Appfile报错：%s", err))
+		c.Ui.Error(i18n.T("compile.appfile_err", err))
 		return 1
 	}
 
 	// 取得一个Core
 	core, err := c.Core(capp)
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf(
-			"装载Core报错：%s", err))
+		c.Ui.Error(i18n.T("command.core_load_err", err))
 		return 1
 	}
 
@@ -135,34 +147,27 @@ func (c *CompileCommand) Run(args []string) int {
 	infra := app.ActiveInfrastructure()
 
 	// 编译之前，告诉用户what is going on
-	ui.Header("编译...")
-	ui.Message(fmt.Sprintf(
-		"Application:   %s (%s)",
+	ui.Header(i18n.T("compile.header"))
+	ui.Message(i18n.T(
+		"compile.application",
 		app.Application.Name,
 		app.Application.Type))
-	ui.Message(fmt.Sprintf("项目：    %s", app.Project.Name))
-	ui.Message(fmt.Sprintf(
-		"Infrastructure: %s (%s)",
+	ui.Message(i18n.T("compile.project", app.Project.Name))
+	ui.Message(i18n.T(
+		"compile.infrastructure",
 		infra.Type,
 		infra.Flavor))
 	ui.Message("")
 
 	// 开始编译
 	if err := core.Compile(); err != nil {
-		c.Ui.Error(fmt.Sprintf(
-			"编译报错: %s", err))
+		c.Ui.Error(i18n.T("compile.err", err))
 		return 1
 	}
 
 	// Success!
-	ui.Header("[green]编译成功!")
-	ui.Message(fmt.Sprintf(
-		"[green]This means that Otto is now ready to start a development environment,\n" +
-			"deploy this application, build the supporting infrastructure, and\n" +
-			"more. See the help for more information.\n\n" +
-			"Supporting files to enable Otto to manage your application from\n" +
-			"development to deployment have been placed in the output directory.\n" +
-			"These files can be manually inspected to determine what Otto will do."))
+	ui.Header(i18n.T("compile.success_header"))
+	ui.Message(i18n.T("compile.success"))
 
 	return 0
 }
@@ -209,7 +214,7 @@ func findAppfile(flag string) (string, error) {
 	if flag != "" {
 		fi, err := os.Stat(flag)
 		if err != nil {
-			return "", fmt.Errorf("装载Appfile报错：%s", err)
+			return "", i18n.Errorf("compile.load_err", err)
 		}
 
 		if fi.IsDir() {
@@ -222,7 +227,7 @@ func findAppfile(flag string) (string, error) {
 	// 检索当前目录
 	wd, err := os.Getwd()
 	if err != nil {
-		return "", fmt.Errorf("加载当前目录报错: %s", err)
+		return "", i18n.Errorf("compile.wd_err", err)
 	}
 
 	return findAppfileInDir(wd), nil
@@ -241,22 +246,3 @@ func findAppfileInDir(path string) string {
 	}
 	return ""
 }
-
-const errCantDetectType = `
-No Appfile is present and Otto couldn't detect the project type automatically.
-Otto does its best without an Appfile to detect what kind of project this is
-automatically, but sometimes this fails if the project is in a structure
-Otto doesn't recognize or its a project type that Otto doesn't yet support.
-
-Please create an Appfile and specify at a minimum the project name and type. Below
-is an example minimal Appfile specifying the "my-app" application name and "go"
-project type:
-
-    application {
-	name = "my-app"
-	type = "go"
-    }
-
-If you believe Otto should've been able to automatically detect your
-project type, then please open an issue with the Otto project.
-`
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kuuyee/otto-learn/appfile/detect"
+	"github.com/mitchellh/cli"
+	"github.com/mitchellh/go-homedir"
+)
+
+func TestCompileCommand_json(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 在没有Appfile的空目录中运行，数据目录也在临时目录中
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(wd)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	// 调试输出直接写到os.Stdout，所以UI和os.Stdout都写到同一个pipe
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	linesCh := make(chan []string, 1)
+	go func() {
+		var lines []string
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		linesCh <- lines
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	c := &CompileCommand{
+		Meta: Meta{Ui: &cli.BasicUi{Writer: w, ErrorWriter: w}},
+		Detectors: []*detect.Detector{
+			&detect.Detector{Type: "go", File: []string{"*.go"}},
+		},
+	}
+	code := c.Run([]string{"-json"})
+	os.Stdout = stdout
+	w.Close()
+	lines := <-linesCh
+
+	// 目录中没有应用，所以编译失败，但是每一行输出都要是JSON事件
+	if code == 0 {
+		t.Fatal("should fail")
+	}
+	if len(lines) == 0 {
+		t.Fatal("no output")
+	}
+	for i, line := range lines {
+		var e JSONEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %d is not JSON: %q", i, line)
+		}
+	}
+
+	var e JSONEvent
+	json.Unmarshal([]byte(lines[len(lines)-1]), &e)
+	if e.Type != JSONEventError {
+		t.Fatalf("bad: %#v", e)
+	}
+}
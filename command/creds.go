@@ -0,0 +1,81 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// CredsCommand 是一个认证命令，管理Otto加密保存的
+// infrastructure认证
+type CredsCommand struct {
+	Meta
+}
+
+func (c *CredsCommand) Run(args []string) int {
+	fs := c.FlagSet("creds", FlagSetNone)
+	fs.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	args = fs.Args()
+	if len(args) != 1 {
+		fs.Usage()
+		return 1
+	}
+
+	// 装载编译的Appfile
+	app, err := c.Appfile()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	// 取得一个Core
+	core, err := c.Core(app)
+	if err != nil {
+		c.Ui.Error(i18n.T("command.core_load_err", err))
+		return 1
+	}
+
+	switch args[0] {
+	case "clear":
+		err = core.ClearCreds()
+	case "rotate":
+		err = core.RotateCreds()
+	default:
+		c.Ui.Error(i18n.T("command.unknown_action", args[0], c.Help()))
+		return 1
+	}
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func (c *CredsCommand) Synopsis() string {
+	return "Manage the stored infrastructure credentials"
+}
+
+func (c *CredsCommand) Help() string {
+	helpText := `
+Usage: otto creds <clear|rotate>
+
+  Manages the infrastructure credentials Otto stores for the Appfile.
+
+  Otto encrypts infrastructure credentials with a password and stores them
+  in the global data directory, keyed by the infrastructure name. The
+  password is asked for once per run, or read from OTTO_CREDS_PASSWORD.
+
+Actions:
+
+  clear     Delete the stored credentials. Otto will ask for credentials
+            again the next time they are needed
+  rotate    Ask for new credentials and a new password, verify them and
+            replace the stored credentials
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,84 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// DeployCommand 是一个部署命令，把应用部署到
+// infrastructure上
+type DeployCommand struct {
+	Meta
+}
+
+func (c *DeployCommand) Run(args []string) int {
+	fs := c.FlagSet("deploy", FlagSetNone)
+	fs.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	// 第一个非flag参数是子动作，其余的参数原样传递给子动作
+	var action string
+	var actionArgs []string
+	if args := fs.Args(); len(args) > 0 {
+		action = args[0]
+		actionArgs = args[1:]
+	}
+
+	// 装载编译的Appfile
+	app, err := c.Appfile()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	// 取得一个Core
+	core, err := c.Core(app)
+	if err != nil {
+		c.Ui.Error(i18n.T("command.core_load_err", err))
+		return 1
+	}
+
+	// 部署
+	if err := core.Deploy(action, actionArgs); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func (c *DeployCommand) Synopsis() string {
+	return "Deploy the application"
+}
+
+func (c *DeployCommand) Help() string {
+	helpText := `
+Usage: otto deploy [action] [args...]
+
+  Deploys the application to the infrastructure.
+
+  This command deploys the artifact built with 'otto build' into the
+  infrastructure created with 'otto infra'. The infrastructure must be
+  fully built before this command will succeed.
+
+  The deploy state is recorded in the directory, so 'otto status' can
+  report whether the application is deployed.
+
+Actions:
+
+  (none)     Deploy the application
+  destroy    Destroy the deployed resources of the application
+  info       Display information about the deployed application
+  help       Show help for the deploy actions of this application
+
+Exit codes:
+
+  0  The action completed successfully
+  1  An error occurred loading the Appfile, the credentials or running
+     the action
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,82 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// DevCommand 是一个开发命令，管理应用的
+// 本地开发环境
+type DevCommand struct {
+	Meta
+}
+
+func (c *DevCommand) Run(args []string) int {
+	fs := c.FlagSet("dev", FlagSetNone)
+	fs.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	// 第一个非flag参数是子动作，其余的参数原样传递给子动作
+	var action string
+	var actionArgs []string
+	if args := fs.Args(); len(args) > 0 {
+		action = args[0]
+		actionArgs = args[1:]
+	}
+
+	// 装载编译的Appfile
+	app, err := c.Appfile()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	// 取得一个Core
+	core, err := c.Core(app)
+	if err != nil {
+		c.Ui.Error(i18n.T("command.core_load_err", err))
+		return 1
+	}
+
+	// 执行
+	if err := core.Dev(action, actionArgs); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func (c *DevCommand) Synopsis() string {
+	return "Start and manage a development environment"
+}
+
+func (c *DevCommand) Help() string {
+	helpText := `
+Usage: otto dev [action] [args...]
+
+  Builds and starts a local development environment for the application.
+
+  The development environment is built from the compiled Appfile, together
+  with the development pieces of all of its dependencies. Otto remembers
+  the state of the development environment, so 'otto status' can report it.
+
+Actions:
+
+  (none), up  Create and start the development environment
+  ssh         SSH into the running development environment
+  halt        Stop the development environment without destroying it
+  destroy     Destroy the development environment
+  address     Show the IP address of the development environment
+  help        Show help for the dev actions of this application
+
+Exit codes:
+
+  0  The action completed successfully
+  1  An error occurred loading the Appfile or running the action
+`
+	return strings.TrimSpace(helpText)
+}
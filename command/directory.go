@@ -0,0 +1,213 @@
+package command
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/kuuyee/otto-learn/otto"
+)
+
+// DefaultDirectoryAddress 是 `otto directory serve` 默认监听的地址
+const DefaultDirectoryAddress = "127.0.0.1:8300"
+
+// DirectoryCommand 是一个目录命令，管理Otto保存infrastructure、
+// 构建和部署数据的目录
+type DirectoryCommand struct {
+	Meta
+
+	// ShutdownCh 收到消息时停止serve
+	ShutdownCh <-chan struct{}
+}
+
+func (c *DirectoryCommand) Run(args []string) int {
+	fs := c.FlagSet("directory", FlagSetNone)
+	fs.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	args = fs.Args()
+	if len(args) < 1 {
+		fs.Usage()
+		return 1
+	}
+
+	switch args[0] {
+	case "export":
+		return c.export(args[1:])
+	case "import":
+		return c.importArchive(args[1:])
+	case "serve":
+		return c.serve(args[1:])
+	default:
+		c.Ui.Error(i18n.T("command.unknown_action", args[0], c.Help()))
+		return 1
+	}
+}
+
+// export 把目录中的所有数据导出到一个归档文件
+func (c *DirectoryCommand) export(args []string) int {
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	path := args[0]
+
+	backend, err := c.configDirectory()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		c.Ui.Error(i18n.T("command.directory_export_err", err))
+		return 1
+	}
+	manifest, err := directory.Export(f, backend)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// 不留下不完整的归档
+		os.Remove(path)
+		c.Ui.Error(i18n.T("command.directory_export_err", err))
+		return 1
+	}
+
+	c.Ui.Output(i18n.T("command.directory_exported", len(manifest.Entries), path))
+	return 0
+}
+
+// importArchive 把export导出的归档导入到目录中
+func (c *DirectoryCommand) importArchive(args []string) int {
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	path := args[0]
+
+	backend, err := c.configDirectory()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		c.Ui.Error(i18n.T("command.directory_import_err", err))
+		return 1
+	}
+	defer f.Close()
+
+	manifest, err := directory.Import(f, backend)
+	if err != nil {
+		c.Ui.Error(i18n.T("command.directory_import_err", err))
+		return 1
+	}
+
+	c.Ui.Output(i18n.T("command.directory_imported", len(manifest.Entries), path))
+	return 0
+}
+
+// serve 通过HTTP API共享本地的目录后端，HTTPBackend可以连接它
+func (c *DirectoryCommand) serve(args []string) int {
+	var address string
+	fs := c.FlagSet("directory serve", FlagSetNone)
+	fs.Usage = func() { c.Ui.Error(c.Help()) }
+	fs.StringVar(&address, "address", DefaultDirectoryAddress, "")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	backend, err := c.configDirectory()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	if _, ok := backend.(*directory.HTTPBackend); ok {
+		c.Ui.Error(i18n.T("command.directory_remote_err"))
+		return 1
+	}
+
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		c.Ui.Error(i18n.T("command.directory_listen_err", address, err))
+		return 1
+	}
+
+	c.Ui.Output(i18n.T("command.directory_serving", ln.Addr().String()))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- directory.Serve(ln, backend)
+	}()
+
+	select {
+	case err := <-errCh:
+		c.Ui.Error(i18n.T("command.directory_serve_err", err))
+		return 1
+	case <-c.ShutdownCh:
+		ln.Close()
+		c.Ui.Output(i18n.T("command.directory_stopped"))
+		return 0
+	}
+}
+
+// configDirectory 返回全局配置中的目录后端。目录命令不需要
+// Appfile，所以Appfile中的设置不起作用
+func (c *DirectoryCommand) configDirectory() (directory.Backend, error) {
+	dataDir, err := c.DataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Directory(&otto.CoreConfig{DataDir: dataDir})
+}
+
+func (c *DirectoryCommand) Synopsis() string {
+	return "Manage the Otto directory"
+}
+
+func (c *DirectoryCommand) Help() string {
+	helpText := `
+Usage: otto directory <action> [args...]
+
+  Manages the directory where Otto stores infrastructure, build and
+  deploy data, including the Terraform state.
+
+  The directory backend is chosen with "directory_backend" and
+  "directory_path" in the global config file, or with the
+  OTTO_DIRECTORY_BACKEND and OTTO_DIRECTORY_PATH environment variables.
+  The "http" backend uses a directory served by 'otto directory serve',
+  with "directory_path" set to its address.
+
+Actions:
+
+  export <path>    Write every infrastructure, build and deploy record
+                   and every blob (like the Terraform state) to a tar
+                   archive with a manifest and checksums
+  import <path>    Read an archive written by export into the directory.
+                   The whole archive is verified before anything is
+                   written. Existing records with the same key are
+                   replaced
+  serve            Share the local directory over HTTP so that a team
+                   can use it with the "http" backend
+
+  To move the directory to another backend, export it with the old
+  backend configured and import it with the new one, for example:
+
+    $ otto directory export otto.tar
+    $ export OTTO_DIRECTORY_BACKEND=file
+    $ export OTTO_DIRECTORY_PATH=~/.otto.d/directory-file
+    $ otto directory import otto.tar
+
+Serve options:
+
+  -address=addr    Address to listen on. Defaults to 127.0.0.1:8300
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,159 @@
+package command
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/mitchellh/cli"
+)
+
+func TestDirectoryCommand_implements(t *testing.T) {
+	var _ cli.Command = &DirectoryCommand{}
+}
+
+func TestDirectoryCommand_serve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 找一个空闲的端口
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	shutdownCh := make(chan struct{})
+	c := &DirectoryCommand{
+		Meta: Meta{
+			Ui:               new(cli.MockUi),
+			DirectoryBackend: "file",
+			DirectoryPath:    dir,
+		},
+		ShutdownCh: shutdownCh,
+	}
+
+	codeCh := make(chan int, 1)
+	go func() {
+		codeCh <- c.Run([]string{"serve", "-address", address})
+	}()
+
+	// 通过HTTPBackend写入，等待服务端启动
+	remote := &directory.HTTPBackend{Address: "http://" + address}
+	infra := &directory.Infra{
+		Lookup: directory.Lookup{Infra: "foo"},
+		State:  directory.InfraStateReady,
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err = remote.PutInfra(infra)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("err: %s", err)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// 数据保存在服务端的本地后端中
+	local := &directory.FileBackend{Dir: dir}
+	actual, err := local.GetInfra(&directory.Infra{Lookup: infra.Lookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual == nil || actual.ID != infra.ID || actual.State != infra.State {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	close(shutdownCh)
+	select {
+	case code := <-codeCh:
+		if code != 0 {
+			t.Fatalf("bad code: %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve didn't stop")
+	}
+}
+
+func TestDirectoryCommand_serveRemote(t *testing.T) {
+	c := &DirectoryCommand{
+		Meta: Meta{
+			Ui:               new(cli.MockUi),
+			DirectoryBackend: "http",
+			DirectoryPath:    "http://127.0.0.1:8300",
+		},
+	}
+
+	if code := c.Run([]string{"serve"}); code != 1 {
+		t.Fatalf("bad code: %d", code)
+	}
+}
+
+func TestDirectoryCommand_exportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 从bolt后端导出
+	boltDir := filepath.Join(dir, "bolt")
+	src := &directory.BoltBackend{Dir: boltDir}
+	infra := &directory.Infra{
+		Lookup: directory.Lookup{Infra: "foo"},
+		State:  directory.InfraStateReady,
+	}
+	if err := src.PutInfra(infra); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	archive := filepath.Join(dir, "otto.tar")
+	c := &DirectoryCommand{
+		Meta: Meta{
+			Ui:               new(cli.MockUi),
+			DirectoryBackend: "bolt",
+			DirectoryPath:    boltDir,
+		},
+	}
+	if code := c.Run([]string{"export", archive}); code != 0 {
+		t.Fatalf("bad code: %d", code)
+	}
+
+	// 导入到file后端
+	fileDir := filepath.Join(dir, "file")
+	c = &DirectoryCommand{
+		Meta: Meta{
+			Ui:               new(cli.MockUi),
+			DirectoryBackend: "file",
+			DirectoryPath:    fileDir,
+		},
+	}
+	if code := c.Run([]string{"import", archive}); code != 0 {
+		t.Fatalf("bad code: %d", code)
+	}
+
+	dst := &directory.FileBackend{Dir: fileDir}
+	actual, err := dst.GetInfra(&directory.Infra{Lookup: infra.Lookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual == nil || actual.ID != infra.ID {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// 不存在的归档
+	if code := c.Run([]string{"import", archive + ".nope"}); code != 1 {
+		t.Fatalf("bad code: %d", code)
+	}
+}
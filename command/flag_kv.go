@@ -0,0 +1,30 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// FlagKV 是一个flag.Value，解析"key=value"形式的参数。
+// flag可以指定多次，值保存在map中
+type FlagKV map[string]string
+
+func (v *FlagKV) String() string {
+	return ""
+}
+
+func (v *FlagKV) Set(raw string) error {
+	idx := strings.Index(raw, "=")
+	if idx == -1 {
+		return i18n.Errorf("command.flag_kv_err", raw)
+	}
+
+	if *v == nil {
+		*v = make(map[string]string)
+	}
+
+	key, value := raw[0:idx], raw[idx+1:]
+	(*v)[key] = value
+	return nil
+}
@@ -0,0 +1,118 @@
+package command
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// InfraCommand 是一个infrastructure命令，创建和管理
+// 应用运行需要的infrastructure
+type InfraCommand struct {
+	Meta
+}
+
+func (c *InfraCommand) Run(args []string) int {
+	var planPath string
+	fs := c.FlagSet("infra", FlagSetNone)
+	fs.Usage = func() { c.Ui.Error(c.Help()) }
+	fs.StringVar(&planPath, "plan", "", "")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	// 第一个非flag参数是子动作，其余的参数原样传递给子动作
+	var action string
+	var actionArgs []string
+	if args := fs.Args(); len(args) > 0 {
+		action = args[0]
+		actionArgs = args[1:]
+	}
+
+	// -plan 应用保存的plan，只能用于创建或更新。路径转换成绝对路径，
+	// 因为Terraform在编译的目录中执行
+	if planPath != "" {
+		if action != "" {
+			c.Ui.Error(c.Help())
+			return 1
+		}
+
+		path, err := filepath.Abs(planPath)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+		actionArgs = append([]string{"-plan=" + path}, actionArgs...)
+	}
+
+	// 装载编译的Appfile
+	app, err := c.Appfile()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	// 取得一个Core
+	core, err := c.Core(app)
+	if err != nil {
+		c.Ui.Error(i18n.T("command.core_load_err", err))
+		return 1
+	}
+
+	// 执行
+	if err := core.Infra(action, actionArgs); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func (c *InfraCommand) Synopsis() string {
+	return "Builds the infrastructure for the Appfile"
+}
+
+func (c *InfraCommand) Help() string {
+	helpText := `
+Usage: otto infra [options] [action] [args...]
+
+  Builds the infrastructure for the Appfile.
+
+  This will build the infrastructure configured in the Appfile, together
+  with the infrastructure of every foundation. If the infrastructure
+  already exists, it will be updated. Credentials for the infrastructure
+  will be requested and verified first.
+
+Actions:
+
+  (none)     Create or update the infrastructure
+  plan       Show the changes that would be made to the infrastructure
+             and save them to a plan file
+  check      Report resources that were changed outside of Otto and
+             mark the infrastructure as drifted in 'otto status'
+  destroy    Destroy the infrastructure. Every app deployed into it must
+             be destroyed with 'otto deploy destroy' first
+  flavors    List the flavors the infrastructure type supports
+  force-unlock
+             Remove a stale lock on the infrastructure state, left by
+             an Otto that was killed while changing the infrastructure
+  info       Display information about the infrastructure
+  state      List, show and restore previous versions of the
+             infrastructure state
+  help       Show help for the infra actions
+
+Options:
+
+  -plan=PATH    Apply exactly the changes in a plan saved by
+                'otto infra plan' instead of planning again. Only valid
+                without an action
+
+Exit codes:
+
+  0  The action completed successfully
+  1  An error occurred loading the Appfile, the credentials or running
+     the action
+`
+	return strings.TrimSpace(helpText)
+}
@@ -3,14 +3,15 @@ package command
 import (
 	"bufio"
 	"flag"
-	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 
-	"github.com/hashicorp/otto/directory"
 	"github.com/hashicorp/otto/ui"
 	"github.com/kuuyee/otto-learn/appfile"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
 	"github.com/kuuyee/otto-learn/otto"
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/go-homedir"
@@ -30,6 +31,10 @@ const (
 	DefaultOutputDirCompiledData    = "compiled"
 	DefaultOutputDirLocalData       = "data"
 
+	// EnvInput 是控制是否询问用户输入的环境变量。设置为false时
+	// 和-input=false一样
+	EnvInput = "OTTO_INPUT"
+
 	// DefaultDataDir 是数据目录的默认，
 	// 如果在Appfile没有指定的话
 	DafaultDataDir = "otto-data"
@@ -51,13 +56,52 @@ const (
 type Meta struct {
 	CoreConfig *otto.CoreConfig
 	Ui         cli.Ui
+
+	// CredsEnv 把infrastructure认证的InputOpts.Id映射到环境变量，
+	// 来自全局配置文件
+	CredsEnv map[string]string
+
+	// DirectoryBackend 和 DirectoryPath 选择保存Appfile数据的
+	// 目录后端，可以被Appfile中project的directory覆盖。为空时
+	// 使用数据目录中的bolt后端。http后端的DirectoryPath是服务端
+	// 的地址
+	DirectoryBackend string
+	DirectoryPath    string
+
+	// ShutdownCh 在用户中断Otto的时候收到消息，传给Core让正在执行的
+	// Terraform等命令正常停止
+	ShutdownCh <-chan struct{}
+
+	// 公共flag的值，参看FlagSet
+	flagSet   bool
+	flagInput bool
+	flagJSON  bool
+	flagVars  FlagKV
+}
+
+// Appfile装载编译过的Appfile。如果Appfile还没有编译，返回错误
+func (m *Meta) Appfile() (*appfile.Compiled, error) {
+	// 找到root目录
+	startDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	rootDir, err := m.RootDir(startDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// 编译的Appfile存放目录
+	compileDir := filepath.Join(
+		rootDir, DefaultOutputDir, DefaultOutputDirCompiledAppfile)
+	return appfile.LoadCompiled(compileDir)
 }
 
 // Core返回一个Appfile的Core.Appfile应该从appfile.File.Path装载
 // root appfile路径将作为Otto的默认输出路径
 func (m *Meta) Core(f *appfile.Compiled) (*otto.Core, error) {
 	if f.File == nil || f.File.Path == "" {
-		return nil, fmt.Errorf("不能确定Appfile目录")
+		return nil, i18n.Errorf("command.appfile_dir_err")
 	}
 
 	rootDir, err := m.RootDir(filepath.Dir(f.File.Path))
@@ -83,6 +127,7 @@ func (m *Meta) Core(f *appfile.Compiled) (*otto.Core, error) {
 	config.CompileDir = filepath.Join(
 		rootDir, DefaultOutputDir, DefaultOutputDirCompiledData)
 	config.Ui = m.OttoUi()
+	config.ShutdownCh = m.ShutdownCh
 	config.Directory, err = m.Directory(&config)
 	if err != nil {
 		return nil, err
@@ -117,20 +162,61 @@ func (m *Meta) RootDir(startDir string) (string, error) {
 		i++
 	}
 
-	return "", fmt.Errorf(
-		"Otto doesn't appear to have compiled your Appfile yet!\n\n" +
-			"Run `otto compile` in the directory with the Appfile or\n" +
-			"with the `-appfile` flag in order to compile the files for\n" +
-			"developing, building, and deploying your application.\n\n" +
-			"Once the Appfile is compiled, you can run `otto` in any\n" +
-			"subdirectory.")
+	return "", i18n.Errorf("command.not_compiled")
 }
 
 // Directory返回Otto后端目录，如果没有指定，将使用Local目录
+//
+// 后端按照这个顺序选择: Appfile中project的directory，全局配置，
+// 最后是数据目录中的bolt后端
 func (m *Meta) Directory(config *otto.CoreConfig) (directory.Backend, error) {
-	return &directory.BoltBackend{
-		Dir: filepath.Join(config.DataDir, "directory"),
-	}, nil
+	backend := m.DirectoryBackend
+	path := m.DirectoryPath
+	baseDir := ""
+	if f := config.Appfile; f != nil && f.File != nil && f.File.Project != nil {
+		if d := f.File.Project.Directory; d != nil {
+			if d.Backend != "" {
+				backend = d.Backend
+			}
+			if d.Path != "" {
+				path = d.Path
+				baseDir = filepath.Dir(f.File.Path)
+			}
+		}
+	}
+
+	// http后端的path是服务端的地址，不是本地路径
+	if backend == "http" {
+		if path == "" {
+			return nil, i18n.Errorf("command.directory_address_err")
+		}
+
+		return &directory.HTTPBackend{Address: path}, nil
+	}
+
+	if path == "" {
+		path = filepath.Join(config.DataDir, "directory")
+	} else {
+		var err error
+		path, err = homedir.Expand(path)
+		if err != nil {
+			return nil, err
+		}
+
+		// Appfile中的相对路径相对于Appfile所在的目录
+		if baseDir != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+	}
+
+	switch backend {
+	case "", "bolt":
+		return &directory.BoltBackend{Dir: path}, nil
+	case "file":
+		return &directory.FileBackend{Dir: path}, nil
+	default:
+		return nil, i18n.Errorf("command.directory_backend_err", backend)
+	}
 }
 
 // FlagSet 返回每个命令的公共flag。FlagSet的具体行为
@@ -138,6 +224,18 @@ func (m *Meta) Directory(config *otto.CoreConfig) (directory.Backend, error) {
 func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 	f := flag.NewFlagSet(n, flag.ContinueOnError)
 
+	// 所有命令都有的flag: -input=false 禁止询问用户输入，
+	// -var id=value 预先给定InputOpts.Id的输入值，-json 输出JSON事件
+	f.BoolVar(&m.flagInput, "input", m.envInput(), "")
+	f.BoolVar(&m.flagJSON, "json", envJSON(), "")
+	f.Var(&m.flagVars, "var", "")
+	m.flagSet = true
+
+	// 命令自己的输出也要遵守-json
+	if _, ok := m.Ui.(*outputUi); !ok {
+		m.Ui = &outputUi{Ui: m.Ui, JSON: &m.flagJSON}
+	}
+
 	// 给Ui错误创建一个io.Writer。这是一个hack，但是其处理job。基本上：
 	// 创建一个pipe,然后扫描每一行，并输出每一行到UI，不断的循环处理。
 	errR, errW := io.Pipe()
@@ -154,5 +252,57 @@ func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 
 // OttoUi返回ui.Ui对象
 func (m *Meta) OttoUi() ui.Ui {
-	return NewUi(m.Ui)
+	opts := &UiOpts{
+		Input:    m.input(),
+		Vars:     m.flagVars,
+		InputEnv: m.CredsEnv,
+	}
+	if m.json() {
+		return NewJSONUi(m.rawUi(), opts)
+	}
+
+	return NewUi(m.rawUi(), opts)
+}
+
+// json 返回是否输出JSON事件。如果没有解析过FlagSet，使用环境变量
+func (m *Meta) json() bool {
+	if !m.flagSet {
+		return envJSON()
+	}
+
+	return m.flagJSON
+}
+
+// rawUi 返回没有经过-json处理的cli.Ui
+func (m *Meta) rawUi() cli.Ui {
+	if u, ok := m.Ui.(*outputUi); ok {
+		return u.Ui
+	}
+
+	return m.Ui
+}
+
+// input 返回是否可以询问用户输入。如果没有解析过FlagSet，
+// 使用环境变量
+func (m *Meta) input() bool {
+	if !m.flagSet {
+		return m.envInput()
+	}
+
+	return m.flagInput
+}
+
+// envInput 返回环境变量是否允许询问用户输入，默认允许
+func (m *Meta) envInput() bool {
+	v := os.Getenv(EnvInput)
+	if v == "" {
+		return true
+	}
+
+	result, err := strconv.ParseBool(v)
+	if err != nil {
+		return true
+	}
+
+	return result
 }
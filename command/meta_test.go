@@ -0,0 +1,93 @@
+package command
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kuuyee/otto-learn/appfile"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/otto"
+)
+
+func TestMetaDirectory(t *testing.T) {
+	appfilePath := filepath.Join("/app", "Appfile")
+	cases := []struct {
+		Meta      Meta
+		Directory *appfile.Directory
+		Expected  directory.Backend
+		Err       bool
+	}{
+		{
+			Meta{},
+			nil,
+			&directory.BoltBackend{Dir: filepath.Join("/data", "directory")},
+			false,
+		},
+		{
+			Meta{DirectoryBackend: "file", DirectoryPath: "/shared"},
+			nil,
+			&directory.FileBackend{Dir: "/shared"},
+			false,
+		},
+		{
+			// Appfile覆盖全局配置，相对路径相对于Appfile
+			Meta{DirectoryBackend: "bolt", DirectoryPath: "/shared"},
+			&appfile.Directory{Backend: "file", Path: "dir"},
+			&directory.FileBackend{Dir: filepath.Join("/app", "dir")},
+			false,
+		},
+		{
+			Meta{DirectoryBackend: "file"},
+			&appfile.Directory{Path: "/abs"},
+			&directory.FileBackend{Dir: "/abs"},
+			false,
+		},
+		{
+			Meta{DirectoryBackend: "nope"},
+			nil,
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		config := &otto.CoreConfig{
+			DataDir: "/data",
+			Appfile: &appfile.Compiled{
+				File: &appfile.File{
+					Path:    appfilePath,
+					Project: &appfile.Project{Directory: tc.Directory},
+				},
+			},
+		}
+
+		actual, err := tc.Meta.Directory(config)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%d: bad: %#v", i, actual)
+		}
+	}
+}
+
+func TestMetaDirectory_http(t *testing.T) {
+	config := &otto.CoreConfig{DataDir: "/data"}
+
+	m := Meta{DirectoryBackend: "http", DirectoryPath: "http://127.0.0.1:8300"}
+	actual, err := m.Directory(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := &directory.HTTPBackend{Address: "http://127.0.0.1:8300"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// 没有地址
+	m = Meta{DirectoryBackend: "http"}
+	if _, err := m.Directory(config); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,166 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/kuuyee/otto-learn/otto"
+)
+
+// StatusCommand 是一个状态命令，汇总显示整个
+// 项目的状态
+type StatusCommand struct {
+	Meta
+}
+
+func (c *StatusCommand) Run(args []string) int {
+	fs := c.FlagSet("status", FlagSetNone)
+	fs.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	status, err := c.status()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	// JSON输出。状态本身就是一个JSON文档，所以不再封装成事件
+	if c.json() {
+		data, err := json.MarshalIndent(status, "", "    ")
+		if err != nil {
+			c.Ui.Error(i18n.T("status.encode_err", err))
+			return 1
+		}
+
+		c.rawUi().Output(string(data))
+		return 0
+	}
+
+	c.output(status)
+	return 0
+}
+
+// status 收集项目的状态。Appfile没有编译时不是错误，
+// 只返回没有编译的状态。编译的Appfile存在但是装载不了时报错
+func (c *StatusCommand) status() (*otto.Status, error) {
+	// 没有找到输出目录说明还没有编译
+	startDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.RootDir(startDir); err != nil {
+		log.Printf("[DEBUG] 没有找到编译的Appfile: %s", err)
+		return &otto.Status{}, nil
+	}
+
+	app, err := c.Appfile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("[DEBUG] 没有找到编译的Appfile: %s", err)
+			return &otto.Status{}, nil
+		}
+
+		return nil, i18n.Errorf("status.appfile_load_err", err)
+	}
+
+	core, err := c.Core(app)
+	if err != nil {
+		return nil, i18n.Errorf("command.core_load_err", err)
+	}
+
+	return core.Status()
+}
+
+// output 把状态以可读的形式输出
+func (c *StatusCommand) output(status *otto.Status) {
+	ui := c.OttoUi()
+
+	if !status.Compiled {
+		ui.Header(i18n.T("status.not_compiled_header"))
+		ui.Message(i18n.T("status.not_compiled"))
+		return
+	}
+
+	compiled := i18n.T("status.compiled")
+	if status.Stale {
+		compiled = i18n.T("status.stale")
+	}
+
+	ui.Header(i18n.T("status.app_header"))
+	ui.Message(i18n.T("status.application", status.App, status.AppType))
+	ui.Message(i18n.T("status.project", status.Project))
+	ui.Message(i18n.T(
+		"status.infrastructure", status.InfraType, status.InfraFlavor))
+
+	ui.Header(i18n.T("status.component_header"))
+	ui.Message(i18n.T("status.appfile", compiled))
+	ui.Message(i18n.T("status.dev", statusText(status.Dev)))
+	ui.Message(i18n.T("status.infra", statusText(status.Infra)))
+	if status.Build == nil {
+		ui.Message(i18n.T("status.build_none"))
+	} else {
+		ui.Message(i18n.T("status.build",
+			statusText(status.Build.State),
+			status.Build.Time.Local().Format("2006-01-02 15:04:05")))
+	}
+
+	ui.Header(i18n.T("status.deploy_header"))
+	for _, d := range status.Deploys {
+		ui.Message(fmt.Sprintf("%s: %s", d.App, statusText(d.State)))
+	}
+}
+
+func (c *StatusCommand) Synopsis() string {
+	return "Status of the stages of this application"
+}
+
+func (c *StatusCommand) Help() string {
+	helpText := `
+Usage: otto status [options]
+
+  Outputs the status of the various stages of this application.
+
+  This reports whether the Appfile is compiled and whether the compiled
+  data is stale compared to the Appfile, together with the state of the
+  development environment, the infrastructure, the last build and the
+  deploy of every application in the Appfile.
+
+Options:
+
+  -json     Output the status as a JSON document. This is also the
+            format used when OTTO_OUTPUT=json is set.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// statusText 返回状态的可读形式
+func statusText(s string) string {
+	switch s {
+	case otto.StatusReady:
+		return i18n.T("status.state.ready")
+	case otto.StatusSuccess:
+		return i18n.T("status.state.success")
+	case otto.StatusDeployed:
+		return i18n.T("status.state.deployed")
+	case otto.StatusHalted:
+		return i18n.T("status.state.halted")
+	case otto.StatusPartial:
+		return i18n.T("status.state.partial")
+	case otto.StatusDrifted:
+		return i18n.T("status.state.drifted")
+	case otto.StatusFailed:
+		return i18n.T("status.state.failed")
+	case otto.StatusInvalid:
+		return i18n.T("status.state.invalid")
+	case otto.StatusNotDeployed:
+		return i18n.T("status.state.not_deployed")
+	default:
+		return i18n.T("status.state.not_created")
+	}
+}
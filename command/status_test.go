@@ -0,0 +1,84 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kuuyee/otto-learn/appfile"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/mitchellh/cli"
+)
+
+func TestStatusCommand_notCompiled(t *testing.T) {
+	cases := map[string]func(dir string){
+		// 没有输出目录
+		"no output dir": func(string) {},
+
+		// 输出目录中没有编译的Appfile
+		"no compiled appfile": func(dir string) {
+			testStatusCompileDir(t, dir)
+		},
+	}
+
+	for name, setup := range cases {
+		dir := testStatusDir(t)
+		setup(dir)
+
+		c := &StatusCommand{Meta: Meta{Ui: new(cli.MockUi)}}
+		status, err := c.status()
+		if err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+		if status.Compiled {
+			t.Fatalf("%s: should not be compiled", name)
+		}
+	}
+}
+
+func TestStatusCommand_loadErr(t *testing.T) {
+	// 编译的Appfile损坏时报错，不能当作没有编译
+	dir := testStatusDir(t)
+	path := filepath.Join(testStatusCompileDir(t, dir), appfile.CompileFilename)
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &StatusCommand{Meta: Meta{Ui: ui}}
+	if code := c.Run(nil); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	prefix := i18n.T("status.appfile_load_err", "")
+	if !strings.HasPrefix(ui.ErrorWriter.String(), prefix) {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
+
+// testStatusDir 创建一个临时目录并切换到这个目录，测试结束时切换回来
+func testStatusDir(t *testing.T) string {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return dir
+}
+
+// testStatusCompileDir 在dir中创建编译的Appfile存放目录
+func testStatusCompileDir(t *testing.T, dir string) string {
+	path := filepath.Join(dir, DefaultOutputDir, DefaultOutputDirCompiledAppfile)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return path
+}
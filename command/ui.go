@@ -14,18 +14,39 @@ import (
 
 	"github.com/hashicorp/otto/ui"
 	"github.com/hashicorp/vault/helper/password"
+	"github.com/kuuyee/otto-learn/helper/i18n"
 	"github.com/mitchellh/cli"
 )
 
 var defaultInputReader io.Reader
 var defaultInputWriter io.Writer
 
+// UiOpts 是创建otto UI的选项，控制Input的行为
+type UiOpts struct {
+	// Input 为false时不会询问用户输入
+	Input bool
+
+	// Vars 是按InputOpts.Id预先给定的输入值
+	Vars map[string]string
+
+	// InputEnv 把InputOpts.Id映射到环境变量的名字，这个环境变量
+	// 优先于InputOpts.EnvVars
+	InputEnv map[string]string
+}
+
 // 返回一个otto UI实现,封装cli.Ui
-func NewUi(raw cli.Ui) ui.Ui {
+func NewUi(raw cli.Ui, opts *UiOpts) ui.Ui {
 	return &ui.Styled{
-		Ui: &cliUi{
-			CliUi: raw,
-		},
+		Ui: newCliUi(raw, opts),
+	}
+}
+
+func newCliUi(raw cli.Ui, opts *UiOpts) *cliUi {
+	return &cliUi{
+		CliUi:    raw,
+		NoInput:  !opts.Input,
+		Vars:     opts.Vars,
+		InputEnv: opts.InputEnv,
 	}
 }
 
@@ -38,6 +59,12 @@ type cliUi struct {
 	Reader io.Reader
 	Writer io.Writer
 
+	// NoInput为true时不询问用户输入，没有值的Input直接返回错误。
+	// Vars和InputEnv参看UiOpts
+	NoInput  bool
+	Vars     map[string]string
+	InputEnv map[string]string
+
 	interrupted bool
 	l           sync.Mutex
 }
@@ -55,11 +82,20 @@ func (u *cliUi) Raw(msg string) {
 }
 
 func (i *cliUi) Input(opts *ui.InputOpts) (string, error) {
-	// 如何设置了环境变量，我们就不询问提示input
-	if value := opts.EnvVarValue(); value != "" {
+	// 已经有值的话就不询问提示input
+	if value, ok := i.value(opts); ok {
 		return value, nil
 	}
 
+	// 禁止输入时使用默认值，没有默认值则立即失败
+	if i.NoInput {
+		if opts.Default != "" {
+			return opts.Default, nil
+		}
+
+		return "", i.inputDisabledError(opts)
+	}
+
 	r := i.Reader
 	w := i.Writer
 	if r == nil {
@@ -82,7 +118,7 @@ func (i *cliUi) Input(opts *ui.InputOpts) (string, error) {
 
 	// 如果终止，那么就不询问input
 	if i.interrupted {
-		return "", errors.New("中断")
+		return "", i18n.Errorf("ui.interrupted")
 	}
 
 	// 监听中断操作，那么就不再询问input
@@ -102,11 +138,11 @@ func (i *cliUi) Input(opts *ui.InputOpts) (string, error) {
 		buf.WriteString("\n")
 	}
 	if opts.Default != "" {
-		buf.WriteString("  [bold]Default:[reset] ")
+		buf.WriteString(i18n.T("ui.default"))
 		buf.WriteString(opts.Default)
 		buf.WriteString("\n")
 	}
-	buf.WriteString("  [bold]输入一个值：[reset] ")
+	buf.WriteString(i18n.T("ui.enter_value"))
 
 	// 询问用户输入
 	if _, err := fmt.Fprint(w, ui.Colorize(buf.String())); err != nil {
@@ -118,7 +154,7 @@ func (i *cliUi) Input(opts *ui.InputOpts) (string, error) {
 	if opts.Hide {
 		f, ok := r.(*os.File)
 		if !ok {
-			return "", fmt.Errorf("必须读取一个文件")
+			return "", i18n.Errorf("ui.hide_not_file")
 		}
 
 		line, err := password.Read(f)
@@ -154,6 +190,43 @@ func (i *cliUi) Input(opts *ui.InputOpts) (string, error) {
 		// Mark that we were interrupted so future Ask calls fail.
 		i.interrupted = true
 
-		return "", errors.New("中断")
+		return "", i18n.Errorf("ui.interrupted")
+	}
+}
+
+// value 返回不需要询问用户的输入值。通过-var预先给定的值优先，
+// 然后是InputEnv映射的环境变量，最后是InputOpts.EnvVars
+func (i *cliUi) value(opts *ui.InputOpts) (string, bool) {
+	if value, ok := i.Vars[opts.Id]; ok {
+		return value, true
+	}
+
+	if env, ok := i.InputEnv[opts.Id]; ok {
+		if value := os.Getenv(env); value != "" {
+			return value, true
+		}
+	}
+
+	if value := opts.EnvVarValue(); value != "" {
+		return value, true
 	}
+
+	return "", false
+}
+
+// inputDisabledError 返回禁止输入时缺少值的错误，说明如何提供这个值
+func (i *cliUi) inputDisabledError(opts *ui.InputOpts) error {
+	envVars := opts.EnvVars
+	if env, ok := i.InputEnv[opts.Id]; ok {
+		envVars = append([]string{env}, envVars...)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(i18n.T("ui.input_disabled", opts.Id, EnvInput, opts.Id))
+	if len(envVars) > 0 {
+		buf.WriteString(i18n.T(
+			"ui.input_disabled_env", strings.Join(envVars, ", ")))
+	}
+
+	return errors.New(buf.String())
 }
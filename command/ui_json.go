@@ -0,0 +1,173 @@
+package command
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/otto/ui"
+	"github.com/mitchellh/cli"
+	"github.com/mitchellh/colorstring"
+)
+
+const (
+	// EnvOutput 是选择输出格式的环境变量。设置为"json"时
+	// 和-json一样
+	EnvOutput = "OTTO_OUTPUT"
+
+	// JSON输出的事件类型
+	JSONEventHeader       = "header"
+	JSONEventMessage      = "message"
+	JSONEventRaw          = "raw"
+	JSONEventError        = "error"
+	JSONEventInputRequest = "input-request"
+)
+
+// JSONEvent 是JSON输出模式下每一行输出的事件
+type JSONEvent struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Text      string    `json:"text"`
+
+	// Id 只用于input-request，是InputOpts.Id
+	Id string `json:"id,omitempty"`
+}
+
+// NewJSONUi 返回一个输出JSON事件的otto UI实现，每个事件一行
+func NewJSONUi(raw cli.Ui, opts *UiOpts) ui.Ui {
+	prompt := newCliUi(raw, opts)
+	prompt.Writer = ioutil.Discard
+	return &jsonUi{
+		CliUi:  raw,
+		Prompt: prompt,
+	}
+}
+
+// jsonUi 把所有输出变成JSON事件，通过CliUi.Output每行输出一个。
+// 它同时实现了ui.Ui和cli.Ui，所以命令本身的错误也是事件
+type jsonUi struct {
+	CliUi cli.Ui
+
+	// Prompt 用于真正读取用户输入，提示不会输出
+	Prompt *cliUi
+
+	l sync.Mutex
+}
+
+func (u *jsonUi) Header(msg string) {
+	u.event(JSONEventHeader, msg, "")
+}
+
+func (u *jsonUi) Message(msg string) {
+	u.event(JSONEventMessage, msg, "")
+}
+
+func (u *jsonUi) Raw(msg string) {
+	u.event(JSONEventRaw, msg, "")
+}
+
+func (u *jsonUi) Input(opts *ui.InputOpts) (string, error) {
+	// 只有真正需要询问用户时才输出input-request事件
+	_, ok := u.Prompt.value(opts)
+	if !ok && !u.Prompt.NoInput {
+		u.event(JSONEventInputRequest, opts.Query, opts.Id)
+	}
+
+	return u.Prompt.Input(opts)
+}
+
+// 以下实现cli.Ui
+
+func (u *jsonUi) Ask(query string) (string, error) {
+	return u.Prompt.Input(&ui.InputOpts{Query: query})
+}
+
+func (u *jsonUi) AskSecret(query string) (string, error) {
+	return u.Prompt.Input(&ui.InputOpts{Query: query, Hide: true})
+}
+
+func (u *jsonUi) Output(msg string) {
+	u.event(JSONEventMessage, msg, "")
+}
+
+func (u *jsonUi) Info(msg string) {
+	u.event(JSONEventMessage, msg, "")
+}
+
+func (u *jsonUi) Error(msg string) {
+	u.event(JSONEventError, msg, "")
+}
+
+func (u *jsonUi) Warn(msg string) {
+	u.event(JSONEventError, msg, "")
+}
+
+func (u *jsonUi) event(t, text, id string) {
+	data, err := json.Marshal(&JSONEvent{
+		Type:      t,
+		Timestamp: time.Now().UTC(),
+		Text:      jsonColorize.Color(text),
+		Id:        id,
+	})
+	if err != nil {
+		log.Printf("[ERR] 编码JSON事件报错: %s", err)
+		return
+	}
+
+	// 事件要在一行里，并且不能和其它事件交错
+	u.l.Lock()
+	defer u.l.Unlock()
+	u.CliUi.Output(string(data))
+}
+
+// jsonColorize 去掉输出中的颜色代码
+var jsonColorize = &colorstring.Colorize{
+	Colors:  colorstring.DefaultColors,
+	Disable: true,
+}
+
+// envJSON 返回环境变量是否选择了JSON输出
+func envJSON() bool {
+	return strings.ToLower(os.Getenv(EnvOutput)) == "json"
+}
+
+// outputUi 根据-json选择命令的cli.Ui。flag在FlagSet之后才解析，
+// 所以每次输出时才判断
+type outputUi struct {
+	cli.Ui
+
+	JSON *bool
+	json *jsonUi
+	once sync.Once
+}
+
+func (u *outputUi) current() cli.Ui {
+	if !*u.JSON {
+		return u.Ui
+	}
+
+	u.once.Do(func() {
+		u.json = &jsonUi{CliUi: u.Ui, Prompt: &cliUi{
+			CliUi:  u.Ui,
+			Writer: ioutil.Discard,
+		}}
+	})
+	return u.json
+}
+
+func (u *outputUi) Ask(query string) (string, error) {
+	return u.current().Ask(query)
+}
+
+func (u *outputUi) AskSecret(query string) (string, error) {
+	return u.current().AskSecret(query)
+}
+
+func (u *outputUi) Output(msg string) { u.current().Output(msg) }
+func (u *outputUi) Info(msg string)   { u.current().Info(msg) }
+func (u *outputUi) Error(msg string)  { u.current().Error(msg) }
+func (u *outputUi) Warn(msg string)   { u.current().Warn(msg) }
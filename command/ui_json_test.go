@@ -0,0 +1,86 @@
+package command
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/ui"
+	"github.com/mitchellh/cli"
+)
+
+func TestJSONUi_impl(t *testing.T) {
+	var _ ui.Ui = new(jsonUi)
+	var _ cli.Ui = new(jsonUi)
+}
+
+func TestJSONUi(t *testing.T) {
+	raw := new(cli.MockUi)
+	u := NewJSONUi(raw, &UiOpts{Vars: map[string]string{"foo": "bar"}})
+	u.Header("[bold]hello")
+	u.Message("world")
+	u.Raw("raw")
+	u.(cli.Ui).Error("bad")
+
+	// 有-var时不输出input-request
+	if v, err := u.Input(&ui.InputOpts{Id: "foo", Query: "foo?"}); err != nil || v != "bar" {
+		t.Fatalf("bad: %#v %s", v, err)
+	}
+
+	// 禁止输入时也不输出input-request
+	if _, err := u.Input(&ui.InputOpts{Id: "baz", Query: "baz?"}); err == nil {
+		t.Fatal("should error")
+	}
+
+	expected := []JSONEvent{
+		{Type: JSONEventHeader, Text: "hello"},
+		{Type: JSONEventMessage, Text: "world"},
+		{Type: JSONEventRaw, Text: "raw"},
+		{Type: JSONEventError, Text: "bad"},
+	}
+
+	var actual []JSONEvent
+	s := bufio.NewScanner(strings.NewReader(raw.OutputWriter.String()))
+	for s.Scan() {
+		var e JSONEvent
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("err: %s\n\n%s", err, s.Text())
+		}
+		if e.Timestamp.IsZero() {
+			t.Fatalf("no timestamp: %s", s.Text())
+		}
+		actual = append(actual, e)
+	}
+
+	if len(actual) != len(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+	for i, e := range expected {
+		if actual[i].Type != e.Type || actual[i].Text != e.Text {
+			t.Fatalf("bad %d: %#v", i, actual[i])
+		}
+	}
+}
+
+func TestMetaJSON(t *testing.T) {
+	raw := new(cli.MockUi)
+	m := &Meta{Ui: raw}
+	fs := m.FlagSet("test", FlagSetNone)
+	if err := fs.Parse([]string{"-json"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	m.Ui.Error("bad")
+	if _, ok := m.OttoUi().(*jsonUi); !ok {
+		t.Fatalf("bad: %#v", m.OttoUi())
+	}
+
+	var e JSONEvent
+	if err := json.Unmarshal(raw.OutputWriter.Bytes(), &e); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e.Type != JSONEventError || e.Text != "bad" {
+		t.Fatalf("bad: %#v", e)
+	}
+}
@@ -0,0 +1,91 @@
+package command
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCliUiInput_vars(t *testing.T) {
+	u := &cliUi{
+		NoInput: true,
+		Vars:    map[string]string{"foo": "bar"},
+	}
+
+	v, err := u.Input(&ui.InputOpts{Id: "foo", Query: "foo?"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "bar" {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestCliUiInput_noInputDefault(t *testing.T) {
+	u := &cliUi{NoInput: true}
+
+	v, err := u.Input(&ui.InputOpts{Id: "foo", Default: "baz"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "baz" {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestCliUiInput_noInputEnv(t *testing.T) {
+	os.Setenv("OTTO_TEST_FOO", "env")
+	defer os.Unsetenv("OTTO_TEST_FOO")
+
+	u := &cliUi{NoInput: true}
+	v, err := u.Input(&ui.InputOpts{
+		Id:      "foo",
+		EnvVars: []string{"OTTO_TEST_FOO"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "env" {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestCliUiInput_noInputMissing(t *testing.T) {
+	u := &cliUi{NoInput: true}
+
+	_, err := u.Input(&ui.InputOpts{
+		Id:      "aws_access_key",
+		EnvVars: []string{"AWS_ACCESS_KEY_ID"},
+	})
+	if err == nil {
+		t.Fatal("should error")
+	}
+	for _, s := range []string{"aws_access_key", "AWS_ACCESS_KEY_ID"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("error should contain %q: %s", s, err)
+		}
+	}
+}
+
+func TestMetaInput(t *testing.T) {
+	os.Setenv(EnvInput, "false")
+	defer os.Unsetenv(EnvInput)
+
+	m := new(Meta)
+	if m.input() {
+		t.Fatal("env should disable input")
+	}
+
+	fs := m.FlagSet("test", FlagSetNone)
+	if err := fs.Parse([]string{"-input=true", "-var", "a=b"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !m.input() {
+		t.Fatal("flag should enable input")
+	}
+	if m.flagVars["a"] != "b" {
+		t.Fatalf("bad: %#v", m.flagVars)
+	}
+}
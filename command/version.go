@@ -3,6 +3,8 @@ package command
 import (
 	"bytes"
 	"fmt"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
 )
 
 // VersionCommand 实现了一个打印版本的命令
@@ -52,12 +54,10 @@ func (v *VersionCommand) Run(args []string) int {
 		// 检查最终版本
 		info, err := v.CheckFunc()
 		if err != nil {
-			v.Ui.Error(fmt.Sprintf("检查最终版本报错: %s", err))
+			v.Ui.Error(i18n.T("command.version_err", err))
 		}
 		if info.Outdated {
-			v.Ui.Output(fmt.Sprintf(
-				"你的Otto版本已经被弃用！最新版是: %s. 你可以"+
-					"从www.ottoproject.io下载升级", info.Lastest))
+			v.Ui.Output(i18n.T("command.version_outdated", info.Lastest))
 		}
 	}
 	return 0
@@ -55,7 +55,8 @@ const (
 	OutputPrefix = "o:"
 )
 
-func init() {
+// initCommands 使用装载的配置初始化Ui和所有的命令
+func initCommands(config *Config) {
 	Ui = &cli.ColoredUi{
 		OutputColor: cli.UiColorNone,
 		InfoColor:   cli.UiColorNone,
@@ -83,26 +84,78 @@ func init() {
 			Infrastructures: map[string]infrastructure.Factory{
 				"aws": infraAws.Infra,
 			},
+			TerraformVersion: config.TerraformVersion,
+			TerraformMirror:  config.TerraformMirror,
 		},
-		Ui: Ui,
+		Ui:               Ui,
+		CredsEnv:         config.CredsEnv,
+		DirectoryBackend: config.DirectoryBackend,
+		DirectoryPath:    config.DirectoryPath,
+		ShutdownCh:       makeShutdownCh(),
 	}
 	//fmt.Println(meta)
 
 	CommandsInclude = []string{
 		"compile",
 		"build",
+		"creds",
 		"deploy",
 		"dev",
+		"directory",
 		"infra",
 		"status",
 		"version",
 	}
 
 	Commands = map[string]cli.CommandFactory{
+		"build": func() (cli.Command, error) {
+			return &command.BuildCommand{
+				Meta: meta,
+			}, nil
+		},
+
 		"compile": func() (cli.Command, error) {
 			return &command.CompileCommand{
-				Meta:      meta,
-				Detectors: Detectors,
+				Meta:         meta,
+				Detectors:    Detectors,
+				DetectorDirs: config.DetectorDirs,
+			}, nil
+		},
+
+		"creds": func() (cli.Command, error) {
+			return &command.CredsCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"deploy": func() (cli.Command, error) {
+			return &command.DeployCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"dev": func() (cli.Command, error) {
+			return &command.DevCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"directory": func() (cli.Command, error) {
+			return &command.DirectoryCommand{
+				Meta:       meta,
+				ShutdownCh: makeShutdownCh(),
+			}, nil
+		},
+
+		"infra": func() (cli.Command, error) {
+			return &command.InfraCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"status": func() (cli.Command, error) {
+			return &command.StatusCommand{
+				Meta: meta,
 			}, nil
 		},
 
@@ -125,12 +178,19 @@ func makeShutdownCh() <-chan struct{} {
 
 	signalCh := make(chan os.Signal, 4)
 	signal.Notify(signalCh, os.Interrupt)
-	go func() {
-		for {
-			<-signalCh
-			resultCh <- struct{}{}
-		}
-	}()
+	go forwardShutdown(signalCh, resultCh)
 
 	return resultCh
 }
+
+// forwardShutdown 把收到的中断转发给resultCh。没有人在等待时丢弃
+// 这次中断，否则它会一直阻塞，直到下一次运行Terraform时才被收到，
+// 把那次运行也中断掉
+func forwardShutdown(signalCh <-chan os.Signal, resultCh chan<- struct{}) {
+	for range signalCh {
+		select {
+		case resultCh <- struct{}{}:
+		default:
+		}
+	}
+}
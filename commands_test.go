@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestForwardShutdown(t *testing.T) {
+	signalCh := make(chan os.Signal, 4)
+	resultCh := make(chan struct{})
+	go forwardShutdown(signalCh, resultCh)
+	defer close(signalCh)
+
+	// 没有人等待时的中断被丢弃，不会留给之后的运行
+	signalCh <- os.Interrupt
+	for len(signalCh) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-resultCh:
+		t.Fatal("interrupt should be dropped")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 有人等待时收到中断
+	doneCh := make(chan struct{})
+	go func() {
+		<-resultCh
+		close(doneCh)
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		signalCh <- os.Interrupt
+		select {
+		case <-doneCh:
+			return
+		case <-timeout:
+			t.Fatal("interrupt not forwarded")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -1,9 +1,28 @@
 package main
 
 import (
-//"fmt"
-//"github.com/hashicorp/hcl"
-//"io/ioutil"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/hashicorp/hcl"
+)
+
+const (
+	// EnvConfigFile 指定全局配置文件的路径，默认是配置目录
+	// 中的config.hcl
+	EnvConfigFile = "OTTO_CONFIG_FILE"
+
+	// 以下环境变量覆盖配置文件中同名的设置
+	EnvDisableCheckpoint          = "OTTO_DISABLE_CHECKPOINT"
+	EnvDisableCheckpointSignature = "OTTO_DISABLE_CHECKPOINT_SIGNATURE"
+	EnvDetectorDirs               = "OTTO_DETECTOR_DIRS"
+	EnvDirectoryBackend           = "OTTO_DIRECTORY_BACKEND"
+	EnvDirectoryPath              = "OTTO_DIRECTORY_PATH"
+	EnvTerraformVersion           = "OTTO_TERRAFORM_VERSION"
+	EnvTerraformMirror            = "OTTO_TERRAFORM_MIRROR"
 )
 
 // 用一个结构来配置Otto CLI
@@ -11,7 +30,27 @@ import (
 // 这不是用来配置Otto本身，那在`config`包中
 type Config struct {
 	DisableCheckpoint          bool `hcl:"disable_checkpoint"`
-	DisableCheckpointSignature bool `hcl:disable_checkpoint_signature`
+	DisableCheckpointSignature bool `hcl:"disable_checkpoint_signature"`
+
+	// CredsEnv 把infrastructure认证的输入ID映射到环境变量的名字，
+	// 例如 aws_access_key = "MY_AWS_KEY"。询问认证之前先读取
+	// 这些环境变量
+	CredsEnv map[string]string `hcl:"creds_env"`
+
+	// DetectorDirs 是除了数据目录中的detect目录之外，还要装载
+	// detector配置的目录
+	DetectorDirs []string `hcl:"detector_dirs"`
+
+	// DirectoryBackend 和 DirectoryPath 选择保存Appfile数据的
+	// 目录后端，"bolt"(默认)、"file"或"http"，参看command.Meta.Directory
+	DirectoryBackend string `hcl:"directory_backend"`
+	DirectoryPath    string `hcl:"directory_path"`
+
+	// TerraformVersion 固定Otto使用的Terraform版本，比如 "0.6.16"。
+	// TerraformMirror 是下载Terraform的本地目录或者URL，目录结构和
+	// releases.hashicorp.com/terraform 一样，参看helper/terraform.Installer
+	TerraformVersion string `hcl:"terraform_version"`
+	TerraformMirror  string `hcl:"terraform_mirror"`
 }
 
 var BuiltinConfig Config
@@ -21,4 +60,142 @@ func ConfigDir() (string, error) {
 	return configDir()
 }
 
-// LoadConfig 从.ottorc文件装载CLI配置
+// ConfigFile 返回全局配置文件的路径，可以通过环境变量
+// OTTO_CONFIG_FILE指定
+func ConfigFile() (string, error) {
+	if path := os.Getenv(EnvConfigFile); path != "" {
+		return path, nil
+	}
+
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "config.hcl"), nil
+}
+
+// LoadConfig 从给定的HCL文件装载CLI配置
+func LoadConfig(path string) (*Config, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := hcl.Parse(string(d))
+	if err != nil {
+		return nil, err
+	}
+
+	var result Config
+	if err := hcl.DecodeObject(&result, obj); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// loadConfig 装载全局配置文件并合并到BuiltinConfig，然后应用
+// 环境变量的覆盖。配置文件不存在不是错误
+func loadConfig() (*Config, error) {
+	config := BuiltinConfig
+
+	path, err := ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("查找配置文件报错: %s", err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		fileConfig, err := LoadConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("装载配置文件 '%s' 报错: %s", path, err)
+		}
+
+		config = *config.Merge(fileConfig)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取配置文件 '%s' 报错: %s", path, err)
+	}
+
+	if err := config.mergeEnv(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Merge 把c2合并到c1上，返回新的配置。c2中设置了的值优先，
+// CredsEnv和DetectorDirs会合并
+func (c1 *Config) Merge(c2 *Config) *Config {
+	var result Config
+	result.DisableCheckpoint = c1.DisableCheckpoint || c2.DisableCheckpoint
+	result.DisableCheckpointSignature = c1.DisableCheckpointSignature ||
+		c2.DisableCheckpointSignature
+
+	result.CredsEnv = make(map[string]string)
+	for k, v := range c1.CredsEnv {
+		result.CredsEnv[k] = v
+	}
+	for k, v := range c2.CredsEnv {
+		result.CredsEnv[k] = v
+	}
+
+	result.DetectorDirs = make([]string, 0, len(c1.DetectorDirs)+len(c2.DetectorDirs))
+	result.DetectorDirs = append(result.DetectorDirs, c1.DetectorDirs...)
+	result.DetectorDirs = append(result.DetectorDirs, c2.DetectorDirs...)
+
+	result.DirectoryBackend = c1.DirectoryBackend
+	if c2.DirectoryBackend != "" {
+		result.DirectoryBackend = c2.DirectoryBackend
+	}
+	result.DirectoryPath = c1.DirectoryPath
+	if c2.DirectoryPath != "" {
+		result.DirectoryPath = c2.DirectoryPath
+	}
+	result.TerraformVersion = c1.TerraformVersion
+	if c2.TerraformVersion != "" {
+		result.TerraformVersion = c2.TerraformVersion
+	}
+	result.TerraformMirror = c1.TerraformMirror
+	if c2.TerraformMirror != "" {
+		result.TerraformMirror = c2.TerraformMirror
+	}
+
+	return &result
+}
+
+// mergeEnv 用环境变量覆盖配置
+func (c *Config) mergeEnv() error {
+	for k, ptr := range map[string]*bool{
+		EnvDisableCheckpoint:          &c.DisableCheckpoint,
+		EnvDisableCheckpointSignature: &c.DisableCheckpointSignature,
+	} {
+		v := os.Getenv(k)
+		if v == "" {
+			continue
+		}
+
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 的值不是bool: %s", k, v)
+		}
+		*ptr = b
+	}
+
+	if v := os.Getenv(EnvDetectorDirs); v != "" {
+		c.DetectorDirs = filepath.SplitList(v)
+	}
+	if v := os.Getenv(EnvDirectoryBackend); v != "" {
+		c.DirectoryBackend = v
+	}
+	if v := os.Getenv(EnvDirectoryPath); v != "" {
+		c.DirectoryPath = v
+	}
+	if v := os.Getenv(EnvTerraformVersion); v != "" {
+		c.TerraformVersion = v
+	}
+	if v := os.Getenv(EnvTerraformMirror); v != "" {
+		c.TerraformMirror = v
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigMerge(t *testing.T) {
+	c1 := &Config{
+		DisableCheckpoint: true,
+		CredsEnv:          map[string]string{"a": "A", "b": "B"},
+		DetectorDirs:      []string{"one"},
+		DirectoryBackend:  "bolt",
+		TerraformVersion:  "0.6.3",
+		TerraformMirror:   "/mirror",
+	}
+	c2 := &Config{
+		CredsEnv:         map[string]string{"b": "B2"},
+		DetectorDirs:     []string{"two"},
+		DirectoryPath:    "/tmp/dir",
+		TerraformVersion: "0.6.16",
+	}
+
+	expected := &Config{
+		DisableCheckpoint: true,
+		CredsEnv:          map[string]string{"a": "A", "b": "B2"},
+		DetectorDirs:      []string{"one", "two"},
+		DirectoryBackend:  "bolt",
+		DirectoryPath:     "/tmp/dir",
+		TerraformVersion:  "0.6.16",
+		TerraformMirror:   "/mirror",
+	}
+
+	actual := c1.Merge(c2)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestConfigMergeEnv(t *testing.T) {
+	env := map[string]string{
+		EnvDisableCheckpointSignature: "1",
+		EnvDetectorDirs:               "one" + string(os.PathListSeparator) + "two",
+		EnvDirectoryBackend:           "foo",
+		EnvTerraformVersion:           "0.6.16",
+	}
+	for k, v := range env {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+
+	c := &Config{DirectoryBackend: "bolt", DirectoryPath: "/tmp"}
+	if err := c.mergeEnv(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		DisableCheckpointSignature: true,
+		DetectorDirs:               []string{"one", "two"},
+		DirectoryBackend:           "foo",
+		DirectoryPath:              "/tmp",
+		TerraformVersion:           "0.6.16",
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("bad: %#v", c)
+	}
+}
+
+func TestConfigMergeEnv_badBool(t *testing.T) {
+	defer os.Setenv(EnvDisableCheckpoint, os.Getenv(EnvDisableCheckpoint))
+	os.Setenv(EnvDisableCheckpoint, "nope")
+
+	c := new(Config)
+	if err := c.mergeEnv(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := testConfigFile(t, testConfigHCL)
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		DisableCheckpoint: true,
+		CredsEnv:          map[string]string{"aws_access_key": "MY_AWS_KEY"},
+		DetectorDirs:      []string{"/detect"},
+		DirectoryBackend:  "file",
+		DirectoryPath:     "/tmp/otto",
+		TerraformVersion:  "0.6.16",
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("bad: %#v", c)
+	}
+}
+
+func TestLoadConfig_malformed(t *testing.T) {
+	path := testConfigFile(t, "directory_backend = \"file\n")
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	// 配置文件中的值合并到BuiltinConfig上
+	defer func(c Config) { BuiltinConfig = c }(BuiltinConfig)
+	BuiltinConfig = Config{
+		CredsEnv:         map[string]string{"aws_secret_key": "MY_AWS_SECRET"},
+		DetectorDirs:     []string{"/builtin"},
+		DirectoryBackend: "bolt",
+		TerraformMirror:  "/mirror",
+	}
+
+	testConfigEnv(t, testConfigFile(t, testConfigHCL))
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		DisableCheckpoint: true,
+		CredsEnv: map[string]string{
+			"aws_access_key": "MY_AWS_KEY",
+			"aws_secret_key": "MY_AWS_SECRET",
+		},
+		DetectorDirs:     []string{"/builtin", "/detect"},
+		DirectoryBackend: "file",
+		DirectoryPath:    "/tmp/otto",
+		TerraformVersion: "0.6.16",
+		TerraformMirror:  "/mirror",
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("bad: %#v", c)
+	}
+}
+
+func TestLoadConfigEnv_missing(t *testing.T) {
+	// 配置文件不存在时使用BuiltinConfig
+	defer func(c Config) { BuiltinConfig = c }(BuiltinConfig)
+	BuiltinConfig = Config{DirectoryBackend: "bolt"}
+
+	testConfigEnv(t, filepath.Join(t.TempDir(), "config.hcl"))
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.DirectoryBackend != "bolt" {
+		t.Fatalf("bad: %#v", c)
+	}
+}
+
+func TestLoadConfigEnv_malformed(t *testing.T) {
+	// 配置文件有语法错误时报错，不能退回到BuiltinConfig
+	testConfigEnv(t, testConfigFile(t, "directory_backend = {\n"))
+
+	if c, err := loadConfig(); err == nil {
+		t.Fatalf("should error: %#v", c)
+	}
+}
+
+const testConfigHCL = `
+disable_checkpoint = true
+directory_backend = "file"
+directory_path = "/tmp/otto"
+detector_dirs = ["/detect"]
+terraform_version = "0.6.16"
+
+creds_env {
+	aws_access_key = "MY_AWS_KEY"
+}
+`
+
+// testConfigFile 把内容写到临时目录中的配置文件，返回路径
+func testConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.hcl")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return path
+}
+
+// testConfigEnv 让loadConfig读取给定的配置文件，并清除会覆盖
+// 配置的环境变量
+func testConfigEnv(t *testing.T, path string) {
+	env := map[string]string{EnvConfigFile: path}
+	for _, k := range []string{
+		EnvDisableCheckpoint,
+		EnvDisableCheckpointSignature,
+		EnvDetectorDirs,
+		EnvDirectoryBackend,
+		EnvDirectoryPath,
+		EnvTerraformVersion,
+		EnvTerraformMirror,
+	} {
+		env[k] = ""
+	}
+
+	for k, v := range env {
+		old := os.Getenv(k)
+		t.Cleanup(func() { os.Setenv(k, old) })
+		os.Setenv(k, v)
+	}
+}
@@ -0,0 +1,27 @@
+// +build darwin freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+func configFile() (string, error) {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, ".ottorc"), nil
+}
+
+func configDir() (string, error) {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, ".otto.d"), nil
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"path/filepath"
 	"syscall"
 	"unsafe"
@@ -19,7 +18,6 @@ func configFile() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	fmt.Printf("[KuuYee]====> homedir: %s",dir)
 	return filepath.Join(dir, "otto.rc"), nil
 }
 
@@ -28,9 +26,7 @@ func configDir() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	getdir := filepath.Join(dir, "otto.d")
-	fmt.Println(getdir)
-	return getdir, nil
+	return filepath.Join(dir, "otto.d"), nil
 }
 
 func homeDir() (string, error) {
@@ -41,6 +37,5 @@ func homeDir() (string, error) {
 		return "", err
 	}
 	home := syscall.UTF16ToString(b)
-	fmt.Printf("%v\n", syscall.MAX_PATH)
 	return home, nil
 }
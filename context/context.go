@@ -1,9 +1,10 @@
 package context
 
 import (
-	"github.com/hashicorp/otto/directory"
 	"github.com/hashicorp/otto/ui"
 	"github.com/kuuyee/otto-learn/appfile"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
 )
 
 // Shared用来在app/infra中共享上下文
@@ -24,12 +25,64 @@ type Shared struct {
 	// InstallDir是放置二进制文件的目录
 	InstallDir string
 
+	// TerraformVersion 固定使用的Terraform版本，为空时使用已经安装的
+	// 足够新的版本。TerraformMirror 是安装固定版本时下载的本地目录
+	// 或者URL，为空时从HashiCorp下载。参看helper/terraform.Installer
+	TerraformVersion string
+	TerraformMirror  string
+
 	// appfile
 	Appfile *appfile.File
 
+	// ShutdownCh 在用户中断Otto(Ctrl-C)的时候收到消息。长时间运行的
+	// 操作，比如Terraform，应该在收到消息时停止。可以为nil
+	ShutdownCh <-chan struct{}
+
+	// Infra 是Appfile使用的infrastructure在目录中的记录，包括它的
+	// 状态和输出。Core在编译、构建和部署时设置它，infrastructure还
+	// 没有创建时是nil。需要infrastructure的输出时使用InfraOutputs
+	Infra *directory.Infra
+
 	// FoundationDirs是放置各种基础脚本的目录
 	//
 	// 这些目录会包含 dev,deploy子目录,将会在环境中被装载
 	// 目录中将存在一个"main.sh"并被调用
 	FoundationDirs []string
 }
+
+// InfraOutputs 返回Appfile使用的infrastructure的输出，列表和map类型
+// 的输出保留原来的结构，参看directory.Output。没有设置Infra时从目录
+// 读取
+//
+// 需要infrastructure的应用和foundation应该使用这个方法：infrastructure
+// 还没有创建或者只创建了一部分时，它返回告诉用户怎么做的错误
+func (s *Shared) InfraOutputs() (directory.Outputs, error) {
+	if s.Appfile == nil {
+		return nil, nil
+	}
+
+	config := s.Appfile.ActiveInfrastructure()
+	if config == nil {
+		return nil, i18n.Errorf(
+			"core.infra.not_found", s.Appfile.Project.Infrastructure)
+	}
+
+	infra := s.Infra
+	if infra == nil && s.Directory != nil {
+		var err error
+		infra, err = s.Directory.GetInfra(&directory.Infra{
+			Lookup: directory.Lookup{Infra: config.Name}})
+		if err != nil {
+			return nil, i18n.Errorf("core.infra.lookup_err", err)
+		}
+	}
+
+	switch {
+	case infra.IsPartial():
+		return nil, i18n.Errorf("context.infra_partial", config.Name)
+	case !infra.IsReady():
+		return nil, i18n.Errorf("context.infra_not_created", config.Name)
+	}
+
+	return infra.AllOutputs(), nil
+}
@@ -0,0 +1,81 @@
+package context
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kuuyee/otto-learn/appfile"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+func TestSharedInfraOutputs(t *testing.T) {
+	s := &Shared{
+		Appfile: &appfile.File{
+			Project: &appfile.Project{Infrastructure: "aws"},
+			Infrastructure: []*appfile.Infrastructure{
+				&appfile.Infrastructure{Name: "aws", Type: "aws"},
+			},
+		},
+		Directory: &directory.FileBackend{Dir: filepath.Join(t.TempDir(), "directory")},
+	}
+
+	// 还没有创建infrastructure
+	_, err := s.InfraOutputs()
+	if err == nil || err.Error() != i18n.T("context.infra_not_created", "aws") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// 只创建了一部分
+	infra := &directory.Infra{
+		Lookup: directory.Lookup{Infra: "aws"},
+		State:  directory.InfraStatePartial,
+	}
+	if err := s.Directory.PutInfra(infra); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err = s.InfraOutputs()
+	if err == nil || err.Error() != i18n.T("context.infra_partial", "aws") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	infra.State = directory.InfraStateReady
+	infra.SetOutputs(directory.Outputs{
+		"subnet_ids": directory.ListOutput([]string{"subnet-1", "subnet-2"}),
+	})
+	if err := s.Directory.PutInfra(infra); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	outputs, err := s.InfraOutputs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if l := outputs["subnet_ids"].List(); len(l) != 2 || l[1] != "subnet-2" {
+		t.Fatalf("bad: %#v", outputs)
+	}
+}
+
+func TestSharedInfraOutputs_set(t *testing.T) {
+	// Core设置的记录优先于目录
+	s := &Shared{
+		Appfile: &appfile.File{
+			Project: &appfile.Project{Infrastructure: "aws"},
+			Infrastructure: []*appfile.Infrastructure{
+				&appfile.Infrastructure{Name: "aws", Type: "aws"},
+			},
+		},
+		Infra: &directory.Infra{
+			State:   directory.InfraStateDrifted,
+			Outputs: map[string]string{"vpc_id": "vpc-1"},
+		},
+	}
+
+	outputs, err := s.InfraOutputs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if outputs["vpc_id"].String() != "vpc-1" {
+		t.Fatalf("bad: %#v", outputs)
+	}
+}
@@ -0,0 +1,396 @@
+package directory
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// ArchiveVersion 是导出的归档格式的版本。格式改变时增加，
+// 导入时拒绝不认识的版本
+const ArchiveVersion = 1
+
+// archiveManifestName 是归档中清单的文件名。清单是归档中的
+// 最后一个文件，因为只有写完数据之后才知道校验和
+const archiveManifestName = "manifest.json"
+
+// 归档中数据的类型，也是数据所在的目录
+const (
+	ArchiveBlob   = "blob"
+	ArchiveInfra  = "infra"
+	ArchiveBuild  = "build"
+	ArchiveDeploy = "deploy"
+)
+
+// ArchiveManifest 是导出的归档的清单，记录归档中每个文件的
+// 类型和校验和
+type ArchiveManifest struct {
+	Version int
+	Created time.Time
+	Entries []*ArchiveEntry
+}
+
+// ArchiveEntry 是归档中的一个文件
+type ArchiveEntry struct {
+	// Path 是文件在归档中的路径
+	Path string
+
+	// Type 是数据的类型，ArchiveBlob等常量之一
+	Type string
+
+	// Key 是二进制数据的key，只用于ArchiveBlob
+	Key string `json:",omitempty"`
+
+	// Size 和 SHA256 是文件内容的大小和SHA256校验和
+	Size   int64
+	SHA256 string
+}
+
+// Export 把b中的所有数据写成一个tar归档。b必须实现Lister
+func Export(w io.Writer, b Backend) (*ArchiveManifest, error) {
+	lister, ok := b.(Lister)
+	if !ok {
+		return nil, i18n.Errorf("directory.export_not_lister")
+	}
+
+	manifest := &ArchiveManifest{
+		Version: ArchiveVersion,
+		Created: time.Now().UTC(),
+	}
+	tw := tar.NewWriter(w)
+
+	// 二进制数据的大小事先不知道，先写到临时文件中
+	keys, err := lister.ListBlobs()
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		entry := &ArchiveEntry{
+			Path: fmt.Sprintf("%s/%d", ArchiveBlob, i),
+			Type: ArchiveBlob,
+			Key:  key,
+		}
+		if err := exportBlob(tw, b, entry); err != nil {
+			return nil, i18n.Errorf("directory.export_err", key, err)
+		}
+
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	// 记录编码成JSON
+	var records []interface{}
+	var types []string
+	infras, err := lister.ListInfra()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range infras {
+		records = append(records, v)
+		types = append(types, ArchiveInfra)
+	}
+	builds, err := lister.ListBuilds()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range builds {
+		records = append(records, v)
+		types = append(types, ArchiveBuild)
+	}
+	deploys, err := lister.ListDeploys()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range deploys {
+		records = append(records, v)
+		types = append(types, ArchiveDeploy)
+	}
+
+	for i, v := range records {
+		data, err := json.MarshalIndent(v, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &ArchiveEntry{
+			Path: fmt.Sprintf("%s/%d.json", types[i], i),
+			Type: types[i],
+		}
+		if err := archiveWrite(tw, entry, data); err != nil {
+			return nil, err
+		}
+
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	// 最后写入清单
+	data, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    archiveManifestName,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: manifest.Created,
+	})
+	if err == nil {
+		_, err = tw.Write(data)
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Import 读取Export写的归档，把数据写入b。写入之前先读取整个
+// 归档并检查清单和校验和，归档有任何问题都不会写入任何数据
+//
+// 已经存在的相同数据会被覆盖
+func Import(r io.Reader, b Backend) (*ArchiveManifest, error) {
+	// 数据先解压到临时目录中，检查通过之后再写入
+	td, err := ioutil.TempDir("", "otto-import")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(td)
+
+	manifest, files, err := importRead(r, td)
+	if err != nil {
+		return nil, err
+	}
+	if err := importVerify(manifest, files); err != nil {
+		return nil, err
+	}
+
+	// 先解码所有的记录，确保写入时不会因为格式错误失败
+	records := make([]interface{}, len(manifest.Entries))
+	for i, entry := range manifest.Entries {
+		var v interface{}
+		switch entry.Type {
+		case ArchiveBlob:
+			continue
+		case ArchiveInfra:
+			v = new(Infra)
+		case ArchiveBuild:
+			v = new(Build)
+		case ArchiveDeploy:
+			v = new(Deploy)
+		}
+
+		data, err := ioutil.ReadFile(files[entry.Path].Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return nil, i18n.Errorf("directory.import_format_err", entry.Path, err)
+		}
+
+		records[i] = v
+	}
+
+	// 写入
+	for i, entry := range manifest.Entries {
+		switch v := records[i].(type) {
+		case *Infra:
+			err = b.PutInfra(v)
+		case *Build:
+			err = b.PutBuild(v)
+		case *Deploy:
+			err = b.PutDeploy(v)
+		default:
+			var f *os.File
+			f, err = os.Open(files[entry.Path].Path)
+			if err == nil {
+				err = b.PutBlob(entry.Key, &BlobData{Data: f, closer: f})
+			}
+		}
+		if err != nil {
+			return nil, i18n.Errorf("directory.import_err", entry.Path, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// archiveFile 是从归档中解压出来的文件
+type archiveFile struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// importRead 把归档中的所有文件解压到dir中，计算每个文件的
+// 校验和，并解码清单
+func importRead(r io.Reader, dir string) (*ArchiveManifest, map[string]*archiveFile, error) {
+	var manifest *ArchiveManifest
+	files := make(map[string]*archiveFile)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, i18n.Errorf("directory.archive_read_err", err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			return nil, nil, i18n.Errorf("directory.archive_file_err", hdr.Name)
+		}
+
+		if hdr.Name == archiveManifestName {
+			manifest = new(ArchiveManifest)
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, nil, i18n.Errorf("directory.archive_manifest_err", err)
+			}
+
+			continue
+		}
+
+		if _, ok := files[hdr.Name]; ok {
+			return nil, nil, i18n.Errorf("directory.archive_duplicate", hdr.Name)
+		}
+
+		// 临时文件用序号命名，不使用归档中的路径
+		f := &archiveFile{
+			Path: filepath.Join(dir, fmt.Sprintf("%d", len(files))),
+		}
+		out, err := os.Create(f.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		h := sha256.New()
+		f.Size, err = io.Copy(io.MultiWriter(out, h), tr)
+		out.Close()
+		if err != nil {
+			return nil, nil, i18n.Errorf("directory.archive_file_read_err", hdr.Name, err)
+		}
+
+		f.SHA256 = hex.EncodeToString(h.Sum(nil))
+		files[hdr.Name] = f
+	}
+
+	if manifest == nil {
+		return nil, nil, i18n.Errorf("directory.archive_no_manifest", archiveManifestName)
+	}
+
+	return manifest, files, nil
+}
+
+// importVerify 检查清单和归档中的文件是否一致
+func importVerify(m *ArchiveManifest, files map[string]*archiveFile) error {
+	if m.Version != ArchiveVersion {
+		return i18n.Errorf("directory.archive_version_err", m.Version, ArchiveVersion)
+	}
+
+	seen := make(map[string]struct{})
+	for _, entry := range m.Entries {
+		switch entry.Type {
+		case ArchiveBlob:
+			// key会作为路径写入目录，必须在写入任何数据之前检查
+			if err := validateBlobKey(entry.Key); err != nil {
+				return i18n.Errorf("directory.archive_key_err", entry.Path, err)
+			}
+		case ArchiveInfra, ArchiveBuild, ArchiveDeploy:
+		default:
+			return i18n.Errorf("directory.archive_type_err", entry.Path, entry.Type)
+		}
+
+		if _, ok := seen[entry.Path]; ok {
+			return i18n.Errorf("directory.archive_manifest_duplicate", entry.Path)
+		}
+		seen[entry.Path] = struct{}{}
+
+		f, ok := files[entry.Path]
+		if !ok {
+			return i18n.Errorf("directory.archive_missing", entry.Path)
+		}
+		if f.Size != entry.Size || f.SHA256 != entry.SHA256 {
+			return i18n.Errorf("directory.archive_checksum_err", entry.Path)
+		}
+	}
+
+	for name := range files {
+		if _, ok := seen[name]; !ok {
+			return i18n.Errorf("directory.archive_extra", name)
+		}
+	}
+
+	return nil
+}
+
+// exportBlob 把一个二进制数据写入归档并填充entry
+func exportBlob(tw *tar.Writer, b Backend, entry *ArchiveEntry) error {
+	data, err := b.GetBlob(entry.Key)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		// 列出之后被删除了
+		return i18n.Errorf("directory.export_blob_gone")
+	}
+	defer data.Close()
+
+	tf, err := ioutil.TempFile("", "otto-export")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tf.Name())
+	defer tf.Close()
+
+	h := sha256.New()
+	entry.Size, err = io.Copy(io.MultiWriter(tf, h), data.Data)
+	if err != nil {
+		return err
+	}
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	if _, err := tf.Seek(0, 0); err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    entry.Path,
+		Mode:    0644,
+		Size:    entry.Size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, tf)
+	return err
+}
+
+// archiveWrite 把data写入归档并填充entry的大小和校验和
+func archiveWrite(tw *tar.Writer, entry *ArchiveEntry, data []byte) error {
+	sum := sha256.Sum256(data)
+	entry.Size = int64(len(data))
+	entry.SHA256 = hex.EncodeToString(sum[:])
+
+	err := tw.WriteHeader(&tar.Header{
+		Name:    entry.Path,
+		Mode:    0644,
+		Size:    entry.Size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	return err
+}
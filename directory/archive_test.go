@@ -0,0 +1,268 @@
+package directory
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+func TestExportImport(t *testing.T) {
+	src := testBoltBackend(t)
+	defer os.RemoveAll(src.Dir)
+	testArchiveData(t, src)
+
+	var buf bytes.Buffer
+	exported, err := Export(&buf, src)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(exported.Entries) != 4 {
+		t.Fatalf("bad: %#v", exported.Entries)
+	}
+
+	// 导入到另一种后端
+	dst := testFileBackend(t)
+	defer os.RemoveAll(dst.Dir)
+	imported, err := Import(&buf, dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(imported.Entries) != len(exported.Entries) {
+		t.Fatalf("bad: %#v", imported.Entries)
+	}
+
+	// 数据和ID都保留
+	lookup := Lookup{Infra: "aws", Foundation: "consul"}
+	expectedInfra, _ := src.GetInfra(&Infra{Lookup: lookup})
+	actualInfra, err := dst.GetInfra(&Infra{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(actualInfra, expectedInfra) {
+		t.Fatalf("bad: %#v", actualInfra)
+	}
+
+	lookup = Lookup{AppID: "app", Infra: "aws", InfraFlavor: "simple"}
+	expectedDeploy, _ := src.GetDeploy(&Deploy{Lookup: lookup})
+	actualDeploy, err := dst.GetDeploy(&Deploy{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(actualDeploy, expectedDeploy) {
+		t.Fatalf("bad: %#v", actualDeploy)
+	}
+
+	data, err := dst.GetBlob("infra-aws/state")
+	if err != nil || data == nil {
+		t.Fatalf("bad: %#v %v", data, err)
+	}
+	raw, _ := ioutil.ReadAll(data.Data)
+	data.Close()
+	if string(raw) != "state" {
+		t.Fatalf("bad: %q", raw)
+	}
+}
+
+func TestImport_corrupt(t *testing.T) {
+	// 按英文的错误信息检查
+	defer os.Setenv(i18n.EnvLang, os.Getenv(i18n.EnvLang))
+	os.Setenv(i18n.EnvLang, "en")
+
+	src := testBoltBackend(t)
+	defer os.RemoveAll(src.Dir)
+	testArchiveData(t, src)
+
+	var buf bytes.Buffer
+	if _, err := Export(&buf, src); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	archive := buf.Bytes()
+
+	cases := map[string]struct {
+		Rewrite func(name string, data []byte) (string, []byte)
+		Err     string
+	}{
+		"changed blob": {
+			func(name string, data []byte) (string, []byte) {
+				if strings.HasPrefix(name, ArchiveBlob+"/") {
+					data = []byte("tampered")
+				}
+				return name, data
+			},
+			"checksum",
+		},
+		"missing manifest": {
+			func(name string, data []byte) (string, []byte) {
+				if name == archiveManifestName {
+					return "", nil
+				}
+				return name, data
+			},
+			archiveManifestName,
+		},
+		"missing file": {
+			func(name string, data []byte) (string, []byte) {
+				if strings.HasPrefix(name, ArchiveInfra+"/") {
+					return "", nil
+				}
+				return name, data
+			},
+			"missing",
+		},
+		"bad version": {
+			func(name string, data []byte) (string, []byte) {
+				if name != archiveManifestName {
+					return name, data
+				}
+
+				var m ArchiveManifest
+				json.Unmarshal(data, &m)
+				m.Version = ArchiveVersion + 1
+				data, _ = json.Marshal(&m)
+				return name, data
+			},
+			"version",
+		},
+		"bad key": {
+			func(name string, data []byte) (string, []byte) {
+				if name != archiveManifestName {
+					return name, data
+				}
+
+				var m ArchiveManifest
+				json.Unmarshal(data, &m)
+				for _, e := range m.Entries {
+					if e.Type == ArchiveBlob {
+						e.Key = "../../pwned"
+					}
+				}
+				data, _ = json.Marshal(&m)
+				return name, data
+			},
+			"invalid key",
+		},
+	}
+
+	for name, tc := range cases {
+		dst := testFileBackend(t)
+		defer os.RemoveAll(dst.Dir)
+
+		r := testRewriteArchive(t, archive, tc.Rewrite)
+		_, err := Import(r, dst)
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: bad err: %v", name, err)
+		}
+
+		// 检查失败时不能写入任何数据
+		if _, err := os.Stat(dst.Dir); !os.IsNotExist(err) {
+			t.Fatalf("%s: data written: %v", name, err)
+		}
+	}
+}
+
+func TestExport_notLister(t *testing.T) {
+	var b struct{ Backend }
+	if _, err := Export(ioutil.Discard, &b); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func testBoltBackend(t *testing.T) *BoltBackend {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &BoltBackend{Dir: dir}
+}
+
+// testFileBackend 返回一个目录还不存在的FileBackend
+func testFileBackend(t *testing.T) *FileBackend {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Remove(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &FileBackend{Dir: dir}
+}
+
+func testArchiveData(t *testing.T, b Backend) {
+	err := b.PutBlob("infra-aws/state", &BlobData{
+		Data: bytes.NewReader([]byte("state")),
+	})
+	if err == nil {
+		err = b.PutInfra(&Infra{
+			Lookup:  Lookup{Infra: "aws"},
+			State:   InfraStateReady,
+			Outputs: map[string]string{"vpc_id": "vpc-1"},
+		})
+	}
+	if err == nil {
+		err = b.PutInfra(&Infra{
+			Lookup: Lookup{Infra: "aws", Foundation: "consul"},
+			State:  InfraStatePartial,
+		})
+	}
+	if err == nil {
+		err = b.PutDeploy(&Deploy{
+			Lookup: Lookup{AppID: "app", Infra: "aws", InfraFlavor: "simple"},
+			State:  DeployStateSuccess,
+		})
+	}
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// testRewriteArchive 用f修改归档中的每个文件。f返回空的名字时
+// 删除这个文件
+func testRewriteArchive(
+	t *testing.T, archive []byte, f func(string, []byte) (string, []byte)) io.Reader {
+	var buf bytes.Buffer
+	tr := tar.NewReader(bytes.NewReader(archive))
+	tw := tar.NewWriter(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		name, data := f(hdr.Name, data)
+		if name == "" {
+			continue
+		}
+
+		hdr.Name = name
+		hdr.Size = int64(len(data))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &buf
+}
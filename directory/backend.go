@@ -0,0 +1,41 @@
+// directory包是Otto的目录服务。目录服务保存Otto管理的
+// infrastructure、部署等数据，以便在多次运行之间共享。
+package directory
+
+// Backend 是目录服务必须实现的接口。它是Otto和目录
+// 通信的协议
+type Backend interface {
+	// PutBlob 用给定的key保存任意的二进制数据。用来保存一些
+	// 不由Otto管理的大块结构化数据(比如Terraform状态)
+	//
+	// GetBlob 读取二进制数据，如果不存在返回nil
+	//
+	// DeleteBlob 删除二进制数据，数据不存在时什么都不做
+	PutBlob(string, *BlobData) error
+	GetBlob(string) (*BlobData, error)
+	DeleteBlob(string) error
+
+	// PutInfra 和 GetInfra 用来保存和读取infrastructure数据
+	PutInfra(*Infra) error
+	GetInfra(*Infra) (*Infra, error)
+
+	// PutBuild 和 GetBuild 用来保存和读取应用最后一次的构建
+	PutBuild(*Build) error
+	GetBuild(*Build) (*Build, error)
+
+	// PutDeploy 和 GetDeploy 用来保存和读取应用的部署数据
+	PutDeploy(*Deploy) error
+	GetDeploy(*Deploy) (*Deploy, error)
+}
+
+// Lister 是可以列出保存的所有数据的Backend。导出目录的时候
+// 需要后端实现这个接口
+type Lister interface {
+	// ListBlobs 返回所有二进制数据的key
+	ListBlobs() ([]string, error)
+
+	// ListInfra、ListBuilds 和 ListDeploys 返回保存的所有数据
+	ListInfra() ([]*Infra, error)
+	ListBuilds() ([]*Build, error)
+	ListDeploys() ([]*Deploy, error)
+}
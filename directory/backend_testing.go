@@ -0,0 +1,391 @@
+package directory
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestBackend 是所有Backend实现都要通过的一组测试。每个实现在
+// 自己的测试里用一个空的Backend调用它
+func TestBackend(t *testing.T, b Backend) {
+	testBackendBlob(t, b)
+	testBackendInfra(t, b)
+	testBackendBuild(t, b)
+	testBackendDeploy(t, b)
+
+	if l, ok := b.(Lister); ok {
+		testBackendList(t, l)
+	}
+	if l, ok := b.(Locker); ok {
+		testBackendLock(t, l)
+	}
+}
+
+func testBackendBlob(t *testing.T, b Backend) {
+	// 不存在的数据
+	data, err := b.GetBlob("foo")
+	if err != nil {
+		t.Fatalf("GetBlob missing err: %s", err)
+	}
+	if data != nil {
+		t.Fatalf("GetBlob missing should be nil: %#v", data)
+	}
+
+	// 写入然后读取，包括带有目录分隔符的key
+	for _, key := range []string{"foo", "bar/baz"} {
+		expected := []byte("data-" + key)
+		err := b.PutBlob(key, &BlobData{Data: bytes.NewReader(expected)})
+		if err != nil {
+			t.Fatalf("PutBlob %s err: %s", key, err)
+		}
+
+		data, err := b.GetBlob(key)
+		if err != nil {
+			t.Fatalf("GetBlob %s err: %s", key, err)
+		}
+		if data == nil {
+			t.Fatalf("GetBlob %s should not be nil", key)
+		}
+		actual, err := ioutil.ReadAll(data.Data)
+		data.Close()
+		if err != nil {
+			t.Fatalf("GetBlob %s read err: %s", key, err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("GetBlob %s bad: %q", key, actual)
+		}
+	}
+
+	// 覆盖写入
+	err = b.PutBlob("foo", &BlobData{Data: bytes.NewReader([]byte("new"))})
+	if err != nil {
+		t.Fatalf("PutBlob overwrite err: %s", err)
+	}
+	data, err = b.GetBlob("foo")
+	if err != nil || data == nil {
+		t.Fatalf("GetBlob overwrite: %#v %v", data, err)
+	}
+	actual, _ := ioutil.ReadAll(data.Data)
+	data.Close()
+	if string(actual) != "new" {
+		t.Fatalf("GetBlob overwrite bad: %q", actual)
+	}
+
+	// 删除
+	err = b.PutBlob("deleted", &BlobData{Data: bytes.NewReader([]byte("x"))})
+	if err != nil {
+		t.Fatalf("PutBlob err: %s", err)
+	}
+	if err := b.DeleteBlob("deleted"); err != nil {
+		t.Fatalf("DeleteBlob err: %s", err)
+	}
+	data, err = b.GetBlob("deleted")
+	if err != nil {
+		t.Fatalf("GetBlob deleted err: %s", err)
+	}
+	if data != nil {
+		data.Close()
+		t.Fatal("GetBlob deleted should be nil")
+	}
+	if err := b.DeleteBlob("deleted"); err != nil {
+		t.Fatalf("DeleteBlob missing err: %s", err)
+	}
+}
+
+func testBackendInfra(t *testing.T, b Backend) {
+	lookup := Lookup{Infra: "foo"}
+
+	// 不存在的数据
+	actual, err := b.GetInfra(&Infra{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("GetInfra missing err: %s", err)
+	}
+	if actual != nil {
+		t.Fatalf("GetInfra missing should be nil: %#v", actual)
+	}
+
+	infra := &Infra{
+		Lookup: lookup,
+		State:  InfraStateReady,
+	}
+	infra.SetOutputs(Outputs{
+		"foo":     StringOutput("bar"),
+		"subnets": ListOutput([]string{"subnet-1", "subnet-2"}),
+		"zones":   MapOutput(map[string]string{"a": "us-east-1a"}),
+	})
+	if err := b.PutInfra(infra); err != nil {
+		t.Fatalf("PutInfra err: %s", err)
+	}
+	if infra.ID == "" {
+		t.Fatal("PutInfra should set ID")
+	}
+
+	actual, err = b.GetInfra(&Infra{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("GetInfra err: %s", err)
+	}
+	if !reflect.DeepEqual(actual, infra) {
+		t.Fatalf("GetInfra bad: %#v", actual)
+	}
+
+	// foundation的数据和infrastructure的数据是分开的
+	foundation := &Infra{
+		Lookup: Lookup{Infra: "foo", Foundation: "consul"},
+		State:  InfraStatePartial,
+	}
+	if err := b.PutInfra(foundation); err != nil {
+		t.Fatalf("PutInfra foundation err: %s", err)
+	}
+	actual, err = b.GetInfra(&Infra{Lookup: foundation.Lookup})
+	if err != nil {
+		t.Fatalf("GetInfra foundation err: %s", err)
+	}
+	if !reflect.DeepEqual(actual, foundation) {
+		t.Fatalf("GetInfra foundation bad: %#v", actual)
+	}
+	actual, err = b.GetInfra(&Infra{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("GetInfra err: %s", err)
+	}
+	if !reflect.DeepEqual(actual, infra) {
+		t.Fatalf("GetInfra after foundation bad: %#v", actual)
+	}
+
+	// 更新时保留ID
+	infra.State = InfraStatePartial
+	id := infra.ID
+	if err := b.PutInfra(infra); err != nil {
+		t.Fatalf("PutInfra update err: %s", err)
+	}
+	if infra.ID != id {
+		t.Fatalf("PutInfra update changed ID: %s", infra.ID)
+	}
+	actual, err = b.GetInfra(&Infra{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("GetInfra update err: %s", err)
+	}
+	if !reflect.DeepEqual(actual, infra) {
+		t.Fatalf("GetInfra update bad: %#v", actual)
+	}
+}
+
+func testBackendBuild(t *testing.T, b Backend) {
+	lookup := Lookup{AppID: "foo", Infra: "bar", InfraFlavor: "baz"}
+
+	// 不存在的数据
+	actual, err := b.GetBuild(&Build{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("GetBuild missing err: %s", err)
+	}
+	if actual != nil {
+		t.Fatalf("GetBuild missing should be nil: %#v", actual)
+	}
+
+	build := &Build{Lookup: lookup, State: BuildStateSuccess}
+	if err := b.PutBuild(build); err != nil {
+		t.Fatalf("PutBuild err: %s", err)
+	}
+	if build.ID == "" {
+		t.Fatal("PutBuild should set ID")
+	}
+
+	actual, err = b.GetBuild(&Build{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("GetBuild err: %s", err)
+	}
+	if actual == nil || actual.ID != build.ID || !actual.IsSuccessful() {
+		t.Fatalf("GetBuild bad: %#v", actual)
+	}
+
+	// 不同的flavor是不同的数据
+	other := lookup
+	other.InfraFlavor = "other"
+	actual, err = b.GetBuild(&Build{Lookup: other})
+	if err != nil {
+		t.Fatalf("GetBuild other err: %s", err)
+	}
+	if actual != nil {
+		t.Fatalf("GetBuild other should be nil: %#v", actual)
+	}
+}
+
+func testBackendDeploy(t *testing.T, b Backend) {
+	lookup := Lookup{AppID: "foo", Infra: "bar", InfraFlavor: "baz"}
+
+	// 不存在的数据
+	actual, err := b.GetDeploy(&Deploy{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("GetDeploy missing err: %s", err)
+	}
+	if actual != nil {
+		t.Fatalf("GetDeploy missing should be nil: %#v", actual)
+	}
+
+	deploy := &Deploy{Lookup: lookup, State: DeployStateNew}
+	if err := b.PutDeploy(deploy); err != nil {
+		t.Fatalf("PutDeploy err: %s", err)
+	}
+	if deploy.ID == "" {
+		t.Fatal("PutDeploy should set ID")
+	}
+
+	deploy.MarkSuccessful()
+	if err := b.PutDeploy(deploy); err != nil {
+		t.Fatalf("PutDeploy update err: %s", err)
+	}
+
+	actual, err = b.GetDeploy(&Deploy{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("GetDeploy err: %s", err)
+	}
+	if !reflect.DeepEqual(actual, deploy) {
+		t.Fatalf("GetDeploy bad: %#v", actual)
+	}
+}
+
+// testBackendList 检查前面的测试写入的数据都能列出来
+func testBackendList(t *testing.T, b Lister) {
+	blobs, err := b.ListBlobs()
+	if err != nil {
+		t.Fatalf("ListBlobs err: %s", err)
+	}
+	sort.Strings(blobs)
+	if !reflect.DeepEqual(blobs, []string{"bar/baz", "foo"}) {
+		t.Fatalf("ListBlobs bad: %#v", blobs)
+	}
+
+	infras, err := b.ListInfra()
+	if err != nil {
+		t.Fatalf("ListInfra err: %s", err)
+	}
+	var infraKeys []string
+	for _, infra := range infras {
+		infraKeys = append(infraKeys, infraKey(&infra.Lookup))
+	}
+	sort.Strings(infraKeys)
+	if !reflect.DeepEqual(infraKeys, []string{"foo", "foo/consul"}) {
+		t.Fatalf("ListInfra bad: %#v", infraKeys)
+	}
+
+	builds, err := b.ListBuilds()
+	if err != nil {
+		t.Fatalf("ListBuilds err: %s", err)
+	}
+	if len(builds) != 1 || builds[0].AppID != "foo" || builds[0].ID == "" {
+		t.Fatalf("ListBuilds bad: %#v", builds)
+	}
+
+	deploys, err := b.ListDeploys()
+	if err != nil {
+		t.Fatalf("ListDeploys err: %s", err)
+	}
+	if len(deploys) != 1 || !deploys[0].IsDeployed() {
+		t.Fatalf("ListDeploys bad: %#v", deploys)
+	}
+}
+
+func testBackendLock(t *testing.T, b Locker) {
+	// 没有锁
+	current, err := b.GetLock("state")
+	if err != nil {
+		t.Fatalf("GetLock missing err: %s", err)
+	}
+	if current != nil {
+		t.Fatalf("GetLock missing should be nil: %#v", current)
+	}
+	if err := b.Unlock(NewLock("state", "test")); err != nil {
+		t.Fatalf("Unlock missing err: %s", err)
+	}
+
+	l1 := NewLock("state", "apply")
+	if err := b.Lock(l1); err != nil {
+		t.Fatalf("Lock err: %s", err)
+	}
+	if l1.Created.IsZero() || !l1.Expires.After(l1.Created) {
+		t.Fatalf("Lock should set times: %#v", l1)
+	}
+
+	current, err = b.GetLock("state")
+	if err != nil {
+		t.Fatalf("GetLock err: %s", err)
+	}
+	if current == nil || current.ID != l1.ID || current.Holder != l1.Holder {
+		t.Fatalf("GetLock bad: %#v", current)
+	}
+
+	// 其它人不能获取和释放
+	l2 := NewLock("state", "destroy")
+	err = b.Lock(l2)
+	lerr, ok := err.(*LockedError)
+	if !ok {
+		t.Fatalf("Lock held should be LockedError: %#v", err)
+	}
+	if lerr.Lock.ID != l1.ID || lerr.Lock.Operation != "apply" {
+		t.Fatalf("LockedError bad: %#v", lerr.Lock)
+	}
+	if _, ok := b.Unlock(l2).(*LockedError); !ok {
+		t.Fatal("Unlock held should be LockedError")
+	}
+
+	// 其它key不受影响
+	other := NewLock("other", "apply")
+	if err := b.Lock(other); err != nil {
+		t.Fatalf("Lock other err: %s", err)
+	}
+
+	// 刷新保留开始时间
+	created := l1.Created
+	if err := b.Lock(l1); err != nil {
+		t.Fatalf("Lock refresh err: %s", err)
+	}
+	if !l1.Created.Equal(created) {
+		t.Fatalf("Lock refresh changed Created: %s", l1.Created)
+	}
+
+	// 释放之后其它人可以获取
+	if err := b.Unlock(l1); err != nil {
+		t.Fatalf("Unlock err: %s", err)
+	}
+	if current, err := b.GetLock("state"); err != nil || current != nil {
+		t.Fatalf("GetLock after Unlock: %#v %v", current, err)
+	}
+	if err := b.Lock(l2); err != nil {
+		t.Fatalf("Lock after Unlock err: %s", err)
+	}
+
+	// 强制释放
+	if err := b.ForceUnlock("state"); err != nil {
+		t.Fatalf("ForceUnlock err: %s", err)
+	}
+	if err := b.Lock(l1); err != nil {
+		t.Fatalf("Lock after ForceUnlock err: %s", err)
+	}
+	if err := b.Unlock(l1); err != nil {
+		t.Fatalf("Unlock err: %s", err)
+	}
+
+	// 过期的锁可以被其它人获取
+	expired := NewLock("expired", "apply")
+	expired.TTL = time.Millisecond
+	if err := b.Lock(expired); err != nil {
+		t.Fatalf("Lock expired err: %s", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if current, err := b.GetLock("expired"); err != nil || current != nil {
+		t.Fatalf("GetLock expired: %#v %v", current, err)
+	}
+	if err := b.Lock(NewLock("expired", "apply")); err != nil {
+		t.Fatalf("Lock over expired err: %s", err)
+	}
+
+	if err := b.ForceUnlock("other"); err != nil {
+		t.Fatalf("ForceUnlock err: %s", err)
+	}
+	if err := b.ForceUnlock("expired"); err != nil {
+		t.Fatalf("ForceUnlock err: %s", err)
+	}
+}
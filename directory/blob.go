@@ -0,0 +1,67 @@
+package directory
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// BlobData 是保存的二进制数据和它的元数据。不同操作中
+// 字段的用途不一样，使用前请仔细阅读文档
+type BlobData struct {
+	// Key 是数据的key。只在读取时填充，其它操作忽略
+	Key string
+
+	// Data 是数据本身。写入时是要写入的数据，读取时是
+	// 读到的数据
+	Data io.Reader
+
+	closer io.Closer
+}
+
+// Close 必须在读取完数据之后调用
+func (d *BlobData) Close() error {
+	if d.closer != nil {
+		return d.closer.Close()
+	}
+
+	return nil
+}
+
+// WriteToFile 把BlobData写入文件。虽然很简单，但是很常用，
+// 所以提供这个辅助函数
+func (d *BlobData) WriteToFile(path string) error {
+	// 确保目录存在
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, d.Data)
+	return err
+}
+
+// validateBlobKey 检查key是否可以安全地用作目录中的相对路径。key可以
+// 用 "/" 分层，但是不能是绝对路径，不能有 "\"，也不能有空的、"." 或者
+// ".." 的部分，这样key就不会指向目录之外的文件
+func validateBlobKey(key string) error {
+	if key == "" || strings.Contains(key, "\\") ||
+		filepath.IsAbs(key) || filepath.VolumeName(key) != "" {
+		return i18n.Errorf("directory.blob_key_err", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return i18n.Errorf("directory.blob_key_err", key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,414 @@
+package directory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/kuuyee/otto-learn/helper/uuid"
+)
+
+var (
+	boltInfraBucket  = []byte("infra")
+	boltBuildBucket  = []byte("build")
+	boltDeployBucket = []byte("deploy")
+	boltLockBucket   = []byte("lock")
+	boltBuckets      = [][]byte{
+		boltInfraBucket,
+		boltBuildBucket,
+		boltDeployBucket,
+		boltLockBucket,
+	}
+)
+
+// BoltBackend 是用BoltDB把数据保存在本地磁盘的目录后端
+//
+// BoltBackend 主要用在开箱即用和单个开发者的场景，不推荐
+// 团队使用
+type BoltBackend struct {
+	// Dir 是数据写入的目录，如果不存在会被创建
+	Dir string
+}
+
+func (b *BoltBackend) PutBlob(key string, data *BlobData) error {
+	// 无论如何都要关闭数据，避免泄露资源
+	defer data.Close()
+
+	if err := validateBlobKey(key); err != nil {
+		return err
+	}
+
+	path := filepath.Join(b.blobDir(), key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, data.Data)
+	return err
+}
+
+func (b *BoltBackend) GetBlob(key string) (*BlobData, error) {
+	if err := validateBlobKey(key); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(b.blobDir(), key)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlobData{
+		Key:    key,
+		Data:   f,
+		closer: f,
+	}, nil
+}
+
+func (b *BoltBackend) DeleteBlob(key string) error {
+	if err := validateBlobKey(key); err != nil {
+		return err
+	}
+
+	err := os.Remove(filepath.Join(b.blobDir(), key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (b *BoltBackend) PutInfra(infra *Infra) error {
+	if infra.ID == "" {
+		infra.ID = uuid.GenerateUUID()
+	}
+
+	return b.put(boltInfraBucket, infraKey(&infra.Lookup), infra)
+}
+
+func (b *BoltBackend) GetInfra(infra *Infra) (*Infra, error) {
+	var result Infra
+	ok, err := b.get(boltInfraBucket, infraKey(&infra.Lookup), &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *BoltBackend) PutBuild(build *Build) error {
+	if build.ID == "" {
+		build.ID = uuid.GenerateUUID()
+	}
+
+	return b.put(boltBuildBucket, appKey(&build.Lookup), build)
+}
+
+func (b *BoltBackend) GetBuild(build *Build) (*Build, error) {
+	var result Build
+	ok, err := b.get(boltBuildBucket, appKey(&build.Lookup), &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *BoltBackend) PutDeploy(deploy *Deploy) error {
+	if deploy.ID == "" {
+		deploy.ID = uuid.GenerateUUID()
+	}
+
+	return b.put(boltDeployBucket, appKey(&deploy.Lookup), deploy)
+}
+
+func (b *BoltBackend) GetDeploy(deploy *Deploy) (*Deploy, error) {
+	var result Deploy
+	ok, err := b.get(boltDeployBucket, appKey(&deploy.Lookup), &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *BoltBackend) ListBlobs() ([]string, error) {
+	var result []string
+	root := b.blobDir()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		key, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		result = append(result, filepath.ToSlash(key))
+		return nil
+	})
+
+	return result, err
+}
+
+func (b *BoltBackend) ListInfra() ([]*Infra, error) {
+	var result []*Infra
+	err := b.list(boltInfraBucket, func(raw []byte) error {
+		var v Infra
+		if err := b.structRead(&v, raw); err != nil {
+			return err
+		}
+
+		result = append(result, &v)
+		return nil
+	})
+
+	return result, err
+}
+
+func (b *BoltBackend) ListBuilds() ([]*Build, error) {
+	var result []*Build
+	err := b.list(boltBuildBucket, func(raw []byte) error {
+		var v Build
+		if err := b.structRead(&v, raw); err != nil {
+			return err
+		}
+
+		result = append(result, &v)
+		return nil
+	})
+
+	return result, err
+}
+
+func (b *BoltBackend) ListDeploys() ([]*Deploy, error) {
+	var result []*Deploy
+	err := b.list(boltDeployBucket, func(raw []byte) error {
+		var v Deploy
+		if err := b.structRead(&v, raw); err != nil {
+			return err
+		}
+
+		result = append(result, &v)
+		return nil
+	})
+
+	return result, err
+}
+
+func (b *BoltBackend) Lock(l *Lock) error {
+	return b.lockUpdate(l.Key, func(current *Lock) (*Lock, error) {
+		if err := lockAcquire(current, l, time.Now()); err != nil {
+			return nil, err
+		}
+
+		return l, nil
+	})
+}
+
+func (b *BoltBackend) Unlock(l *Lock) error {
+	return b.lockUpdate(l.Key, func(current *Lock) (*Lock, error) {
+		remove, err := lockRelease(current, l, time.Now())
+		if err != nil || !remove {
+			return current, err
+		}
+
+		return nil, nil
+	})
+}
+
+func (b *BoltBackend) GetLock(key string) (*Lock, error) {
+	var result Lock
+	ok, err := b.get(boltLockBucket, key, &result)
+	if !ok || err != nil || result.Expired(time.Now()) {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *BoltBackend) ForceUnlock(key string) error {
+	return b.lockUpdate(key, func(*Lock) (*Lock, error) {
+		return nil, nil
+	})
+}
+
+// lockUpdate 在一个事务中读取key当前的锁，用f返回的锁替换它。
+// f返回nil时删除锁
+func (b *BoltBackend) lockUpdate(key string, f func(*Lock) (*Lock, error)) error {
+	db, err := b.db()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(boltLockBucket)
+		if bkt == nil {
+			return fmt.Errorf("bucket没有找到: %s", boltLockBucket)
+		}
+
+		var current *Lock
+		if raw := bkt.Get([]byte(key)); raw != nil {
+			current = new(Lock)
+			if err := b.structRead(current, raw); err != nil {
+				return err
+			}
+		}
+
+		l, err := f(current)
+		if err != nil {
+			return err
+		}
+		if l == nil {
+			return bkt.Delete([]byte(key))
+		}
+
+		data, err := b.structData(l)
+		if err != nil {
+			return err
+		}
+
+		return bkt.Put([]byte(key), data)
+	})
+}
+
+// put 把v编码后用key保存到bucket中
+func (b *BoltBackend) put(bucket []byte, key string, v interface{}) error {
+	db, err := b.db()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	data, err := b.structData(v)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(bucket)
+		if bkt == nil {
+			return fmt.Errorf("bucket没有找到: %s", bucket)
+		}
+
+		return bkt.Put([]byte(key), data)
+	})
+}
+
+// get 从bucket中读取key并解码到v。如果key不存在返回false
+func (b *BoltBackend) get(bucket []byte, key string, v interface{}) (bool, error) {
+	db, err := b.db()
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	found := false
+	err = db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(bucket)
+		if bkt == nil {
+			return fmt.Errorf("bucket没有找到: %s", bucket)
+		}
+
+		data := bkt.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+
+		found = true
+		return b.structRead(v, data)
+	})
+
+	return found, err
+}
+
+// list 对bucket中的每个值调用f
+func (b *BoltBackend) list(bucket []byte, f func([]byte) error) error {
+	db, err := b.db()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(bucket)
+		if bkt == nil {
+			return fmt.Errorf("bucket没有找到: %s", bucket)
+		}
+
+		return bkt.ForEach(func(k, v []byte) error {
+			return f(v)
+		})
+	})
+}
+
+func (b *BoltBackend) db() (*bolt.DB, error) {
+	if err := os.MkdirAll(b.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(b.Dir, "otto.db")
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建需要的bucket
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range boltBuckets {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (b *BoltBackend) blobDir() string {
+	return filepath.Join(b.Dir, "blob")
+}
+
+func (b *BoltBackend) structData(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (b *BoltBackend) structRead(v interface{}, raw []byte) error {
+	return json.NewDecoder(bytes.NewReader(raw)).Decode(v)
+}
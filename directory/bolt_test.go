@@ -0,0 +1,61 @@
+package directory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBoltBackend_impl(t *testing.T) {
+	var _ Backend = new(BoltBackend)
+}
+
+func TestBoltBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	TestBackend(t, &BoltBackend{Dir: dir})
+}
+
+func TestBoltBackend_blobKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := &BoltBackend{Dir: filepath.Join(dir, "directory")}
+	for _, key := range []string{
+		"../pwned",
+		"../../pwned",
+		"foo/../../../pwned",
+		"/tmp/pwned",
+		"foo//bar",
+		"./foo",
+		"foo\\..\\..\\pwned",
+	} {
+		if err := b.PutBlob(key, &BlobData{Data: strings.NewReader("x")}); err == nil {
+			t.Fatalf("PutBlob %q should error", key)
+		}
+		if _, err := b.GetBlob(key); err == nil {
+			t.Fatalf("GetBlob %q should error", key)
+		}
+		if err := b.DeleteBlob(key); err == nil {
+			t.Fatalf("DeleteBlob %q should error", key)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); !os.IsNotExist(err) {
+		t.Fatalf("file written outside the directory: %v", err)
+	}
+
+	// 分层的key是可以的
+	if err := b.PutBlob("foo/bar", &BlobData{Data: strings.NewReader("x")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,34 @@
+package directory
+
+import (
+	"time"
+)
+
+// BuildState 是构建的状态
+type BuildState byte
+
+const (
+	BuildStateInvalid BuildState = 0
+	BuildStateFail    BuildState = iota
+	BuildStateSuccess
+)
+
+// Build 表示一个应用最后一次的构建
+type Build struct {
+	// Lookup 是查询信息。AppID、Infra和InfraFlavor是必须的
+	Lookup
+
+	// State 是构建的状态，Time 是构建完成的时间
+	State BuildState
+	Time  time.Time
+
+	// 私有字段，在Get或Put时设置
+	//
+	// 不要修改
+	ID string
+}
+
+// IsSuccessful 返回构建是否成功
+func (b *Build) IsSuccessful() bool {
+	return b != nil && b.State == BuildStateSuccess
+}
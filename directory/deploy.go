@@ -0,0 +1,58 @@
+package directory
+
+// DeployState 是部署的状态
+//
+// 部署在真正执行之前就会保存到目录中，以便部署失败时
+// 仍然能找到和部署关联的数据
+type DeployState byte
+
+const (
+	DeployStateInvalid DeployState = 0
+	DeployStateNew     DeployState = iota
+	DeployStateFail
+	DeployStateSuccess
+)
+
+// Deploy 表示一个应用的部署
+type Deploy struct {
+	// Lookup 是查询信息。AppID、Infra和InfraFlavor是必须的
+	Lookup
+
+	// State 是部署的状态
+	State DeployState
+
+	// 私有字段，在Get或Put时设置
+	//
+	// 不要修改
+	ID string
+}
+
+// IsNew 返回这个部署是否是新的，还没有部署过
+func (d *Deploy) IsNew() bool {
+	return d == nil || d.State == DeployStateNew
+}
+
+// IsFailed 返回最后一次部署是否失败
+func (d *Deploy) IsFailed() bool {
+	return d != nil && d.State == DeployStateFail
+}
+
+// IsDeployed 返回应用是否部署成功
+func (d *Deploy) IsDeployed() bool {
+	return d != nil && d.State == DeployStateSuccess
+}
+
+// MarkFailed 标记部署失败
+func (d *Deploy) MarkFailed() {
+	d.State = DeployStateFail
+}
+
+// MarkSuccessful 标记部署成功
+func (d *Deploy) MarkSuccessful() {
+	d.State = DeployStateSuccess
+}
+
+// MarkGone 在销毁部署之后把部署重置为新的状态
+func (d *Deploy) MarkGone() {
+	d.State = DeployStateNew
+}
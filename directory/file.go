@@ -0,0 +1,435 @@
+package directory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/kuuyee/otto-learn/helper/uuid"
+)
+
+const (
+	fileInfraDir  = "infra"
+	fileBuildDir  = "build"
+	fileDeployDir = "deploy"
+	fileBlobDir   = "blob"
+	fileLockDir   = "lock"
+	fileLockName  = ".lock"
+
+	// fileTempPrefix 是写入时临时文件的前缀
+	fileTempPrefix = ".tmp-"
+
+	// fileLockTimeout 是等待其它进程释放锁的最长时间，和
+	// BoltBackend打开数据库的超时一样
+	fileLockTimeout = 5 * time.Second
+)
+
+// errFileLocked 是lockFile在锁文件已经被其它进程锁住时返回的错误
+var errFileLocked = errors.New("file is locked")
+
+// FileBackend 是把数据保存成普通JSON文件的目录后端。每条记录
+// 是一个文件，可以直接查看，也可以放在共享的磁盘上
+//
+// 所有的写入都先写到临时文件，然后rename到最终的位置，所以读取
+// 的时候不会看到写了一半的文件。写入时用Dir中的锁文件保证同一
+// 时间只有一个进程在写
+type FileBackend struct {
+	// Dir 是数据写入的目录，如果不存在会被创建
+	Dir string
+}
+
+func (b *FileBackend) PutBlob(key string, data *BlobData) error {
+	// 无论如何都要关闭数据，避免泄露资源
+	defer data.Close()
+
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return b.writeAtomic(b.path(fileBlobDir, key, ""), data.Data)
+}
+
+func (b *FileBackend) GetBlob(key string) (*BlobData, error) {
+	f, err := os.Open(b.path(fileBlobDir, key, ""))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &BlobData{
+		Key:    key,
+		Data:   f,
+		closer: f,
+	}, nil
+}
+
+func (b *FileBackend) DeleteBlob(key string) error {
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = os.Remove(b.path(fileBlobDir, key, ""))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (b *FileBackend) PutInfra(infra *Infra) error {
+	if infra.ID == "" {
+		infra.ID = uuid.GenerateUUID()
+	}
+
+	return b.put(fileInfraDir, infraKey(&infra.Lookup), infra)
+}
+
+func (b *FileBackend) GetInfra(infra *Infra) (*Infra, error) {
+	var result Infra
+	ok, err := b.get(fileInfraDir, infraKey(&infra.Lookup), &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *FileBackend) PutBuild(build *Build) error {
+	if build.ID == "" {
+		build.ID = uuid.GenerateUUID()
+	}
+
+	return b.put(fileBuildDir, appKey(&build.Lookup), build)
+}
+
+func (b *FileBackend) GetBuild(build *Build) (*Build, error) {
+	var result Build
+	ok, err := b.get(fileBuildDir, appKey(&build.Lookup), &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *FileBackend) PutDeploy(deploy *Deploy) error {
+	if deploy.ID == "" {
+		deploy.ID = uuid.GenerateUUID()
+	}
+
+	return b.put(fileDeployDir, appKey(&deploy.Lookup), deploy)
+}
+
+func (b *FileBackend) GetDeploy(deploy *Deploy) (*Deploy, error) {
+	var result Deploy
+	ok, err := b.get(fileDeployDir, appKey(&deploy.Lookup), &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *FileBackend) ListBlobs() ([]string, error) {
+	return b.list(fileBlobDir, "")
+}
+
+func (b *FileBackend) ListInfra() ([]*Infra, error) {
+	keys, err := b.list(fileInfraDir, ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Infra, 0, len(keys))
+	for _, k := range keys {
+		var v Infra
+		if _, err := b.get(fileInfraDir, k, &v); err != nil {
+			return nil, err
+		}
+
+		result = append(result, &v)
+	}
+
+	return result, nil
+}
+
+func (b *FileBackend) ListBuilds() ([]*Build, error) {
+	keys, err := b.list(fileBuildDir, ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Build, 0, len(keys))
+	for _, k := range keys {
+		var v Build
+		if _, err := b.get(fileBuildDir, k, &v); err != nil {
+			return nil, err
+		}
+
+		result = append(result, &v)
+	}
+
+	return result, nil
+}
+
+func (b *FileBackend) ListDeploys() ([]*Deploy, error) {
+	keys, err := b.list(fileDeployDir, ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Deploy, 0, len(keys))
+	for _, k := range keys {
+		var v Deploy
+		if _, err := b.get(fileDeployDir, k, &v); err != nil {
+			return nil, err
+		}
+
+		result = append(result, &v)
+	}
+
+	return result, nil
+}
+
+func (b *FileBackend) Lock(l *Lock) error {
+	return b.lockUpdate(l.Key, func(current *Lock) (*Lock, error) {
+		if err := lockAcquire(current, l, time.Now()); err != nil {
+			return nil, err
+		}
+
+		return l, nil
+	})
+}
+
+func (b *FileBackend) Unlock(l *Lock) error {
+	return b.lockUpdate(l.Key, func(current *Lock) (*Lock, error) {
+		remove, err := lockRelease(current, l, time.Now())
+		if err != nil || !remove {
+			return current, err
+		}
+
+		return nil, nil
+	})
+}
+
+func (b *FileBackend) GetLock(key string) (*Lock, error) {
+	var result Lock
+	ok, err := b.get(fileLockDir, key, &result)
+	if !ok || err != nil || result.Expired(time.Now()) {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *FileBackend) ForceUnlock(key string) error {
+	return b.lockUpdate(key, func(*Lock) (*Lock, error) {
+		return nil, nil
+	})
+}
+
+// lockUpdate 持有写锁读取key当前的锁，用f返回的锁替换它。
+// f返回nil时删除锁
+func (b *FileBackend) lockUpdate(key string, f func(*Lock) (*Lock, error)) error {
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var current *Lock
+	var existing Lock
+	ok, err := b.get(fileLockDir, key, &existing)
+	if err != nil {
+		return err
+	}
+	if ok {
+		current = &existing
+	}
+
+	l, err := f(current)
+	if err != nil {
+		return err
+	}
+
+	path := b.path(fileLockDir, key, ".json")
+	if l == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	data, err := json.MarshalIndent(l, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return b.writeAtomic(path, bytes.NewReader(data))
+}
+
+// list 返回dir中所有扩展名是ext的key，跳过写了一半的临时文件
+func (b *FileBackend) list(dir, ext string) ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(b.Dir, dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	result := make([]string, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || strings.HasPrefix(name, fileTempPrefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		key, err := url.PathUnescape(strings.TrimSuffix(name, ext))
+		if err != nil {
+			return nil, fmt.Errorf("%s 中的文件名不正确: %s", dir, name)
+		}
+
+		result = append(result, key)
+	}
+
+	return result, nil
+}
+
+// put 把v编码成JSON，用key保存到dir中
+func (b *FileBackend) put(dir, key string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return b.writeAtomic(b.path(dir, key, ".json"), bytes.NewReader(data))
+}
+
+// get 读取dir中的key并解码到v。如果key不存在返回false
+func (b *FileBackend) get(dir, key string, v interface{}) (bool, error) {
+	f, err := os.Open(b.path(dir, key, ".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return false, fmt.Errorf("解析 %s 报错: %s", f.Name(), err)
+	}
+
+	return true, nil
+}
+
+// path 返回key在dir中的文件路径。key会被转义，所以每个key都是
+// dir中的一个文件
+func (b *FileBackend) path(dir, key, ext string) string {
+	return filepath.Join(b.Dir, dir, url.PathEscape(key)+ext)
+}
+
+// writeAtomic 先把数据写到同一个目录的临时文件中，然后rename到
+// path。rename在同一个文件系统中是原子的
+func (b *FileBackend) writeAtomic(path string, r io.Reader) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, fileTempPrefix)
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// lock 获取Dir的写锁，返回释放锁的函数。锁是Dir中锁文件上的系统
+// 文件锁，持有锁的进程退出或崩溃时由系统释放，所以不会留下永远锁住
+// 的目录。如果其它进程持有锁，最多等待fileLockTimeout
+func (b *FileBackend) lock() (func(), error) {
+	if err := os.MkdirAll(b.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(b.Dir, fileLockName)
+	deadline := time.Now().Add(fileLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		err = lockFile(f)
+		if err == nil {
+			// 释放锁的时候会删除锁文件，所以锁住的可能是已经删除的
+			// 文件。只有锁住的还是path上的文件才算拿到了锁
+			if fi, err := f.Stat(); err == nil {
+				if pi, err := os.Stat(path); err == nil && os.SameFile(fi, pi) {
+					fmt.Fprintf(f, "%d\n", os.Getpid())
+					return func() {
+						// 先删除再解锁，等待的进程解锁之后会发现文件已经删除
+						os.Remove(path)
+						unlockFile(f)
+						f.Close()
+					}, nil
+				}
+			}
+
+			unlockFile(f)
+		}
+		f.Close()
+		if err != nil && err != errFileLocked {
+			return nil, err
+		}
+
+		if time.Now().After(deadline) {
+			return nil, i18n.Errorf("directory.lock_timeout", path)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,23 @@
+// +build !windows
+
+package directory
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile 不等待地获取f上的独占flock，其它进程持有锁时返回
+// errFileLocked。进程退出时系统会释放flock
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errFileLocked
+	}
+
+	return err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
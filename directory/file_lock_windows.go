@@ -0,0 +1,51 @@
+package directory
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+)
+
+// lockFile 不等待地用LockFileEx锁住f的第一个字节，其它进程持有锁时
+// 返回errFileLocked。进程退出时系统会释放锁
+func lockFile(f *os.File) error {
+	var ol syscall.Overlapped
+	r, _, err := procLockFileEx.Call(
+		f.Fd(),
+		lockfileExclusiveLock|lockfileFailImmediately,
+		0, 1, 0,
+		uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		if err == errorLockViolation {
+			return errFileLocked
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	var ol syscall.Overlapped
+	r, _, err := procUnlockFileEx.Call(
+		f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package directory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileBackend_impl(t *testing.T) {
+	var _ Backend = new(FileBackend)
+}
+
+func TestFileBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	TestBackend(t, &FileBackend{Dir: dir})
+
+	// 写入之后不应该留下锁文件和临时文件
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := info.Name()
+		if name == fileLockName || strings.HasPrefix(name, fileTempPrefix) {
+			t.Errorf("leftover file: %s", path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestFileBackend_lockWait(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := &FileBackend{Dir: dir}
+	unlock, err := b.lock()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// 其它写入要等锁释放之后才能完成
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- b.PutInfra(&Infra{Lookup: Lookup{Infra: "foo"}})
+	}()
+
+	select {
+	case err := <-doneCh:
+		t.Fatalf("PutInfra should wait for lock: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	unlock()
+	if err := <-doneCh; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestFileBackend_lockStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 模拟崩溃的进程: 锁住锁文件之后没有解锁和删除就退出了，退出时
+	// 系统关闭文件并释放锁
+	f, err := os.OpenFile(filepath.Join(dir, fileLockName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := lockFile(f); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f.WriteString("999999\n")
+	f.Close()
+
+	// 留下的锁文件不会阻止写入
+	b := &FileBackend{Dir: dir}
+	start := time.Now()
+	if err := b.PutInfra(&Infra{Lookup: Lookup{Infra: "foo"}}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("should not wait for the stale lock")
+	}
+}
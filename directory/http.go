@@ -0,0 +1,295 @@
+package directory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// HTTPBackend 是通过HTTP API访问远程目录的后端。服务端是
+// HTTPHandler，通常由 `otto directory serve` 运行
+//
+// 团队成员使用同一个服务端时可以共享infrastructure数据和
+// Terraform状态
+type HTTPBackend struct {
+	// Address 是服务端的地址，比如 "http://127.0.0.1:8300"
+	Address string
+
+	// Client 是使用的HTTP客户端，为nil时使用http.DefaultClient
+	Client *http.Client
+}
+
+func (b *HTTPBackend) PutBlob(key string, data *BlobData) error {
+	// 无论如何都要关闭数据，避免泄露资源
+	defer data.Close()
+
+	resp, err := b.do("PUT", b.blobURL(key), data.Data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (b *HTTPBackend) GetBlob(key string) (*BlobData, error) {
+	resp, err := b.do("GET", b.blobURL(key), nil)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+
+	return &BlobData{
+		Key:    key,
+		Data:   resp.Body,
+		closer: resp.Body,
+	}, nil
+}
+
+func (b *HTTPBackend) DeleteBlob(key string) error {
+	resp, err := b.do("DELETE", b.blobURL(key), nil)
+	if err != nil || resp == nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (b *HTTPBackend) PutInfra(infra *Infra) error {
+	return b.put(httpInfraPath, infra)
+}
+
+func (b *HTTPBackend) GetInfra(infra *Infra) (*Infra, error) {
+	var result Infra
+	ok, err := b.get(httpInfraPath, &infra.Lookup, &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *HTTPBackend) PutBuild(build *Build) error {
+	return b.put(httpBuildPath, build)
+}
+
+func (b *HTTPBackend) GetBuild(build *Build) (*Build, error) {
+	var result Build
+	ok, err := b.get(httpBuildPath, &build.Lookup, &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *HTTPBackend) PutDeploy(deploy *Deploy) error {
+	return b.put(httpDeployPath, deploy)
+}
+
+func (b *HTTPBackend) GetDeploy(deploy *Deploy) (*Deploy, error) {
+	var result Deploy
+	ok, err := b.get(httpDeployPath, &deploy.Lookup, &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *HTTPBackend) ListBlobs() ([]string, error) {
+	var result []string
+	if err := b.list("blob", &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (b *HTTPBackend) ListInfra() ([]*Infra, error) {
+	var result []*Infra
+	if err := b.list("infra", &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (b *HTTPBackend) ListBuilds() ([]*Build, error) {
+	var result []*Build
+	if err := b.list("build", &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (b *HTTPBackend) ListDeploys() ([]*Deploy, error) {
+	var result []*Deploy
+	if err := b.list("deploy", &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (b *HTTPBackend) Lock(l *Lock) error {
+	return b.put(httpLockPath+url.PathEscape(l.Key), l)
+}
+
+func (b *HTTPBackend) Unlock(l *Lock) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.do("DELETE", b.lockURL(l.Key, nil), bytes.NewReader(data))
+	if err != nil || resp == nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (b *HTTPBackend) GetLock(key string) (*Lock, error) {
+	resp, err := b.do("GET", b.lockURL(key, nil), nil)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result Lock
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析目录服务器的响应报错: %s", err)
+	}
+
+	return &result, nil
+}
+
+func (b *HTTPBackend) ForceUnlock(key string) error {
+	resp, err := b.do("DELETE", b.lockURL(key, url.Values{"force": []string{"1"}}), nil)
+	if err != nil || resp == nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// list 读取服务端某种数据的列表并解码到v
+func (b *HTTPBackend) list(kind string, v interface{}) error {
+	resp, err := b.do("GET", b.url(httpListPath+kind, nil), nil)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return fmt.Errorf("目录服务器不支持列出数据: %s", kind)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("解析目录服务器的响应报错: %s", err)
+	}
+
+	return nil
+}
+
+// put 把v发送给服务端保存，然后用服务端返回的数据更新v，
+// 这样服务端分配的ID会设置到v中
+func (b *HTTPBackend) put(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.do("PUT", b.url(path, nil), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// get 用lookup查询数据并解码到v。如果数据不存在返回false
+func (b *HTTPBackend) get(path string, l *Lookup, v interface{}) (bool, error) {
+	resp, err := b.do("GET", b.url(path, lookupQuery(l)), nil)
+	if err != nil || resp == nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("解析目录服务器的响应报错: %s", err)
+	}
+
+	return true, nil
+}
+
+// do 发送请求。GET和DELETE时服务端返回404表示数据不存在，返回nil
+// 的响应和nil的错误。PUT和POST的404说明地址不是目录服务器，返回错误。
+// 其它非2xx的响应转换成错误
+func (b *HTTPBackend) do(method, u string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("访问目录服务器 %s 报错: %s", b.Address, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		if method == "PUT" || method == "POST" {
+			return nil, i18n.Errorf("directory.http_not_found", u)
+		}
+
+		return nil, nil
+	case resp.StatusCode == http.StatusConflict:
+		// 锁被其它人持有，响应是当前的锁
+		defer resp.Body.Close()
+		var l Lock
+		if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+			return nil, fmt.Errorf("解析目录服务器的响应报错: %s", err)
+		}
+
+		return nil, &LockedError{Lock: &l}
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf(
+			"目录服务器返回错误 (%d): %s",
+			resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
+
+func (b *HTTPBackend) url(path string, query url.Values) string {
+	u := strings.TrimRight(b.Address, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	return u
+}
+
+func (b *HTTPBackend) lockURL(key string, query url.Values) string {
+	return b.url(httpLockPath+url.PathEscape(key), query)
+}
+
+func (b *HTTPBackend) blobURL(key string) string {
+	return b.url(httpBlobPath+url.PathEscape(key), nil)
+}
@@ -0,0 +1,328 @@
+package directory
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTTP API的路径，HTTPBackend和HTTPHandler共同使用
+//
+//	GET/PUT /v1/blob/<key>   二进制数据，数据不存在时返回404
+//	DELETE  /v1/blob/<key>   删除二进制数据
+//	GET     /v1/infra?...    用Lookup的查询参数读取数据，不存在时返回404
+//	PUT     /v1/infra        保存请求中的JSON数据，返回保存后的数据
+//	GET     /v1/list/infra   列出所有数据，Backend需要实现Lister
+//	GET     /v1/lock/<key>   读取锁，没有锁时返回404
+//	PUT     /v1/lock/<key>   获取或刷新请求中的锁，锁被占用时返回409和当前的锁
+//	DELETE  /v1/lock/<key>   释放请求中的锁，加上 ?force=1 时强制删除
+//
+// build和deploy与infra相同，/v1/list/blob 列出二进制数据的key
+const (
+	httpListPath   = "/v1/list/"
+	httpLockPath   = "/v1/lock/"
+	httpBlobPath   = "/v1/blob/"
+	httpInfraPath  = "/v1/infra"
+	httpBuildPath  = "/v1/build"
+	httpDeployPath = "/v1/deploy"
+)
+
+// HTTPHandler 是HTTPBackend的服务端，把HTTP API的请求转给
+// Backend处理。任何Backend都可以通过它共享给其它的Otto
+type HTTPHandler struct {
+	Backend Backend
+}
+
+// Serve 在ln上用HTTP API共享b，直到ln被关闭
+func Serve(ln net.Listener, b Backend) error {
+	return http.Serve(ln, &HTTPHandler{Backend: b})
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, httpListPath):
+		h.serveList(w, r, strings.TrimPrefix(path, httpListPath))
+	case strings.HasPrefix(path, httpLockPath):
+		h.serveLock(w, r, strings.TrimPrefix(path, httpLockPath))
+	case strings.HasPrefix(path, httpBlobPath):
+		h.serveBlob(w, r, strings.TrimPrefix(path, httpBlobPath))
+	case path == httpInfraPath:
+		h.serveInfra(w, r)
+	case path == httpBuildPath:
+		h.serveBuild(w, r)
+	case path == httpDeployPath:
+		h.serveDeploy(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *HTTPHandler) serveBlob(w http.ResponseWriter, r *http.Request, key string) {
+	if key == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// key来自URL，不检查的话 ".." 可以读写目录之外的文件
+	if err := validateBlobKey(key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		data, err := h.Backend.GetBlob(key)
+		if err != nil {
+			h.error(w, err)
+			return
+		}
+		if data == nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer data.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if _, err := io.Copy(w, data.Data); err != nil {
+			log.Printf("[ERR] directory: error writing blob %s: %s", key, err)
+		}
+
+	case "PUT":
+		if err := h.Backend.PutBlob(key, &BlobData{Data: r.Body}); err != nil {
+			h.error(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case "DELETE":
+		if err := h.Backend.DeleteBlob(key); err != nil {
+			h.error(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		h.methodNotAllowed(w)
+	}
+}
+
+func (h *HTTPHandler) serveInfra(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		result, err := h.Backend.GetInfra(&Infra{Lookup: lookupFromQuery(r.URL.Query())})
+		h.respond(w, r, result != nil, result, err)
+
+	case "PUT":
+		var infra Infra
+		if !h.decode(w, r, &infra) {
+			return
+		}
+
+		err := h.Backend.PutInfra(&infra)
+		h.respond(w, r, true, &infra, err)
+
+	default:
+		h.methodNotAllowed(w)
+	}
+}
+
+func (h *HTTPHandler) serveBuild(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		result, err := h.Backend.GetBuild(&Build{Lookup: lookupFromQuery(r.URL.Query())})
+		h.respond(w, r, result != nil, result, err)
+
+	case "PUT":
+		var build Build
+		if !h.decode(w, r, &build) {
+			return
+		}
+
+		err := h.Backend.PutBuild(&build)
+		h.respond(w, r, true, &build, err)
+
+	default:
+		h.methodNotAllowed(w)
+	}
+}
+
+func (h *HTTPHandler) serveDeploy(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		result, err := h.Backend.GetDeploy(&Deploy{Lookup: lookupFromQuery(r.URL.Query())})
+		h.respond(w, r, result != nil, result, err)
+
+	case "PUT":
+		var deploy Deploy
+		if !h.decode(w, r, &deploy) {
+			return
+		}
+
+		err := h.Backend.PutDeploy(&deploy)
+		h.respond(w, r, true, &deploy, err)
+
+	default:
+		h.methodNotAllowed(w)
+	}
+}
+
+func (h *HTTPHandler) serveList(w http.ResponseWriter, r *http.Request, kind string) {
+	if r.Method != "GET" {
+		h.methodNotAllowed(w)
+		return
+	}
+
+	lister, ok := h.Backend.(Lister)
+	if !ok {
+		http.Error(w, "backend can't list data", http.StatusNotImplemented)
+		return
+	}
+
+	var result interface{}
+	var err error
+	switch kind {
+	case "blob":
+		result, err = lister.ListBlobs()
+	case "infra":
+		result, err = lister.ListInfra()
+	case "build":
+		result, err = lister.ListBuilds()
+	case "deploy":
+		result, err = lister.ListDeploys()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	h.respond(w, r, true, result, err)
+}
+
+func (h *HTTPHandler) serveLock(w http.ResponseWriter, r *http.Request, key string) {
+	locker, ok := h.Backend.(Locker)
+	if !ok {
+		http.Error(w, "backend doesn't support locking", http.StatusNotImplemented)
+		return
+	}
+	if key == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		result, err := locker.GetLock(key)
+		h.respond(w, r, result != nil, result, err)
+
+	case "PUT":
+		var l Lock
+		if !h.decode(w, r, &l) {
+			return
+		}
+
+		l.Key = key
+		err := locker.Lock(&l)
+		h.respond(w, r, true, &l, err)
+
+	case "DELETE":
+		var err error
+		if r.URL.Query().Get("force") != "" {
+			err = locker.ForceUnlock(key)
+		} else {
+			var l Lock
+			if !h.decode(w, r, &l) {
+				return
+			}
+
+			l.Key = key
+			err = locker.Unlock(&l)
+		}
+		if err != nil {
+			h.error(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		h.methodNotAllowed(w)
+	}
+}
+
+// decode 解码请求中的JSON数据。解码失败时写入错误响应并返回false
+func (h *HTTPHandler) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// respond 写入v的JSON。found为false时返回404
+func (h *HTTPHandler) respond(
+	w http.ResponseWriter, r *http.Request, found bool, v interface{}, err error) {
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[ERR] directory: error writing response: %s", err)
+	}
+}
+
+func (h *HTTPHandler) error(w http.ResponseWriter, err error) {
+	// 锁被占用不是服务端的错误，返回当前的锁
+	if lerr, ok := err.(*LockedError); ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(lerr.Lock)
+		return
+	}
+
+	log.Printf("[ERR] directory: %s", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (h *HTTPHandler) methodNotAllowed(w http.ResponseWriter) {
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+// lookupQuery 把Lookup编码成查询参数
+func lookupQuery(l *Lookup) url.Values {
+	q := make(url.Values)
+	for k, v := range map[string]string{
+		"app_id":       l.AppID,
+		"infra":        l.Infra,
+		"infra_flavor": l.InfraFlavor,
+		"foundation":   l.Foundation,
+	} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+
+	return q
+}
+
+// lookupFromQuery 从查询参数中解码Lookup
+func lookupFromQuery(q url.Values) Lookup {
+	return Lookup{
+		AppID:       q.Get("app_id"),
+		Infra:       q.Get("infra"),
+		InfraFlavor: q.Get("infra_flavor"),
+		Foundation:  q.Get("foundation"),
+	}
+}
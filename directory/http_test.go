@@ -0,0 +1,134 @@
+package directory
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPBackend_impl(t *testing.T) {
+	var _ Backend = new(HTTPBackend)
+}
+
+func TestHTTPBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(&HTTPHandler{Backend: &FileBackend{Dir: dir}})
+	defer server.Close()
+
+	TestBackend(t, &HTTPBackend{Address: server.URL})
+}
+
+func TestHTTPBackend_serverError(t *testing.T) {
+	// 服务端的目录不可写，错误要传递给客户端
+	f, err := ioutil.TempFile("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	server := httptest.NewServer(&HTTPHandler{Backend: &FileBackend{Dir: f.Name()}})
+	defer server.Close()
+
+	b := &HTTPBackend{Address: server.URL}
+	err = b.PutInfra(&Infra{Lookup: Lookup{Infra: "foo"}})
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "500") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestHTTPBackend_notFound(t *testing.T) {
+	// 地址不是目录服务器，写入要报错而不是panic
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	b := &HTTPBackend{Address: server.URL}
+	err := b.PutBlob("foo", &BlobData{Data: strings.NewReader("bar")})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("bad: %v", err)
+	}
+	if err := b.PutInfra(&Infra{Lookup: Lookup{Infra: "foo"}}); err == nil {
+		t.Fatal("should error")
+	}
+	if err := b.Lock(&Lock{Key: "foo", ID: "bar"}); err == nil {
+		t.Fatal("should error")
+	}
+
+	// 读取时404表示数据不存在
+	data, err := b.GetBlob("foo")
+	if err != nil || data != nil {
+		t.Fatalf("bad: %#v, %v", data, err)
+	}
+}
+
+func TestHTTPHandler_blobTraversal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secret, []byte("data"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	server := httptest.NewServer(&HTTPHandler{
+		Backend: &BoltBackend{Dir: filepath.Join(dir, "directory")},
+	})
+	defer server.Close()
+
+	// key中的 ".." 不能读写目录之外的文件
+	for _, method := range []string{"PUT", "GET", "DELETE"} {
+		for _, key := range []string{
+			"..%2F..%2Fpwned",
+			"..%2Fsecret",
+			"foo%2F..%2F..%2F..%2Fsecret",
+			"%2Fetc%2Fpasswd",
+			"foo%2F%2Fbar",
+		} {
+			req, err := http.NewRequest(
+				method, server.URL+httpBlobPath+key, strings.NewReader("pwned"))
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("%s %s: bad status: %d", method, key, resp.StatusCode)
+			}
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); !os.IsNotExist(err) {
+		t.Fatalf("file written outside the directory: %v", err)
+	}
+	if data, err := ioutil.ReadFile(secret); err != nil || string(data) != "data" {
+		t.Fatalf("bad: %q, %v", data, err)
+	}
+}
+
+func TestHTTPBackend_unreachable(t *testing.T) {
+	server := httptest.NewServer(&HTTPHandler{})
+	server.Close()
+
+	b := &HTTPBackend{Address: server.URL}
+	if _, err := b.GetInfra(&Infra{Lookup: Lookup{Infra: "foo"}}); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,82 @@
+package directory
+
+// InfraState 是infrastructure的状态
+type InfraState byte
+
+const (
+	InfraStateInvalid InfraState = 0
+	InfraStateReady   InfraState = iota
+	InfraStatePartial
+
+	// InfraStateDrifted 表示infrastructure已经创建，但是 `otto infra
+	// check` 发现实际的资源和保存的状态不一致了
+	InfraStateDrifted
+)
+
+// Infra 表示一个infrastructure的数据
+type Infra struct {
+	// Lookup 是查询信息。只有Infra字段是必须的，可选的
+	// Foundation字段用来获取foundation的infrastructure数据
+	Lookup
+
+	// 这些字段在Put时设置，在Get时填充
+	State   InfraState        // infrastructure的状态
+	Outputs map[string]string // 创建infrastructure的输出数据，字符串形式
+
+	// TypedOutputs 是保留了类型的输出数据。旧版本的Otto保存的记录
+	// 只有Outputs，读取输出应该使用Output和AllOutputs
+	TypedOutputs Outputs
+
+	// 私有字段，在Get或Put时设置
+	//
+	// 不要修改
+	ID string
+}
+
+// IsReady 返回infrastructure是否已经可用。发生了漂移的infrastructure
+// 仍然是可用的
+func (i *Infra) IsReady() bool {
+	return i != nil && (i.State == InfraStateReady || i.State == InfraStateDrifted)
+}
+
+// SetOutputs 设置infrastructure的输出，同时更新字符串形式的Outputs
+func (i *Infra) SetOutputs(outputs Outputs) {
+	i.TypedOutputs = outputs
+	i.Outputs = outputs.Strings()
+}
+
+// AllOutputs 返回infrastructure的所有输出。只有字符串输出的旧记录
+// 的每个输出都是字符串类型
+func (i *Infra) AllOutputs() Outputs {
+	if i == nil {
+		return nil
+	}
+	if i.TypedOutputs != nil {
+		return i.TypedOutputs
+	}
+	if i.Outputs == nil {
+		return nil
+	}
+
+	result := make(Outputs, len(i.Outputs))
+	for k, v := range i.Outputs {
+		result[k] = StringOutput(v)
+	}
+
+	return result
+}
+
+// Output 返回名字是name的输出，不存在时返回nil
+func (i *Infra) Output(name string) *Output {
+	return i.AllOutputs()[name]
+}
+
+// IsDrifted 返回infrastructure是否发生了漂移
+func (i *Infra) IsDrifted() bool {
+	return i != nil && i.State == InfraStateDrifted
+}
+
+// IsPartial 返回infrastructure是否只创建了一部分
+func (i *Infra) IsPartial() bool {
+	return i != nil && i.State == InfraStatePartial
+}
@@ -0,0 +1,134 @@
+package directory
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/kuuyee/otto-learn/helper/uuid"
+)
+
+// DefaultLockTTL 是锁默认的有效期。持有者需要在过期之前刷新锁，
+// 否则其它人可以获取它
+const DefaultLockTTL = 5 * time.Minute
+
+// Lock 是目录中的一个锁，用来防止多个Otto同时修改同一份数据，
+// 比如Terraform状态
+type Lock struct {
+	// Key 是锁住的数据的key，通常是Terraform的StateId
+	Key string
+
+	// ID 唯一标识持有锁的一方，释放和刷新锁时必须一致。为空时
+	// NewLock会生成一个
+	ID string
+
+	// Holder 和 Operation 描述谁在做什么，锁被占用的时候显示
+	// 给用户看
+	Holder    string
+	Operation string
+
+	// TTL 是锁的有效期。Expires 在获取或刷新锁时由后端设置
+	TTL     time.Duration
+	Created time.Time
+	Expires time.Time
+}
+
+// NewLock 返回一个key的新锁，Holder是当前用户、主机和进程
+func NewLock(key, operation string) *Lock {
+	return &Lock{
+		Key:       key,
+		ID:        uuid.GenerateUUID(),
+		Holder:    lockHolder(),
+		Operation: operation,
+		TTL:       DefaultLockTTL,
+	}
+}
+
+// Expired 返回锁在now的时候是否已经过期
+func (l *Lock) Expired(now time.Time) bool {
+	return !l.Expires.IsZero() && now.After(l.Expires)
+}
+
+// Locker 是支持锁的Backend。锁是建议性的，只有获取锁的一方
+// 才会遵守它
+type Locker interface {
+	// Lock 获取或刷新锁。锁不存在、已经过期或者ID相同时成功，
+	// 并设置锁的Created和Expires。锁被其它人持有时返回
+	// *LockedError
+	Lock(*Lock) error
+
+	// Unlock 释放锁。锁已经不存在时什么都不做，被其它人持有
+	// 时返回*LockedError
+	Unlock(*Lock) error
+
+	// GetLock 返回key当前的锁，没有锁或者锁已经过期时返回nil
+	GetLock(key string) (*Lock, error)
+
+	// ForceUnlock 无论谁持有都删除key的锁。只在持有锁的进程
+	// 已经不在的时候使用
+	ForceUnlock(key string) error
+}
+
+// LockedError 是锁被其它人持有时返回的错误
+type LockedError struct {
+	Lock *Lock
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf(
+		"%s 已经被 %s 锁定 (操作: %s，开始于 %s，过期时间 %s)",
+		e.Lock.Key, e.Lock.Holder, e.Lock.Operation,
+		e.Lock.Created.Format(time.RFC3339),
+		e.Lock.Expires.Format(time.RFC3339))
+}
+
+// lockAcquire 是后端获取锁的公共逻辑。current是key当前的锁，
+// 可以是nil。成功时设置l的时间
+func lockAcquire(current, l *Lock, now time.Time) error {
+	if current != nil && current.ID != l.ID && !current.Expired(now) {
+		return &LockedError{Lock: current}
+	}
+
+	if l.TTL <= 0 {
+		l.TTL = DefaultLockTTL
+	}
+	if current != nil && current.ID == l.ID {
+		l.Created = current.Created
+	} else {
+		l.Created = now
+	}
+	l.Expires = now.Add(l.TTL)
+	return nil
+}
+
+// lockRelease 是后端释放锁的公共逻辑。返回是否应该删除current
+func lockRelease(current, l *Lock, now time.Time) (bool, error) {
+	if current == nil {
+		return false, nil
+	}
+	if current.ID != l.ID {
+		if current.Expired(now) {
+			return false, nil
+		}
+
+		return false, &LockedError{Lock: current}
+	}
+
+	return true, nil
+}
+
+// lockHolder 返回描述当前进程的字符串
+func lockHolder() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s@%s (pid %d)", name, host, os.Getpid())
+}
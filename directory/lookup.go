@@ -0,0 +1,34 @@
+package directory
+
+import (
+	"fmt"
+)
+
+// Lookup 是查询目录数据使用的结构。不同数据类型需要
+// 的字段不同，参看每个类型的文档
+type Lookup struct {
+	// AppID 是应用的ID，来自Appfile的ID
+	AppID string
+
+	// Infra 是infrastructure的名字，Infra Flavor 是它的flavor
+	Infra       string
+	InfraFlavor string
+
+	// Foundation 是foundation的名字，只用在foundation相关的查询
+	Foundation string
+}
+
+// infraKey 返回infrastructure数据使用的key
+func infraKey(l *Lookup) string {
+	key := l.Infra
+	if l.Foundation != "" {
+		key += "/" + l.Foundation
+	}
+
+	return key
+}
+
+// appKey 返回应用数据(构建、部署)使用的key
+func appKey(l *Lookup) string {
+	return fmt.Sprintf("%s/%s/%s", l.AppID, l.Infra, l.InfraFlavor)
+}
@@ -0,0 +1,282 @@
+package directory
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuuyee/otto-learn/helper/uuid"
+)
+
+// MemoryBackend 是把数据保存在内存中的目录后端，进程退出后数据就
+// 没有了。主要用于测试
+//
+// 记录和FileBackend一样编码成JSON保存，所以读取的总是一份新的副本，
+// 修改读到的数据不会影响保存的数据
+type MemoryBackend struct {
+	lock    sync.Mutex
+	blobs   map[string][]byte
+	records map[string]map[string][]byte
+	locks   map[string]*Lock
+}
+
+func (b *MemoryBackend) PutBlob(key string, data *BlobData) error {
+	// 无论如何都要关闭数据，避免泄露资源
+	defer data.Close()
+
+	raw, err := ioutil.ReadAll(data.Data)
+	if err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.blobs == nil {
+		b.blobs = make(map[string][]byte)
+	}
+	b.blobs[key] = raw
+	return nil
+}
+
+func (b *MemoryBackend) GetBlob(key string) (*BlobData, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	raw, ok := b.blobs[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &BlobData{
+		Key:  key,
+		Data: bytes.NewReader(raw),
+	}, nil
+}
+
+func (b *MemoryBackend) DeleteBlob(key string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.blobs, key)
+	return nil
+}
+
+func (b *MemoryBackend) PutInfra(infra *Infra) error {
+	if infra.ID == "" {
+		infra.ID = uuid.GenerateUUID()
+	}
+
+	return b.put(fileInfraDir, infraKey(&infra.Lookup), infra)
+}
+
+func (b *MemoryBackend) GetInfra(infra *Infra) (*Infra, error) {
+	var result Infra
+	ok, err := b.get(fileInfraDir, infraKey(&infra.Lookup), &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *MemoryBackend) PutBuild(build *Build) error {
+	if build.ID == "" {
+		build.ID = uuid.GenerateUUID()
+	}
+
+	return b.put(fileBuildDir, appKey(&build.Lookup), build)
+}
+
+func (b *MemoryBackend) GetBuild(build *Build) (*Build, error) {
+	var result Build
+	ok, err := b.get(fileBuildDir, appKey(&build.Lookup), &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *MemoryBackend) PutDeploy(deploy *Deploy) error {
+	if deploy.ID == "" {
+		deploy.ID = uuid.GenerateUUID()
+	}
+
+	return b.put(fileDeployDir, appKey(&deploy.Lookup), deploy)
+}
+
+func (b *MemoryBackend) GetDeploy(deploy *Deploy) (*Deploy, error) {
+	var result Deploy
+	ok, err := b.get(fileDeployDir, appKey(&deploy.Lookup), &result)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *MemoryBackend) ListBlobs() ([]string, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	result := make([]string, 0, len(b.blobs))
+	for k := range b.blobs {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+func (b *MemoryBackend) ListInfra() ([]*Infra, error) {
+	var result []*Infra
+	err := b.list(fileInfraDir, func(raw []byte) error {
+		var v Infra
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+
+		result = append(result, &v)
+		return nil
+	})
+
+	return result, err
+}
+
+func (b *MemoryBackend) ListBuilds() ([]*Build, error) {
+	var result []*Build
+	err := b.list(fileBuildDir, func(raw []byte) error {
+		var v Build
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+
+		result = append(result, &v)
+		return nil
+	})
+
+	return result, err
+}
+
+func (b *MemoryBackend) ListDeploys() ([]*Deploy, error) {
+	var result []*Deploy
+	err := b.list(fileDeployDir, func(raw []byte) error {
+		var v Deploy
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+
+		result = append(result, &v)
+		return nil
+	})
+
+	return result, err
+}
+
+func (b *MemoryBackend) Lock(l *Lock) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if err := lockAcquire(b.locks[l.Key], l, time.Now()); err != nil {
+		return err
+	}
+
+	if b.locks == nil {
+		b.locks = make(map[string]*Lock)
+	}
+	stored := *l
+	b.locks[l.Key] = &stored
+	return nil
+}
+
+func (b *MemoryBackend) Unlock(l *Lock) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	remove, err := lockRelease(b.locks[l.Key], l, time.Now())
+	if err != nil {
+		return err
+	}
+	if remove {
+		delete(b.locks, l.Key)
+	}
+
+	return nil
+}
+
+func (b *MemoryBackend) GetLock(key string) (*Lock, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	l, ok := b.locks[key]
+	if !ok || l.Expired(time.Now()) {
+		return nil, nil
+	}
+
+	result := *l
+	return &result, nil
+}
+
+func (b *MemoryBackend) ForceUnlock(key string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.locks, key)
+	return nil
+}
+
+// put 把v编码成JSON，用key保存到kind类型的记录中
+func (b *MemoryBackend) put(kind, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.records == nil {
+		b.records = make(map[string]map[string][]byte)
+	}
+	if b.records[kind] == nil {
+		b.records[kind] = make(map[string][]byte)
+	}
+	b.records[kind][key] = raw
+	return nil
+}
+
+// get 读取kind类型的记录key并解码到v。如果key不存在返回false
+func (b *MemoryBackend) get(kind, key string, v interface{}) (bool, error) {
+	b.lock.Lock()
+	raw, ok := b.records[kind][key]
+	b.lock.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(raw, v)
+}
+
+// list 按照key的顺序对kind类型的每条记录调用f
+func (b *MemoryBackend) list(kind string, f func([]byte) error) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	keys := make([]string, 0, len(b.records[kind]))
+	for k := range b.records[kind] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := f(b.records[kind][k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package directory
+
+import (
+	"testing"
+)
+
+func TestMemoryBackend_impl(t *testing.T) {
+	var _ Backend = new(MemoryBackend)
+	var _ Lister = new(MemoryBackend)
+	var _ Locker = new(MemoryBackend)
+}
+
+func TestMemoryBackend(t *testing.T) {
+	TestBackend(t, new(MemoryBackend))
+}
+
+func TestMemoryBackend_copy(t *testing.T) {
+	b := new(MemoryBackend)
+	infra := &Infra{Lookup: Lookup{Infra: "foo"}, State: InfraStateReady}
+	if err := b.PutInfra(infra); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// 修改写入或者读到的记录不会改变保存的数据
+	infra.State = InfraStatePartial
+	actual, err := b.GetInfra(infra)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	actual.State = InfraStateInvalid
+
+	actual, err = b.GetInfra(infra)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual.State != InfraStateReady {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
@@ -0,0 +1,153 @@
+package directory
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// OutputType 是infrastructure输出的类型
+type OutputType string
+
+const (
+	OutputTypeString OutputType = "string"
+	OutputTypeList   OutputType = "list"
+	OutputTypeMap    OutputType = "map"
+)
+
+// Output 是infrastructure的一个有类型的输出，比如Terraform的output
+//
+// Value 根据Type是string、[]string或者map[string]string
+type Output struct {
+	Type  OutputType  `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// StringOutput、ListOutput 和 MapOutput 创建对应类型的输出
+func StringOutput(v string) *Output {
+	return &Output{Type: OutputTypeString, Value: v}
+}
+
+func ListOutput(v []string) *Output {
+	return &Output{Type: OutputTypeList, Value: v}
+}
+
+func MapOutput(v map[string]string) *Output {
+	return &Output{Type: OutputTypeMap, Value: v}
+}
+
+// String 返回输出的字符串形式。列表用逗号连接，这和Terraform 0.6
+// 输出列表的习惯一致；map是按key排序的 "key=value"，用逗号连接
+func (o *Output) String() string {
+	if o == nil {
+		return ""
+	}
+
+	switch v := o.Value.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ",")
+	case map[string]string:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + "=" + v[k]
+		}
+		return strings.Join(parts, ",")
+	default:
+		return ""
+	}
+}
+
+// List 返回列表输出的值。字符串输出按逗号拆分，这样用逗号连接
+// 列表的旧输出也可以当作列表读取。map输出返回nil
+func (o *Output) List() []string {
+	if o == nil {
+		return nil
+	}
+
+	switch v := o.Value.(type) {
+	case []string:
+		return v
+	case string:
+		if v == "" {
+			return nil
+		}
+
+		return strings.Split(v, ",")
+	default:
+		return nil
+	}
+}
+
+// Map 返回map输出的值，其它类型返回nil
+func (o *Output) Map() map[string]string {
+	if o == nil {
+		return nil
+	}
+
+	v, _ := o.Value.(map[string]string)
+	return v
+}
+
+// UnmarshalJSON 根据Type把Value还原成具体的类型
+func (o *Output) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type  OutputType      `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	o.Type = raw.Type
+	switch raw.Type {
+	case OutputTypeString:
+		var v string
+		if err := json.Unmarshal(raw.Value, &v); err != nil {
+			return err
+		}
+		o.Value = v
+	case OutputTypeList:
+		var v []string
+		if err := json.Unmarshal(raw.Value, &v); err != nil {
+			return err
+		}
+		o.Value = v
+	case OutputTypeMap:
+		var v map[string]string
+		if err := json.Unmarshal(raw.Value, &v); err != nil {
+			return err
+		}
+		o.Value = v
+	default:
+		return i18n.Errorf("directory.output_type_err", raw.Type)
+	}
+
+	return nil
+}
+
+// Outputs 是infrastructure的所有输出，key是输出的名字
+type Outputs map[string]*Output
+
+// Strings 返回所有输出的字符串形式，参看Output.String
+func (o Outputs) Strings() map[string]string {
+	if o == nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(o))
+	for k, v := range o {
+		result[k] = v.String()
+	}
+
+	return result
+}
@@ -0,0 +1,110 @@
+package directory
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	cases := []struct {
+		Output *Output
+		String string
+		List   []string
+		Map    map[string]string
+	}{
+		{
+			StringOutput("a"),
+			"a",
+			[]string{"a"},
+			nil,
+		},
+		{
+			StringOutput("a,b"),
+			"a,b",
+			[]string{"a", "b"},
+			nil,
+		},
+		{
+			StringOutput(""),
+			"",
+			nil,
+			nil,
+		},
+		{
+			ListOutput([]string{"a", "b"}),
+			"a,b",
+			[]string{"a", "b"},
+			nil,
+		},
+		{
+			MapOutput(map[string]string{"b": "2", "a": "1"}),
+			"a=1,b=2",
+			nil,
+			map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			nil,
+			"",
+			nil,
+			nil,
+		},
+	}
+
+	for i, tc := range cases {
+		if actual := tc.Output.String(); actual != tc.String {
+			t.Fatalf("%d: bad string: %q", i, actual)
+		}
+		if actual := tc.Output.List(); !reflect.DeepEqual(actual, tc.List) {
+			t.Fatalf("%d: bad list: %#v", i, actual)
+		}
+		if actual := tc.Output.Map(); !reflect.DeepEqual(actual, tc.Map) {
+			t.Fatalf("%d: bad map: %#v", i, actual)
+		}
+	}
+}
+
+func TestOutputJSON(t *testing.T) {
+	outputs := Outputs{
+		"foo":     StringOutput("bar"),
+		"subnets": ListOutput([]string{"subnet-1"}),
+		"zones":   MapOutput(map[string]string{"a": "us-east-1a"}),
+	}
+
+	data, err := json.Marshal(outputs)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var actual Outputs
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(actual, outputs) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if err := json.Unmarshal([]byte(`{"x": {"type": "set"}}`), &actual); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestInfraOutputs_legacy(t *testing.T) {
+	// 旧版本保存的记录只有字符串输出
+	infra := &Infra{Outputs: map[string]string{"subnets": "subnet-1,subnet-2"}}
+
+	if o := infra.Output("subnets"); o == nil || o.Type != OutputTypeString {
+		t.Fatalf("bad: %#v", o)
+	}
+	if l := infra.Output("subnets").List(); len(l) != 2 {
+		t.Fatalf("bad: %#v", l)
+	}
+	if o := infra.Output("missing"); o != nil {
+		t.Fatalf("bad: %#v", o)
+	}
+
+	var missing *Infra
+	if o := missing.AllOutputs(); o != nil {
+		t.Fatalf("bad: %#v", o)
+	}
+}
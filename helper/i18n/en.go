@@ -0,0 +1,379 @@
+package i18n
+
+// en 是英文的消息目录
+var en = map[string]string{
+	// otto.Core
+	"core.compile.infra":      "Compiling infra...",
+	"core.compile.foundation": "Compiling foundation: %s",
+	"core.compile.dep":        "Compiling dependency '%s'...",
+	"core.compile.main":       "Compiling main application...",
+
+	"core.app.load_err":        "Error loading App: %s",
+	"core.app.load_vertex_err": "Error loading Appfile for '%s': %s",
+	"core.app.impl_err":        "Error getting App implementation for '%s': %s",
+	"core.app.not_found":       "App implementation for tuple not found: %s",
+	"core.app.start_err":       "App failed to start properly: %s",
+	"core.app.cache_dir_err":   "Error creating cache directory '%s': %s",
+	"core.app.dev_ip_err":      "Error retrieving dev IP address: %s",
+
+	"core.build.save_err":    "Error saving build data: %s",
+	"core.build.lookup_err":  "Error looking up build data: %s",
+	"core.deploy.save_err":   "Error saving deploy data: %s",
+	"core.deploy.lookup_err": "Error looking up deploy data: %s",
+
+	"core.dev.state_read_err": "Error reading dev environment state: %s",
+	"core.dev.state_save_err": "Error saving dev environment state: %s",
+	"core.dev.dep_cached":     "Using cached dev dependency for '%s'",
+	"core.dev.dep_building":   "Building dev dependency for '%s'...",
+	"core.dev.dep_build_err":  "Error building dev dependency '%s': %s",
+	"core.dev.dep_cache_err":  "Error caching dev dependency '%s': %s",
+	"core.dev.not_created": "The development environment hasn't been created yet!\n\n" +
+		"Run 'otto dev' to create the development environment, and then\n" +
+		"run this command again.",
+
+	"core.not_compiled": "The application hasn't been compiled yet!\n\n" +
+		"Otto couldn't find the compiled data for this application. Run\n" +
+		"'otto compile' in the directory with the Appfile to compile the\n" +
+		"files needed to develop, build, and deploy your application, and\n" +
+		"then run this command again.",
+	"core.hash_err": "Error computing the Appfile hash: %s",
+
+	"core.infra.not_found":   "Infrastructure not found in Appfile: %s",
+	"core.infra.unsupported": "Infrastructure type not supported: %s",
+	"core.infra.lookup_err":  "Error looking up infrastructure data: %s",
+	"core.infra.flavor_err": "Unknown flavor %q for the %s infrastructure.\n" +
+		"Valid flavors: %s",
+	"core.infra.variable_err": "Unknown variable %q for the %s flavor.\n" +
+		"Variables declared by the flavor: %s",
+	"core.infra.foundation_build":   "Building infrastructure for foundation: %s",
+	"core.infra.foundation_destroy": "Destroying infrastructure for foundation: %s",
+	"core.infra.created_header":     "[green]Infrastructure successfully created!",
+	"core.infra.created": "[green]The infrastructure necessary to deploy this application\n" +
+		"is now available. You can now deploy using `otto deploy`.",
+	"core.infra.destroyed_header": "[green]Infrastructure successfully destroyed!",
+	"core.infra.destroyed": "[green]The infrastructure necessary to run this application and\n" +
+		"all other applications in this project has been destroyed.",
+	"core.infra.deployed": "Applications are still deployed into this infrastructure: %s\n\n" +
+		"Otto won't destroy infrastructure that still has applications deployed\n" +
+		"into it. Run 'otto deploy destroy' for each of these applications first,\n" +
+		"and then run this command again.",
+	"core.infra.not_ready": "Infrastructure for this application hasn't been built yet.\n\n" +
+		"The deploy step requires the infrastructure to be fully built.\n" +
+		"Please run `otto infra` to build the infrastructure, and then\n" +
+		"run this command again.",
+	"core.infra.partial": "Infrastructure for this application was only partially built.\n\n" +
+		"The last `otto infra` failed before it completed. The deploy step\n" +
+		"requires the infrastructure to be fully built. Please run\n" +
+		"`otto infra` again, and then run this command again.",
+	"context.infra_not_created": "This application needs the outputs of the infrastructure %q,\n" +
+		"but it hasn't been built yet. Please run `otto infra` first.",
+	"context.infra_partial": "This application needs the outputs of the infrastructure %q,\n" +
+		"but it was only partially built and its outputs may be missing.\n" +
+		"Please run `otto infra` again first.",
+
+	"core.foundation.not_found": "Foundation implementation for tuple not found: %s",
+
+	// 认证
+	"creds.header": "Querying infrastructure credentials...",
+	"creds.cached": "Cached and encrypted infrastructure credentials found.\n" +
+		"Otto will now ask you for the password to decrypt these\n" +
+		"credentials.\n\n",
+	"creds.not_found": "Existing infrastructure credentials were not found! Otto will\n" +
+		"now ask you for infrastructure credentials. These will be encrypted\n" +
+		"and saved on disk so this doesn't need to be repeated.\n\n",
+	"creds.verify_err": "Error verifying infrastructure credentials: %s",
+	"creds.verify_err_rotate": "Error verifying infrastructure credentials: %s\n\n" +
+		"If the stored credentials are no longer valid, run `otto creds rotate`\n" +
+		"to enter new credentials.",
+	"creds.clear_err":     "Error deleting encrypted credentials: %s",
+	"creds.cleared":       "[green]Deleted the encrypted credentials for infrastructure '%s'",
+	"creds.rotate_header": "Re-entering infrastructure credentials...",
+	"creds.rotate_warning": "IMPORTANT: Make sure the new credentials are for the same account,\n" +
+		"otherwise you may lose access to your existing infrastructure\n" +
+		"Otto set up.\n\n",
+	"creds.rotated": "[green]Updated the encrypted credentials for infrastructure '%s'",
+	"creds.read_err": "Error reading encrypted credentials: %s\n\n" +
+		"If you forgot the password, run `otto creds clear` to delete the\n" +
+		"stored credentials and Otto will ask for them again.",
+	"creds.write_err":  "Error saving encrypted credentials: %s",
+	"creds.pass_query": "Encrypted Credentials Password",
+	"creds.pass_desc": "This password is used to encrypt and decrypt the infrastructure\n" +
+		"credentials Otto stores on disk. The same password must be used\n" +
+		"every time the credentials are read. Set OTTO_CREDS_PASSWORD to\n" +
+		"avoid being asked for it.",
+	"creds.crypt_format_err":  "The encrypted data is malformed.",
+	"creds.crypt_decrypt_err": "The password is wrong or the encrypted data is corrupted.",
+	"creds.pass_empty":        "The encrypted credentials password can't be empty.",
+
+	// 命令
+	"command.core_load_err":   "Error loading Core: %s",
+	"command.appfile_dir_err": "Can't determine the Appfile directory",
+	"command.not_compiled": "Otto doesn't appear to have compiled your Appfile yet!\n\n" +
+		"Run `otto compile` in the directory with the Appfile or\n" +
+		"with the `-appfile` flag in order to compile the files for\n" +
+		"developing, building, and deploying your application.\n\n" +
+		"Once the Appfile is compiled, you can run `otto` in any\n" +
+		"subdirectory.",
+	"command.directory_backend_err": "Unknown directory backend: %s (expected \"bolt\", \"file\" or \"http\")",
+	"command.directory_address_err": "The \"http\" directory backend needs the server address in directory_path",
+	"command.directory_remote_err":  "Can't serve a remote directory. Configure a local \"bolt\" or \"file\" backend to serve",
+	"command.directory_listen_err":  "Error listening on %s: %s",
+	"command.directory_serving":     "Serving the Otto directory on http://%s",
+	"command.directory_serve_err":   "Error serving the directory: %s",
+	"command.directory_stopped":     "Directory server stopped",
+	"command.directory_export_err":  "Error exporting the directory: %s",
+	"command.directory_exported":    "Exported %d entries to %s",
+	"command.directory_import_err":  "Error importing the directory: %s",
+	"command.directory_imported":    "Imported %d entries from %s",
+	"command.unknown_action":        "Unknown action: %s\n\n%s",
+	"command.flag_kv_err":           "Argument must be in the form key=value: %s",
+	"command.version_err":           "Error checking latest version: %s",
+	"command.version_outdated": "Your version of Otto is out of date! The latest version\n" +
+		"is %s. You can update by downloading from www.ottoproject.io",
+
+	"compile.loading":         "Loading Appfile...",
+	"compile.load_err":        "Error loading Appfile: %s",
+	"compile.wd_err":          "Error loading working directory: %s",
+	"compile.no_appfile_head": "No Appfile found! Detecting project information...",
+	"compile.no_appfile": "No Appfile was found. If there is no Appfile, Otto will do its best\n" +
+		"to detect the type of application this is and set reasonable defaults.\n" +
+		"This is a good way to get started with Otto, but over time we recommend\n" +
+		"writing a real Appfile since this will allow more complex customizations,\n" +
+		"the ability to reference dependencies, versioning, and more.",
+	"compile.cant_detect": "No Appfile is present and Otto couldn't detect the project type automatically.\n" +
+		"Otto does its best without an Appfile to detect what kind of project this is\n" +
+		"automatically, but sometimes this fails if the project is in a structure\n" +
+		"Otto doesn't recognize or its a project type that Otto doesn't yet support.\n\n" +
+		"Please create an Appfile and specify at a minimum the project name and type. Below\n" +
+		"is an example minimal Appfile specifying the \"my-app\" application name and \"go\"\n" +
+		"project type:\n\n" +
+		"    application {\n" +
+		"\tname = \"my-app\"\n" +
+		"\ttype = \"go\"\n" +
+		"    }\n\n" +
+		"If you believe Otto should've been able to automatically detect your\n" +
+		"project type, then please open an issue with the Otto project.",
+	"compile.detectors_err":  "Error loading detectors from %s: %s",
+	"compile.deps":           "Fetching all Appfile dependencies...",
+	"compile.appfile_err":    "Error compiling Appfile: %s",
+	"compile.header":         "Compiling...",
+	"compile.application":    "Application:    %s (%s)",
+	"compile.project":        "Project:        %s",
+	"compile.infrastructure": "Infrastructure: %s (%s)",
+	"compile.err":            "Error compiling: %s",
+	"compile.success_header": "[green]Compilation success!",
+	"compile.success": "[green]This means that Otto is now ready to start a development environment,\n" +
+		"deploy this application, build the supporting infrastructure, and\n" +
+		"more. See the help for more information.\n\n" +
+		"Supporting files to enable Otto to manage your application from\n" +
+		"development to deployment have been placed in the output directory.\n" +
+		"These files can be manually inspected to determine what Otto will do.",
+
+	"status.encode_err":          "Error encoding status: %s",
+	"status.appfile_load_err":    "Error loading the compiled Appfile: %s",
+	"status.not_compiled_header": "Appfile: [reset]NOT COMPILED",
+	"status.not_compiled": "Run 'otto compile' in the directory with the Appfile to\n" +
+		"compile it. The status of the other components can only\n" +
+		"be shown once the Appfile is compiled.",
+	"status.app_header":         "App Info",
+	"status.application":        "Application:    %s (%s)",
+	"status.project":            "Project:        %s",
+	"status.infrastructure":     "Infrastructure: %s (%s)",
+	"status.component_header":   "Component Status",
+	"status.appfile":            "Appfile:         %s",
+	"status.dev":                "Dev environment: %s",
+	"status.infra":              "Infra:           %s",
+	"status.build":              "Build:           %s (%s)",
+	"status.build_none":         "Build:           [reset]NOT BUILT",
+	"status.deploy_header":      "Deploy Status",
+	"status.compiled":           "[green]COMPILED",
+	"status.stale":              "[yellow]STALE (the Appfile changed, run 'otto compile')",
+	"status.state.ready":        "[green]READY",
+	"status.state.success":      "[green]SUCCESS",
+	"status.state.deployed":     "[green]DEPLOYED",
+	"status.state.halted":       "[yellow]HALTED",
+	"status.state.partial":      "[yellow]PARTIAL",
+	"status.state.drifted":      "[yellow]DRIFTED (run 'otto infra check' for details)",
+	"status.state.failed":       "[red]FAILED",
+	"status.state.invalid":      "[reset]INVALID",
+	"status.state.not_deployed": "[reset]NOT DEPLOYED",
+	"status.state.not_created":  "[reset]NOT CREATED",
+
+	"ui.interrupted":   "interrupted",
+	"ui.hide_not_file": "hidden input must read from a file",
+	"ui.default":       "  [bold]Default:[reset] ",
+	"ui.enter_value":   "  [bold]Enter a value:[reset] ",
+	"ui.input_disabled": "Input '%s' is required, but input is disabled (-input=false or %s).\n" +
+		"Provide the value with -var %s=VALUE",
+	"ui.input_disabled_env": ", or set one of the environment variables: %s",
+
+	// appfile
+	"appfile.parse_err":         "Error parsing Appfile: %s",
+	"appfile.block_err":         "Error parsing '%s' in the Appfile: %s",
+	"appfile.block_once":        "Only one '%s' block is allowed",
+	"appfile.block_type_err":    "'%s' must be a block",
+	"appfile.duplicate":         "%s '%s' is defined more than once",
+	"appfile.variable_type_err": "Variable %q must be a string, number or boolean",
+
+	// directory
+	"directory.http_not_found": "The directory server returned 404 for %s.\n" +
+		"Check that the directory path points to an Otto directory server.",
+	"directory.blob_key_err": "Invalid blob key %q: keys must be relative paths\n" +
+		"without empty, \".\" or \"..\" parts.",
+	"directory.lock_timeout": "Timeout waiting for the directory lock: %s\n\n" +
+		"Another Otto process is probably writing to the directory. Wait for it\n" +
+		"to finish and try again.",
+	"directory.export_not_lister":          "The directory backend can't list its data, so it can't be exported.",
+	"directory.export_err":                 "Error exporting %s: %s",
+	"directory.export_blob_gone":           "the data was deleted while exporting",
+	"directory.import_err":                 "Error importing %s: %s",
+	"directory.import_format_err":          "Invalid %s in the archive: %s",
+	"directory.archive_read_err":           "Error reading the archive: %s",
+	"directory.archive_file_err":           "Unsupported file in the archive: %s",
+	"directory.archive_manifest_err":       "Invalid archive manifest: %s",
+	"directory.archive_duplicate":          "Duplicate file in the archive: %s",
+	"directory.archive_file_read_err":      "Error reading %s from the archive: %s",
+	"directory.archive_no_manifest":        "The archive has no manifest %s, so it isn't an Otto directory archive.",
+	"directory.archive_version_err":        "Unsupported archive version: %d (supported version: %d)",
+	"directory.archive_key_err":            "%s in the manifest has an invalid key: %s",
+	"directory.archive_type_err":           "%s in the manifest has an invalid type: %s",
+	"directory.archive_manifest_duplicate": "Duplicate file in the manifest: %s",
+	"directory.archive_missing":            "File missing from the archive: %s",
+	"directory.archive_checksum_err":       "The checksum of %s in the archive doesn't match. The archive may be corrupt.",
+	"directory.archive_extra":              "%s in the archive isn't in the manifest",
+	"directory.output_type_err":            "Unknown output type %q",
+
+	// helper/terraform
+	"terraform.destroying":  "Destroying main infrastructure...",
+	"terraform.building":    "Building main infrastructure...",
+	"terraform.info_none":   "Infrastructure not created. Nothing to display.",
+	"terraform.run_err":     "Error running Terraform: %s",
+	"terraform.executing":   "Executing Terraform to manage infrastructure...",
+	"terraform.complete":    "Terraform execution complete. Saving results...",
+	"terraform.outputs_err": "Error reading Terraform outputs: %s",
+	"terraform.executing_note": "Raw Terraform output will begin streaming in below. Otto\n" +
+		"does not create this output. It is mirrored directly from\n" +
+		"Terraform while the infrastructure is being created.\n\n" +
+		"Terraform may ask for input. For infrastructure provider\n" +
+		"credentials, be sure to enter the same credentials\n" +
+		"consistently within the same Otto environment.\n\n",
+	"terraform.lookup_err": "Error looking up existing infrastructure data: %s\n\n" +
+		"These errors are usually transient and can be fixed by retrying\n" +
+		"the command. Additional causes of errors are networking or disk\n" +
+		"issues that can be resolved external to Otto.",
+	"terraform.prepare_err": "Error preparing infrastructure: %s\n\n" +
+		"These errors are usually transient and can be fixed by retrying\n" +
+		"the command. Additional causes of errors are networking or disk\n" +
+		"issues that can be resolved external to Otto.",
+	"terraform.store_err": "Error storing infrastructure data: %s\n\n" +
+		"This means that Otto won't be able to know that your infrastructure\n" +
+		"was successfully created. Otto tries a few times to save the\n" +
+		"infrastructure. At this point in time, Otto doesn't support gracefully\n" +
+		"recovering from this error. Your infrastructure is now orphaned from\n" +
+		"Otto's management. Please reference the community for help.\n\n" +
+		"A future version of Otto will resolve this.",
+	"terraform.not_ready": "Error reading Terraform outputs: %s\n\n" +
+		"In this case, Otto is unable to consider the infrastructure ready.\n" +
+		"Otto won't lose your infrastructure information. You may just need\n" +
+		"to run `otto infra` again and it may work. If this problem persists,\n" +
+		"please see the error message and consult the community for help.",
+	"terraform.output_read_err": "Error reading output %q: %s",
+	"terraform.output_type_err": "Unknown Terraform output type %q",
+	"terraform.state_load_err":  "Error loading Terraform state: %s",
+	"terraform.state_write_err": "Error writing Terraform state: %s",
+	"terraform.state_read_err":  "Error reading Terraform state for saving: %s",
+	"terraform.state_save_err": "Failed to save Terraform state: %s\n\n" +
+		"This means that Otto was unable to store the state of your infrastructure.\n" +
+		"Otto copied the state to the path below so it isn't lost. Keep this\n" +
+		"file! Once the directory works again, ask the community for help\n" +
+		"putting the state back.\n\n" +
+		"  %s",
+	"terraform.run_state_err": "%s\n\n" +
+		"In addition, the state of this run couldn't be saved:\n\n%s",
+	"terraform.state_history_parse_err": "Error parsing the state history: %s",
+	"terraform.state_locked": "The Terraform state is locked by %s\n" +
+		"(operation: %s, since %s, expires %s).\n\n" +
+		"Another Otto is changing this infrastructure. Wait for it to finish\n" +
+		"and try again. If that Otto is no longer running, remove the lock\n" +
+		"with `otto infra force-unlock`.",
+	"terraform.state_lock_err": "Error locking the Terraform state: %s",
+	"terraform.state_unlock_err": "Error unlocking the Terraform state: %s\n\n" +
+		"The lock expires on its own, or can be removed with\n" +
+		"`otto infra force-unlock`.",
+	"terraform.lock_unsupported":  "The configured directory backend doesn't support locking.",
+	"terraform.unlock_not_locked": "The infrastructure state is not locked.",
+	"terraform.unlock_holder": "The infrastructure state is locked by %s\n" +
+		"(operation: %s, since %s).",
+	"terraform.unlock_query": "Remove the lock?",
+	"terraform.unlock_desc": "Only remove the lock if the Otto holding it is no longer running.\n" +
+		"Removing a lock that is still in use can corrupt the state.\n" +
+		"Only 'yes' will be accepted.",
+	"terraform.unlock_aborted": "Force-unlock cancelled.",
+	"terraform.unlocked":       "Lock removed.",
+	"terraform.state_copy_err": "(the state could not be copied either: %s)",
+	"terraform.state_history_err": "Warning: the Terraform state was saved, but keeping a\n" +
+		"version of it in the state history failed: %s",
+	"terraform.state_no_history":    "No state versions are stored yet.",
+	"terraform.state_list_header":   "VERSION\tTIME\tOPERATION\tBYTES\tTERRAFORM\t",
+	"terraform.state_current":       "(current)",
+	"terraform.state_version_err":   "Invalid state version: %s",
+	"terraform.state_version_none":  "State version %d doesn't exist. Run `otto infra state list` to see the stored versions.",
+	"terraform.state_restore_query": "Restore state version %d from %s?",
+	"terraform.state_restore_desc": "The current state will be replaced. It stays in the state\n" +
+		"history, so the restore can be undone.\n" +
+		"Only 'yes' will be accepted.",
+	"terraform.state_restore_aborted": "State restore cancelled.",
+	"terraform.state_restore_err":     "Error restoring the Terraform state: %s",
+	"terraform.state_restored": "Restored state version %d as version %d. Run `otto infra`\n" +
+		"to bring the infrastructure in line with the restored state.",
+	"terraform.planning":        "Planning changes to main infrastructure...",
+	"terraform.building_plan":   "Applying saved plan to main infrastructure...",
+	"terraform.plan_summary":    "Plan: %d to add, %d to change, %d to destroy.",
+	"terraform.plan_no_changes": "No changes. The infrastructure is up to date, so no plan was saved.",
+	"terraform.plan_saved": "The plan was saved to %s. To apply exactly this plan, run:\n\n" +
+		"  otto infra -plan=%s\n\n" +
+		"The plan file contains the infrastructure credentials. Keep it private.",
+	"terraform.plan_write_err": "Error saving the plan: %s",
+	"terraform.plan_read_err":  "Error reading the plan %s: %s",
+	"terraform.plan_other_infra": "The plan %s was created for the infrastructure %q,\n" +
+		"not %q. Run `otto infra plan` to create a new plan.",
+	"terraform.plan_stale": "The plan %s is stale: the infrastructure state changed after\n" +
+		"it was created. Run `otto infra plan` to create a new plan.",
+	"terraform.plan_no_history": "Saved plans need the state history to tell whether the state\n" +
+		"changed after the plan was made, but the state history is disabled.",
+	"terraform.checking":        "Checking main infrastructure for drift...",
+	"terraform.state_parse_err": "Error parsing the Terraform state: %s",
+	"terraform.drift_none":      "No drift. The infrastructure matches the stored state.",
+	"terraform.drift_found":     "%d resource(s) changed outside of Otto:",
+	"terraform.drift_changed":   "  ~ %s: %s",
+	"terraform.drift_deleted":   "  - %s: deleted",
+	"terraform.drift_hint": "Run `otto infra plan` to see the changes `otto infra` would make\n" +
+		"to bring the infrastructure back in line with the Appfile.",
+	"terraform.drift_err":       "The infrastructure has drifted from the stored state.",
+	"terraform.output_none":     "The infrastructure has no output named %q.",
+	"terraform.flavors_header":  "Flavors of the %s infrastructure:",
+	"terraform.flavors_current": "  %s (used by this Appfile)",
+	"terraform.interrupting": "Interrupt received. Waiting up to %s for Terraform to finish\n" +
+		"what it is doing and save the state. The state may be partial.\n" +
+		"Interrupt again to stop Terraform immediately.",
+	"terraform.interrupt_force": "Interrupted again. Terraform was stopped. Resources that were\n" +
+		"being changed may not be in the state. Run `otto infra check` to see\n" +
+		"what changed.",
+	"terraform.interrupt_timeout": "Terraform didn't exit within %s and was stopped. Resources\n" +
+		"that were being changed may not be in the state. Run `otto infra check`\n" +
+		"to see what changed.",
+	"terraform.interrupted":     "Terraform was interrupted.",
+	"terraform.installing":      "Installing Terraform %s...",
+	"terraform.installing_from": "Downloading from %s and verifying the SHA256 checksum.",
+	"terraform.install_err":     "Error installing Terraform %s: %s",
+	"terraform.install_no_sum":  "The SHA256SUMS file has no checksum for %s, so it can't be verified.",
+	"terraform.install_checksum_err": "The checksum of %s doesn't match the SHA256SUMS file.\n" +
+		"Expected %s, got %s. The download may be corrupted or tampered\n" +
+		"with, so it was not installed.",
+	"terraform.install_no_binary": "%s doesn't contain a terraform binary.",
+	"terraform.version_invalid":   "Invalid Terraform version %q: %s",
+	"terraform.version_too_old":   "Terraform %s is too old. Otto requires Terraform %s or later.",
+	"terraform.version_changed": "Note: the state was last written by Terraform %s, and is now\n" +
+		"changed with Terraform %s. Older versions of Terraform may not be able\n" +
+		"to read the state afterwards.",
+}
@@ -0,0 +1,72 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// EnvLang 是选择语言的环境变量，例如"en"或者"zh"。没有设置时
+	// 使用系统的locale(LC_ALL、LC_MESSAGES、LANG)
+	EnvLang = "OTTO_LANG"
+
+	// DefaultLang 是没有设置语言或者语言不支持时使用的语言
+	DefaultLang = "en"
+)
+
+// Catalogs 是所有支持的语言的消息目录，key是语言，值是消息ID
+// 到消息的映射。每个语言必须有相同的消息ID
+var Catalogs = map[string]map[string]string{
+	"en": en,
+	"zh": zh,
+}
+
+// Lang 返回当前使用的语言。优先使用OTTO_LANG，然后是系统的locale，
+// 例如"zh_CN.UTF-8"会选择"zh"。不支持的语言使用DefaultLang
+func Lang() string {
+	for _, k := range []string{EnvLang, "LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(k)
+		if v == "" {
+			continue
+		}
+
+		// 只使用语言部分: zh_CN.UTF-8 => zh
+		v = strings.ToLower(v)
+		if idx := strings.IndexAny(v, "_-.@"); idx >= 0 {
+			v = v[:idx]
+		}
+		if _, ok := Catalogs[v]; ok {
+			return v
+		}
+
+		// 设置了但是不支持的语言，不再查看后面的变量
+		return DefaultLang
+	}
+
+	return DefaultLang
+}
+
+// T 返回当前语言中id对应的消息。有args时消息作为fmt.Sprintf的
+// 格式。当前语言没有这个消息时使用DefaultLang，都没有时返回id
+func T(id string, args ...interface{}) string {
+	msg, ok := Catalogs[Lang()][id]
+	if !ok {
+		msg, ok = Catalogs[DefaultLang][id]
+	}
+	if !ok {
+		return id
+	}
+
+	if len(args) == 0 {
+		return msg
+	}
+
+	return fmt.Sprintf(msg, args...)
+}
+
+// Errorf 同T，但是返回一个error
+func Errorf(id string, args ...interface{}) error {
+	return errors.New(T(id, args...))
+}
@@ -0,0 +1,131 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// verbRe 匹配fmt格式中的verb
+var verbRe = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogs(t *testing.T) {
+	for lang, catalog := range Catalogs {
+		for otherLang, other := range Catalogs {
+			if lang == otherLang {
+				continue
+			}
+
+			for id, msg := range catalog {
+				otherMsg, ok := other[id]
+				if !ok {
+					t.Errorf("%s: missing %q (present in %s)", otherLang, id, lang)
+					continue
+				}
+
+				// 每个语言的格式参数必须一致，否则格式化会出错
+				if v1, v2 := verbs(msg), verbs(otherMsg); !reflect.DeepEqual(v1, v2) {
+					t.Errorf("%q: verbs differ: %s %v, %s %v",
+						id, lang, v1, otherLang, v2)
+				}
+			}
+		}
+
+		for id, msg := range catalog {
+			if strings.TrimSpace(msg) == "" {
+				t.Errorf("%s: empty message %q", lang, id)
+			}
+		}
+	}
+}
+
+// TestCatalogs_used 检查源代码中使用的所有消息ID都在目录中
+func TestCatalogs_used(t *testing.T) {
+	re := regexp.MustCompile(`i18n\.(?:T|Errorf)\(\s*"([^"]+)"`)
+	dirs := []string{"../../appfile", "../../otto", "../../command", "../../directory", "../terraform"}
+	count := 0
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		for _, path := range files {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			for _, m := range re.FindAllStringSubmatch(string(data), -1) {
+				count++
+				for lang, catalog := range Catalogs {
+					if _, ok := catalog[m[1]]; !ok {
+						t.Errorf("%s: %s: missing %q", path, lang, m[1])
+					}
+				}
+			}
+		}
+	}
+
+	if count == 0 {
+		t.Fatal("no message IDs found")
+	}
+}
+
+func TestLang(t *testing.T) {
+	cases := []struct {
+		Env      map[string]string
+		Expected string
+	}{
+		{nil, "en"},
+		{map[string]string{"LANG": "zh_CN.UTF-8"}, "zh"},
+		{map[string]string{"LANG": "fr_FR.UTF-8"}, "en"},
+		{map[string]string{"LANG": "zh_CN.UTF-8", "LC_ALL": "C"}, "en"},
+		{map[string]string{"LANG": "en_US.UTF-8", EnvLang: "zh"}, "zh"},
+		{map[string]string{EnvLang: "ZH-cn"}, "zh"},
+	}
+
+	keys := []string{EnvLang, "LC_ALL", "LC_MESSAGES", "LANG"}
+	for _, k := range keys {
+		defer os.Setenv(k, os.Getenv(k))
+	}
+
+	for _, tc := range cases {
+		for _, k := range keys {
+			os.Setenv(k, tc.Env[k])
+		}
+
+		if actual := Lang(); actual != tc.Expected {
+			t.Errorf("%v: expected %s, got %s", tc.Env, tc.Expected, actual)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	defer os.Setenv(EnvLang, os.Getenv(EnvLang))
+
+	os.Setenv(EnvLang, "zh")
+	if actual := T("core.app.load_err", "foo"); actual != "装载App报错: foo" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	os.Setenv(EnvLang, "en")
+	if actual := T("core.app.load_err", "foo"); actual != "Error loading App: foo" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	if actual := T("nope"); actual != "nope" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func verbs(msg string) []string {
+	result := verbRe.FindAllString(msg, -1)
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,348 @@
+package i18n
+
+// zh 是中文的消息目录
+var zh = map[string]string{
+	// otto.Core
+	"core.compile.infra":      "编译 infra...",
+	"core.compile.foundation": "编译foundation: %s",
+	"core.compile.dep":        "编译依赖 '%s'...",
+	"core.compile.main":       "编译主应用...",
+
+	"core.app.load_err":        "装载App报错: %s",
+	"core.app.load_vertex_err": "装载 '%s' 的Appfile报错: %s",
+	"core.app.impl_err":        "获取App实现报错 '%s': %s",
+	"core.app.not_found":       "tuple的app实现没有找到: %s",
+	"core.app.start_err":       "App没有正常启动: %s",
+	"core.app.cache_dir_err":   "创建缓存目录报错 '%s': %s",
+	"core.app.dev_ip_err":      "获取dev IP地址报错: %s",
+
+	"core.build.save_err":    "保存构建数据报错: %s",
+	"core.build.lookup_err":  "查询构建数据报错: %s",
+	"core.deploy.save_err":   "保存部署数据报错: %s",
+	"core.deploy.lookup_err": "查询部署数据报错: %s",
+
+	"core.dev.state_read_err": "读取开发环境状态报错: %s",
+	"core.dev.state_save_err": "保存开发环境状态报错: %s",
+	"core.dev.dep_cached":     "使用缓存的开发依赖 '%s'",
+	"core.dev.dep_building":   "构建开发依赖 '%s'...",
+	"core.dev.dep_build_err":  "构建开发依赖报错 '%s': %s",
+	"core.dev.dep_cache_err":  "缓存开发依赖报错 '%s': %s",
+	"core.dev.not_created": "开发环境还没有创建!\n\n" +
+		"运行'otto dev'创建开发环境，然后再运行这个命令。",
+
+	"core.not_compiled": "应用还没有编译!\n\n" +
+		"Otto没有找到这个应用编译的数据。在Appfile所在的目录运行\n" +
+		"'otto compile'，编译开发、构建和部署应用需要的文件，然后\n" +
+		"再运行这个命令。",
+	"core.hash_err": "计算Appfile hash错误: %s",
+
+	"core.infra.not_found":   "infrastructure在Appfile中没找到: %s",
+	"core.infra.unsupported": "infrastructure类型不支持: %s",
+	"core.infra.lookup_err":  "查询infrastructure数据报错: %s",
+	"core.infra.flavor_err": "%q 不是 %s infrastructure支持的flavor。\n" +
+		"可用的flavor: %s",
+	"core.infra.variable_err": "变量 %q 没有在 %s flavor中声明。\n" +
+		"flavor声明的变量: %s",
+	"core.infra.foundation_build":   "构建foundation的infrastructure: %s",
+	"core.infra.foundation_destroy": "销毁foundation的infrastructure: %s",
+	"core.infra.created_header":     "[green]Infrastructure创建成功!",
+	"core.infra.created": "[green]部署这个应用需要的infrastructure已经可用。\n" +
+		"现在可以用`otto deploy`部署。",
+	"core.infra.destroyed_header": "[green]Infrastructure销毁成功!",
+	"core.infra.destroyed": "[green]运行这个应用以及这个项目中其它应用需要的\n" +
+		"infrastructure已经被销毁。",
+	"core.infra.deployed": "还有应用部署在这个infrastructure上: %s\n\n" +
+		"Otto不会销毁还部署着应用的infrastructure。先对每个应用运行\n" +
+		"'otto deploy destroy'，然后再运行这个命令。",
+	"core.infra.not_ready": "这个应用的infrastructure还没有构建。\n\n" +
+		"部署需要infrastructure已经完全构建。请先运行`otto infra`\n" +
+		"构建infrastructure，然后再运行这个命令。",
+	"core.infra.partial": "这个应用的infrastructure只构建了一部分。\n\n" +
+		"上一次 `otto infra` 没有完成就失败了。部署需要infrastructure已经\n" +
+		"完全构建。请再运行一次 `otto infra`，然后再运行这个命令。",
+	"context.infra_not_created": "这个应用需要infrastructure %q 的输出，\n" +
+		"但是它还没有构建。请先运行 `otto infra`。",
+	"context.infra_partial": "这个应用需要infrastructure %q 的输出，\n" +
+		"但是它只构建了一部分，输出可能不完整。\n" +
+		"请先再运行一次 `otto infra`。",
+
+	"core.foundation.not_found": "tuple的foundation实现没找到: %s",
+
+	// 认证
+	"creds.header": "获取infrastructure认证...",
+	"creds.cached": "找到了缓存的加密infrastructure认证。\n" +
+		"Otto现在会询问解密这些认证的密码。\n\n",
+	"creds.not_found": "没有找到已有的infrastructure认证! Otto现在会询问\n" +
+		"infrastructure认证。认证会被加密保存在磁盘上，这样就不需要\n" +
+		"再次输入。\n\n",
+	"creds.verify_err": "验证infrastructure认证报错: %s",
+	"creds.verify_err_rotate": "验证infrastructure认证报错: %s\n\n" +
+		"如果保存的认证已经失效，运行`otto creds rotate`重新输入认证。",
+	"creds.clear_err":     "删除加密认证报错: %s",
+	"creds.cleared":       "[green]已经删除infrastructure '%s' 的加密认证",
+	"creds.rotate_header": "重新输入infrastructure认证...",
+	"creds.rotate_warning": "重要: 确保新的认证属于同一个账号，否则可能无法再访问\n" +
+		"Otto已经创建的infrastructure。\n\n",
+	"creds.rotated": "[green]已经更新infrastructure '%s' 的加密认证",
+	"creds.read_err": "读取加密认证报错: %s\n\n" +
+		"如果忘记了密码，运行`otto creds clear`删除保存的认证，\n" +
+		"Otto会重新询问认证。",
+	"creds.write_err":  "保存加密认证报错: %s",
+	"creds.pass_query": "加密认证的密码",
+	"creds.pass_desc": "这个密码用来加密和解密Otto保存在磁盘上的infrastructure\n" +
+		"认证。每次读取认证都必须使用同一个密码。设置环境变量\n" +
+		"OTTO_CREDS_PASSWORD可以不再询问密码。",
+	"creds.crypt_format_err":  "加密数据格式错误",
+	"creds.crypt_decrypt_err": "密码错误或者加密数据已经损坏",
+	"creds.pass_empty":        "加密认证的密码不能为空。",
+
+	// 命令
+	"command.core_load_err":   "装载Core报错: %s",
+	"command.appfile_dir_err": "不能确定Appfile目录",
+	"command.not_compiled": "Otto好像还没有编译你的Appfile!\n\n" +
+		"在Appfile所在的目录，或者用`-appfile`参数运行`otto compile`，\n" +
+		"编译开发、构建和部署应用需要的文件。\n\n" +
+		"Appfile编译之后，可以在任何子目录中运行`otto`。",
+	"command.directory_backend_err": "不支持的目录后端: %s (可以是 \"bolt\"、\"file\" 或 \"http\")",
+	"command.directory_address_err": "\"http\" 目录后端需要在 directory_path 中设置服务端地址",
+	"command.directory_remote_err":  "不能共享远程目录，请配置本地的 \"bolt\" 或 \"file\" 后端",
+	"command.directory_listen_err":  "监听 %s 报错: %s",
+	"command.directory_serving":     "在 http://%s 上共享Otto目录",
+	"command.directory_serve_err":   "共享目录报错: %s",
+	"command.directory_stopped":     "目录服务已停止",
+	"command.directory_export_err":  "导出目录报错: %s",
+	"command.directory_exported":    "导出了 %d 项数据到 %s",
+	"command.directory_import_err":  "导入目录报错: %s",
+	"command.directory_imported":    "导入了 %d 项数据，来自 %s",
+	"command.unknown_action":        "未知的动作: %s\n\n%s",
+	"command.flag_kv_err":           "参数格式必须是key=value: %s",
+	"command.version_err":           "检查最终版本报错: %s",
+	"command.version_outdated": "你的Otto版本已经被弃用！最新版是: %s。\n" +
+		"你可以从www.ottoproject.io下载升级",
+
+	"compile.loading":         "装载 Appfile...",
+	"compile.load_err":        "装载Appfile报错: %s",
+	"compile.wd_err":          "加载当前目录报错: %s",
+	"compile.no_appfile_head": "没有发现Appfile! 检测项目信息...",
+	"compile.no_appfile": "没有找到Appfile。没有Appfile时，Otto会尽量检测应用的类型，\n" +
+		"并设置合理的默认值。这是开始使用Otto的好方法，但是我们建议\n" +
+		"之后写一个真正的Appfile，这样可以做更复杂的定制、引用依赖、\n" +
+		"管理版本等等。",
+	"compile.cant_detect": "没有Appfile，Otto也不能自动检测项目类型。\n" +
+		"没有Appfile时Otto会尽量自动检测项目的类型，但是如果项目的结构\n" +
+		"Otto不认识，或者是Otto还不支持的项目类型，检测就会失败。\n\n" +
+		"请创建一个Appfile，至少指定项目的名字和类型。下面是一个最小的\n" +
+		"Appfile例子，指定了应用名字\"my-app\"和项目类型\"go\":\n\n" +
+		"    application {\n" +
+		"\tname = \"my-app\"\n" +
+		"\ttype = \"go\"\n" +
+		"    }\n\n" +
+		"如果你认为Otto应该能够自动检测你的项目类型，请给Otto项目\n" +
+		"提交一个issue。",
+	"compile.detectors_err":  "从 %s 装载detector报错: %s",
+	"compile.deps":           "获取所有的Appfile依赖...",
+	"compile.appfile_err":    "编译Appfile报错: %s",
+	"compile.header":         "编译...",
+	"compile.application":    "应用:           %s (%s)",
+	"compile.project":        "项目:           %s",
+	"compile.infrastructure": "Infrastructure: %s (%s)",
+	"compile.err":            "编译报错: %s",
+	"compile.success_header": "[green]编译成功!",
+	"compile.success": "[green]Otto现在已经可以启动开发环境、部署这个应用、构建需要的\n" +
+		"infrastructure等等。更多信息请查看帮助。\n\n" +
+		"Otto管理应用从开发到部署需要的文件已经放在输出目录中。\n" +
+		"可以手动查看这些文件，了解Otto会做什么。",
+
+	"status.encode_err":          "编码状态报错: %s",
+	"status.appfile_load_err":    "装载编译的Appfile报错: %s",
+	"status.not_compiled_header": "Appfile: [reset]没有编译",
+	"status.not_compiled": "在Appfile所在的目录运行'otto compile'编译Appfile。\n" +
+		"Appfile编译之后才能显示其它组件的状态。",
+	"status.app_header":         "应用信息",
+	"status.application":        "应用:           %s (%s)",
+	"status.project":            "项目:           %s",
+	"status.infrastructure":     "Infrastructure: %s (%s)",
+	"status.component_header":   "组件状态",
+	"status.appfile":            "Appfile:         %s",
+	"status.dev":                "开发环境:        %s",
+	"status.infra":              "Infra:           %s",
+	"status.build":              "构建:            %s (%s)",
+	"status.build_none":         "构建:            [reset]没有构建",
+	"status.deploy_header":      "部署状态",
+	"status.compiled":           "[green]已编译",
+	"status.stale":              "[yellow]已过期 (Appfile已经改变，运行'otto compile')",
+	"status.state.ready":        "[green]就绪",
+	"status.state.success":      "[green]成功",
+	"status.state.deployed":     "[green]已部署",
+	"status.state.halted":       "[yellow]已停止",
+	"status.state.partial":      "[yellow]部分完成",
+	"status.state.drifted":      "[yellow]已漂移 (运行 'otto infra check' 查看详情)",
+	"status.state.failed":       "[red]失败",
+	"status.state.invalid":      "[reset]无效",
+	"status.state.not_deployed": "[reset]没有部署",
+	"status.state.not_created":  "[reset]没有创建",
+
+	"ui.interrupted":   "中断",
+	"ui.hide_not_file": "必须读取一个文件",
+	"ui.default":       "  [bold]默认值:[reset] ",
+	"ui.enter_value":   "  [bold]输入一个值:[reset] ",
+	"ui.input_disabled": "需要输入 '%s'，但是输入已经被禁用 (-input=false 或者 %s)。\n" +
+		"可以用 -var %s=VALUE 提供这个值",
+	"ui.input_disabled_env": "，或者设置环境变量: %s",
+
+	// appfile
+	"appfile.parse_err":         "解析Appfile报错: %s",
+	"appfile.block_err":         "解析Appfile中的 '%s' 报错: %s",
+	"appfile.block_once":        "只能有一个 '%s' 块",
+	"appfile.block_type_err":    "'%s' 必须是一个块",
+	"appfile.duplicate":         "%s '%s' 定义了多次",
+	"appfile.variable_type_err": "变量 %q 必须是字符串、数字或者布尔值",
+
+	// directory
+	"directory.http_not_found": "目录服务器对 %s 返回404。\n" +
+		"请检查目录的地址是不是Otto的目录服务器。",
+	"directory.blob_key_err": "二进制数据的key %q 不正确: key必须是相对路径，\n" +
+		"并且不能有空的、\".\" 或者 \"..\" 的部分。",
+	"directory.lock_timeout": "等待目录锁超时: %s\n\n" +
+		"可能有另一个Otto进程正在写入目录。请等它完成之后重试。",
+	"directory.export_not_lister":          "目录后端不支持列出数据，无法导出",
+	"directory.export_err":                 "导出 %s 报错: %s",
+	"directory.export_blob_gone":           "数据不存在",
+	"directory.import_err":                 "导入 %s 报错: %s",
+	"directory.import_format_err":          "归档中的 %s 格式不正确: %s",
+	"directory.archive_read_err":           "读取归档报错: %s",
+	"directory.archive_file_err":           "归档中有不支持的文件: %s",
+	"directory.archive_manifest_err":       "归档的清单格式不正确: %s",
+	"directory.archive_duplicate":          "归档中有重复的文件: %s",
+	"directory.archive_file_read_err":      "读取归档中的 %s 报错: %s",
+	"directory.archive_no_manifest":        "归档中没有清单 %s，不是Otto目录的归档",
+	"directory.archive_version_err":        "不支持的归档版本: %d (支持的版本: %d)",
+	"directory.archive_key_err":            "清单中的 %s 的key不正确: %s",
+	"directory.archive_type_err":           "清单中的 %s 类型不正确: %s",
+	"directory.archive_manifest_duplicate": "清单中有重复的文件: %s",
+	"directory.archive_missing":            "归档中缺少文件: %s",
+	"directory.archive_checksum_err":       "归档中的 %s 校验和不匹配，归档可能已经损坏",
+	"directory.archive_extra":              "归档中的 %s 不在清单中",
+	"directory.output_type_err":            "未知的输出类型: %q",
+
+	// helper/terraform
+	"terraform.destroying":  "销毁主infrastructure...",
+	"terraform.building":    "构建主infrastructure...",
+	"terraform.info_none":   "Infrastructure还没有创建，没有可以显示的信息。",
+	"terraform.run_err":     "运行Terraform报错: %s",
+	"terraform.executing":   "执行Terraform管理infrastructure...",
+	"terraform.complete":    "Terraform执行完成，保存结果...",
+	"terraform.outputs_err": "读取Terraform输出报错: %s",
+	"terraform.executing_note": "下面是Terraform原始的输出。这些输出不是Otto产生的，\n" +
+		"而是在创建infrastructure的时候直接从Terraform转发的。\n\n" +
+		"Terraform可能会询问输入。对于infrastructure提供者的认证，\n" +
+		"在同一个Otto环境中要一直输入相同的认证。\n\n",
+	"terraform.lookup_err": "查询已有的infrastructure数据报错: %s\n\n" +
+		"这类错误通常是暂时的，重新运行命令就可以解决。其它可能\n" +
+		"的原因是网络或者磁盘问题，需要在Otto之外解决。",
+	"terraform.prepare_err": "准备infrastructure报错: %s\n\n" +
+		"这类错误通常是暂时的，重新运行命令就可以解决。其它可能\n" +
+		"的原因是网络或者磁盘问题，需要在Otto之外解决。",
+	"terraform.store_err": "保存infrastructure数据报错: %s\n\n" +
+		"这表示Otto无法知道你的infrastructure已经创建成功。Otto会尝试\n" +
+		"几次保存infrastructure。目前Otto还不支持从这个错误中恢复，\n" +
+		"你的infrastructure已经脱离了Otto的管理。请向社区寻求帮助。\n\n" +
+		"以后的Otto版本会解决这个问题。",
+	"terraform.not_ready": "读取Terraform输出报错: %s\n\n" +
+		"这种情况下Otto不能认为infrastructure已经就绪。Otto不会丢失\n" +
+		"infrastructure的信息，也许再运行一次`otto infra`就可以了。如果\n" +
+		"问题一直存在，请查看错误信息并向社区寻求帮助。",
+	"terraform.output_read_err": "读取输出 %q 报错: %s",
+	"terraform.output_type_err": "未知的Terraform输出类型: %q",
+	"terraform.state_load_err":  "装载Terraform状态报错: %s",
+	"terraform.state_write_err": "写入Terraform状态报错: %s",
+	"terraform.state_read_err":  "读取要保存的Terraform状态报错: %s",
+	"terraform.state_save_err": "保存Terraform状态失败: %s\n\n" +
+		"这表示Otto无法保存infrastructure的状态。Otto把状态复制到了下面\n" +
+		"的路径，所以状态没有丢失。请保留这个文件！目录恢复正常之后，\n" +
+		"请向社区寻求帮助把状态放回目录。\n\n" +
+		"  %s",
+	"terraform.run_state_err": "%s\n\n" +
+		"另外，这次运行的状态也没能保存:\n\n%s",
+	"terraform.state_history_parse_err": "解析状态历史报错: %s",
+	"terraform.state_locked": "Terraform状态已经被 %s 锁定\n" +
+		"(操作: %s，开始于 %s，过期时间 %s)。\n\n" +
+		"另一个Otto正在修改这个infrastructure。请等它完成之后重试。\n" +
+		"如果那个Otto已经不在运行了，用 `otto infra force-unlock` 删除锁。",
+	"terraform.state_lock_err": "锁定Terraform状态报错: %s",
+	"terraform.state_unlock_err": "释放Terraform状态的锁报错: %s\n\n" +
+		"锁会自己过期，也可以用 `otto infra force-unlock` 删除。",
+	"terraform.lock_unsupported":  "配置的目录后端不支持锁。",
+	"terraform.unlock_not_locked": "infrastructure状态没有被锁定。",
+	"terraform.unlock_holder": "infrastructure状态被 %s 锁定\n" +
+		"(操作: %s，开始于 %s)。",
+	"terraform.unlock_query": "删除这个锁吗?",
+	"terraform.unlock_desc": "只有在持有锁的Otto已经不在运行时才删除锁。\n" +
+		"删除正在使用的锁可能会损坏状态。\n" +
+		"只接受 'yes'。",
+	"terraform.unlock_aborted": "取消了强制解锁。",
+	"terraform.unlocked":       "锁已删除。",
+	"terraform.state_copy_err": "(状态也无法复制: %s)",
+	"terraform.state_history_err": "警告: Terraform状态已经保存，但是保存状态的历史\n" +
+		"版本失败: %s",
+	"terraform.state_no_history":    "还没有保存任何状态版本。",
+	"terraform.state_list_header":   "版本\t时间\t操作\t字节\tTerraform\t",
+	"terraform.state_current":       "(当前)",
+	"terraform.state_version_err":   "状态版本不正确: %s",
+	"terraform.state_version_none":  "状态版本 %d 不存在。运行 `otto infra state list` 查看保存的版本。",
+	"terraform.state_restore_query": "恢复 %d 版的状态 (%s)?",
+	"terraform.state_restore_desc": "当前的状态会被替换。它仍然保存在状态历史中，\n" +
+		"所以可以撤销这次恢复。\n" +
+		"只接受 'yes'。",
+	"terraform.state_restore_aborted": "取消了状态恢复。",
+	"terraform.state_restore_err":     "恢复Terraform状态报错: %s",
+	"terraform.state_restored": "已经把 %d 版的状态恢复为 %d 版。运行 `otto infra`\n" +
+		"让infrastructure和恢复的状态保持一致。",
+	"terraform.planning":        "正在计划对主infrastructure的修改...",
+	"terraform.building_plan":   "正在把保存的plan应用到主infrastructure...",
+	"terraform.plan_summary":    "Plan: 创建 %d 个，修改 %d 个，删除 %d 个资源。",
+	"terraform.plan_no_changes": "没有修改。infrastructure已经是最新的，所以没有保存plan。",
+	"terraform.plan_saved": "plan已经保存到 %s。要应用这个plan，运行:\n\n" +
+		"  otto infra -plan=%s\n\n" +
+		"plan文件中包含infrastructure的凭证，请妥善保管。",
+	"terraform.plan_write_err": "保存plan报错: %s",
+	"terraform.plan_read_err":  "读取plan %s 报错: %s",
+	"terraform.plan_other_infra": "plan %s 是为infrastructure %q 创建的，\n" +
+		"不是 %q。运行 `otto infra plan` 创建新的plan。",
+	"terraform.plan_stale": "plan %s 已经过期: 创建之后infrastructure的状态改变了。\n" +
+		"运行 `otto infra plan` 创建新的plan。",
+	"terraform.plan_no_history": "保存的plan需要状态历史来判断生成plan之后状态是否改变了，\n" +
+		"但是状态历史被禁用了。",
+	"terraform.checking":        "正在检查主infrastructure是否发生了漂移...",
+	"terraform.state_parse_err": "解析Terraform状态报错: %s",
+	"terraform.drift_none":      "没有漂移。infrastructure和保存的状态一致。",
+	"terraform.drift_found":     "有 %d 个资源在Otto之外被修改了:",
+	"terraform.drift_changed":   "  ~ %s: %s",
+	"terraform.drift_deleted":   "  - %s: 已删除",
+	"terraform.drift_hint": "运行 `otto infra plan` 查看 `otto infra` 会做哪些修改\n" +
+		"让infrastructure和Appfile重新保持一致。",
+	"terraform.drift_err":       "infrastructure和保存的状态不一致。",
+	"terraform.output_none":     "infrastructure没有名字是 %q 的输出。",
+	"terraform.flavors_header":  "%s infrastructure支持的flavor:",
+	"terraform.flavors_current": "  %s (这个Appfile使用的)",
+	"terraform.interrupting": "收到中断。最多等待 %s 让Terraform完成正在进行的操作\n" +
+		"并保存状态。保存的状态可能是不完整的。\n" +
+		"再次中断会立即结束Terraform。",
+	"terraform.interrupt_force": "再次收到中断，Terraform已经被强制结束。正在修改的资源\n" +
+		"可能不在状态中。运行 `otto infra check` 查看有哪些变化。",
+	"terraform.interrupt_timeout": "Terraform在 %s 内没有退出，已经被强制结束。正在修改\n" +
+		"的资源可能不在状态中。运行 `otto infra check` 查看有哪些变化。",
+	"terraform.interrupted":     "Terraform被中断了。",
+	"terraform.installing":      "安装Terraform %s...",
+	"terraform.installing_from": "从 %s 下载并验证SHA256校验和。",
+	"terraform.install_err":     "安装Terraform %s报错: %s",
+	"terraform.install_no_sum":  "SHA256SUMS文件中没有 %s 的校验和，无法验证。",
+	"terraform.install_checksum_err": "%s 的校验和和SHA256SUMS文件不一致。\n" +
+		"应该是 %s，实际是 %s。下载的文件可能损坏或者被篡改了，\n" +
+		"所以没有安装。",
+	"terraform.install_no_binary": "%s 中没有terraform程序。",
+	"terraform.version_invalid":   "无效的Terraform版本 %q: %s",
+	"terraform.version_too_old":   "Terraform %s 太旧了。Otto需要Terraform %s 或者更新的版本。",
+	"terraform.version_changed": "注意: 状态上次是Terraform %s 写入的，现在用Terraform %s\n" +
+		"修改。之后旧版本的Terraform可能读不了这个状态。",
+}
@@ -0,0 +1,112 @@
+// redact 包把敏感的值，比如infrastructure的凭证，从日志和输出中
+// 替换掉
+//
+// 敏感的值通过Add在进程范围内注册，之后String、Writer和Ui都会把
+// 它们替换成Placeholder
+package redact
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/otto/ui"
+)
+
+// Placeholder 是替换敏感值的字符串
+const Placeholder = "<sensitive>"
+
+// minLength 是会被替换的值的最小长度。太短的值在普通的输出中也
+// 经常出现，替换它们只会让输出没法读
+const minLength = 4
+
+var (
+	lock     sync.RWMutex
+	values   = make(map[string]struct{})
+	replacer *strings.Replacer
+)
+
+// Add 把值标记为敏感的
+func Add(vs ...string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	changed := false
+	for _, v := range vs {
+		if len(v) < minLength {
+			continue
+		}
+		if _, ok := values[v]; ok {
+			continue
+		}
+
+		values[v] = struct{}{}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+
+	// 先替换长的值，这样一个值包含另一个值的时候整个被替换
+	sorted := make([]string, 0, len(values))
+	for v := range values {
+		sorted = append(sorted, v)
+	}
+	sort.Sort(byLength(sorted))
+
+	pairs := make([]string, 0, len(sorted)*2)
+	for _, v := range sorted {
+		pairs = append(pairs, v, Placeholder)
+	}
+	replacer = strings.NewReplacer(pairs...)
+}
+
+// String 把s中的敏感值替换成Placeholder
+func String(s string) string {
+	lock.RLock()
+	r := replacer
+	lock.RUnlock()
+
+	if r == nil {
+		return s
+	}
+
+	return r.Replace(s)
+}
+
+// Writer 在写入W之前替换敏感值
+//
+// 每次Write分别替换，所以被拆到两次Write中的值不会被替换。log包
+// 每次写入一整条日志，所以可以用于日志
+type Writer struct {
+	W io.Writer
+}
+
+// NewWriter 返回一个替换敏感值的Writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{W: w}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.W, String(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Ui 在输出之前替换敏感值，用来转发外部命令的输出，比如Terraform
+type Ui struct {
+	ui.Ui
+}
+
+func (u *Ui) Header(msg string)  { u.Ui.Header(String(msg)) }
+func (u *Ui) Message(msg string) { u.Ui.Message(String(msg)) }
+func (u *Ui) Raw(msg string)     { u.Ui.Raw(String(msg)) }
+
+type byLength []string
+
+func (s byLength) Len() int           { return len(s) }
+func (s byLength) Less(i, j int) bool { return len(s[i]) > len(s[j]) }
+func (s byLength) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
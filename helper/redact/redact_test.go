@@ -0,0 +1,75 @@
+package redact
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestString(t *testing.T) {
+	Add("secret-key", "secret-key-longer", "abc", "")
+
+	cases := map[string]string{
+		"nothing here":           "nothing here",
+		"key=secret-key":         "key=<sensitive>",
+		"key=secret-key-longer":  "key=<sensitive>",
+		"secret-key secret-key":  "<sensitive> <sensitive>",
+		"short values stay: abc": "short values stay: abc",
+	}
+	for input, expected := range cases {
+		if actual := String(input); actual != expected {
+			t.Fatalf("%q: bad: %q", input, actual)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	Add("writer-secret")
+
+	var buf bytes.Buffer
+	l := log.New(NewWriter(&buf), "", 0)
+	l.Printf("[DEBUG] value: %s", "writer-secret")
+
+	if actual := buf.String(); actual != "[DEBUG] value: <sensitive>\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestUi(t *testing.T) {
+	Add("ui-secret")
+
+	inner := new(testUi)
+	u := &Ui{Ui: inner}
+	u.Header("header ui-secret")
+	u.Message("message ui-secret")
+	u.Raw("raw ui-secret\n")
+
+	expected := []string{
+		"header <sensitive>",
+		"message <sensitive>",
+		"raw <sensitive>\n",
+	}
+	if len(inner.Messages) != len(expected) {
+		t.Fatalf("bad: %#v", inner.Messages)
+	}
+	for i, msg := range inner.Messages {
+		if msg != expected[i] {
+			t.Fatalf("bad: %#v", inner.Messages)
+		}
+	}
+}
+
+// testUi 记录输出的ui.Ui
+type testUi struct {
+	Messages []string
+}
+
+func (u *testUi) Header(msg string)  { u.Messages = append(u.Messages, msg) }
+func (u *testUi) Message(msg string) { u.Messages = append(u.Messages, msg) }
+func (u *testUi) Raw(msg string)     { u.Messages = append(u.Messages, msg) }
+
+func (u *testUi) Input(*ui.InputOpts) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,216 @@
+package terraform
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	execHelper "github.com/hashicorp/otto/helper/exec"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// ResourceDrift 是一个实际情况和保存的状态不一致的资源
+type ResourceDrift struct {
+	// Address 是资源的地址，比如 "aws_instance.web"
+	Address string
+
+	// Deleted 表示资源已经不存在了
+	Deleted bool
+
+	// Attributes 是改变了的属性，按名字排序
+	Attributes []string
+}
+
+// Drift 刷新保存的状态，返回和实际的资源不一致的资源。刷新后的
+// 状态不会写回目录，所以保存的状态不会改变。没有保存的状态时
+// 返回nil
+func (t *Terraform) Drift() ([]*ResourceDrift, error) {
+	return t.DriftContext(stdcontext.Background())
+}
+
+// DriftContext 和Drift一样，但是ctx取消的时候中断Terraform
+func (t *Terraform) DriftContext(ctx stdcontext.Context) ([]*ResourceDrift, error) {
+	// Refresh doesn't change the stored state, but the lock keeps us
+	// from comparing against a state that is being written.
+	unlock, err := t.lock("check")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	stateDir, err := ioutil.TempDir("", "otto-tf")
+	if err != nil {
+		return nil, err
+	}
+	if execHelper.ShouldCleanup() {
+		defer os.RemoveAll(stateDir)
+	}
+	stateOldPath := filepath.Join(stateDir, "state.old")
+	statePath := filepath.Join(stateDir, "state")
+
+	data, err := t.Directory.GetBlob(t.StateId)
+	if err != nil {
+		return nil, i18n.Errorf("terraform.state_load_err", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	err = data.WriteToFile(stateOldPath)
+	data.Close()
+	if err != nil {
+		return nil, i18n.Errorf("terraform.state_write_err", err)
+	}
+
+	command := []string{"refresh", "-no-color"}
+	if len(t.Variables) > 0 {
+		varfile, err := t.varfile()
+		if err != nil {
+			return nil, err
+		}
+		defer t.removeVarfile(varfile)
+
+		command = append(command, "-var-file", varfile)
+	}
+	command = append(command, "-state", stateOldPath, "-state-out", statePath)
+
+	log.Printf("[DEBUG] executing terraform: %v", command)
+	path := "terraform"
+	if t.Path != "" {
+		path = t.Path
+	}
+	cmd := exec.Command(path, command...)
+	cmd.Dir = t.Dir
+	if err := run(ctx, t.ui(), cmd, t.gracefulTimeout()); err != nil {
+		return nil, i18n.Errorf("terraform.run_err", err)
+	}
+
+	before, err := ioutil.ReadFile(stateOldPath)
+	if err != nil {
+		return nil, i18n.Errorf("terraform.state_read_err", err)
+	}
+	after, err := ioutil.ReadFile(statePath)
+	if err != nil {
+		return nil, i18n.Errorf("terraform.state_read_err", err)
+	}
+
+	return StateDrift(before, after)
+}
+
+// StateDrift 比较刷新前后的Terraform状态，返回改变了的资源，按照
+// 地址排序。只比较资源的ID和属性，状态的serial等元数据的变化会被
+// 忽略
+func StateDrift(before, after []byte) ([]*ResourceDrift, error) {
+	old, err := stateResources(before)
+	if err != nil {
+		return nil, err
+	}
+	refreshed, err := stateResources(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*ResourceDrift
+	for addr, r := range old {
+		current, ok := refreshed[addr]
+		if !ok || current.Primary == nil {
+			if r.Primary != nil {
+				result = append(result, &ResourceDrift{Address: addr, Deleted: true})
+			}
+
+			continue
+		}
+		if r.Primary == nil {
+			continue
+		}
+
+		changed := make(map[string]struct{})
+		if r.Primary.ID != current.Primary.ID {
+			changed["id"] = struct{}{}
+		}
+		for k, v := range r.Primary.Attributes {
+			if cv, ok := current.Primary.Attributes[k]; !ok || cv != v {
+				changed[k] = struct{}{}
+			}
+		}
+		for k := range current.Primary.Attributes {
+			if _, ok := r.Primary.Attributes[k]; !ok {
+				changed[k] = struct{}{}
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+
+		drift := &ResourceDrift{Address: addr}
+		for k := range changed {
+			drift.Attributes = append(drift.Attributes, k)
+		}
+		sort.Strings(drift.Attributes)
+		result = append(result, drift)
+	}
+
+	sort.Sort(resourceDriftSlice(result))
+	return result, nil
+}
+
+// tfState 是Terraform状态文件中比较漂移需要的部分
+type tfState struct {
+	Modules []*tfModuleState `json:"modules"`
+}
+
+type tfModuleState struct {
+	Path      []string                    `json:"path"`
+	Resources map[string]*tfResourceState `json:"resources"`
+}
+
+type tfResourceState struct {
+	Primary *tfInstanceState `json:"primary"`
+}
+
+type tfInstanceState struct {
+	ID         string            `json:"id"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// stateResources 读取状态中所有模块的资源，key是资源的完整地址，
+// 比如 "module.vpc.aws_vpc.main"
+func stateResources(data []byte) (map[string]*tfResourceState, error) {
+	result := make(map[string]*tfResourceState)
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return result, nil
+	}
+
+	var state tfState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, i18n.Errorf("terraform.state_parse_err", err)
+	}
+
+	for _, m := range state.Modules {
+		var prefix string
+		for _, p := range m.Path {
+			if p == "root" {
+				continue
+			}
+
+			prefix += "module." + p + "."
+		}
+
+		for name, r := range m.Resources {
+			result[prefix+name] = r
+		}
+	}
+
+	return result, nil
+}
+
+type resourceDriftSlice []*ResourceDrift
+
+func (s resourceDriftSlice) Len() int           { return len(s) }
+func (s resourceDriftSlice) Less(i, j int) bool { return s[i].Address < s[j].Address }
+func (s resourceDriftSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,165 @@
+package terraform
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kuuyee/otto-learn/directory"
+)
+
+const testDriftBefore = `{
+    "version": 1,
+    "serial": 3,
+    "modules": [
+        {
+            "path": ["root"],
+            "resources": {
+                "aws_instance.web": {
+                    "type": "aws_instance",
+                    "primary": {
+                        "id": "i-1",
+                        "attributes": {"ami": "ami-1", "tags.Name": "web"}
+                    }
+                },
+                "aws_eip.web": {
+                    "type": "aws_eip",
+                    "primary": {"id": "eip-1", "attributes": {"instance": "i-1"}}
+                },
+                "aws_security_group.web": {
+                    "type": "aws_security_group",
+                    "primary": {"id": "sg-1", "attributes": {"name": "web"}}
+                }
+            }
+        },
+        {
+            "path": ["root", "vpc"],
+            "resources": {
+                "aws_vpc.main": {
+                    "type": "aws_vpc",
+                    "primary": {"id": "vpc-1", "attributes": {"cidr_block": "10.0.0.0/16"}}
+                }
+            }
+        }
+    ]
+}`
+
+const testDriftAfter = `{
+    "version": 1,
+    "serial": 4,
+    "modules": [
+        {
+            "path": ["root"],
+            "resources": {
+                "aws_instance.web": {
+                    "type": "aws_instance",
+                    "primary": {
+                        "id": "i-1",
+                        "attributes": {"ami": "ami-1", "tags.Name": "changed", "tags.Owner": "bob"}
+                    }
+                },
+                "aws_security_group.web": {
+                    "type": "aws_security_group",
+                    "primary": {"id": "sg-1", "attributes": {"name": "web"}}
+                }
+            }
+        },
+        {
+            "path": ["root", "vpc"],
+            "resources": {
+                "aws_vpc.main": {
+                    "type": "aws_vpc",
+                    "primary": {"id": "vpc-2", "attributes": {"cidr_block": "10.0.0.0/16"}}
+                }
+            }
+        }
+    ]
+}`
+
+func TestStateDrift(t *testing.T) {
+	actual, err := StateDrift([]byte(testDriftBefore), []byte(testDriftAfter))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []*ResourceDrift{
+		{Address: "aws_eip.web", Deleted: true},
+		{Address: "aws_instance.web", Attributes: []string{"tags.Name", "tags.Owner"}},
+		{Address: "module.vpc.aws_vpc.main", Attributes: []string{"id"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStateDrift_none(t *testing.T) {
+	actual, err := StateDrift([]byte(testDriftBefore), []byte(testDriftBefore))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(actual) != 0 {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStateDrift_invalid(t *testing.T) {
+	if _, err := StateDrift([]byte("{"), []byte(testDriftBefore)); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestTerraformDrift(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	err := tf.Directory.PutBlob(tf.StateId, &directory.BlobData{
+		Data: bytes.NewReader([]byte(testDriftBefore)),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = ioutil.WriteFile(
+		filepath.Join(dir, "refreshed.tfstate"), []byte(testDriftAfter), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	drift, err := tf.Drift()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(drift) != 3 {
+		t.Fatalf("bad: %#v", drift)
+	}
+
+	// The refreshed state isn't written back
+	data, err := tf.Directory.GetBlob(tf.StateId)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer data.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(data.Data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if buf.String() != testDriftBefore {
+		t.Fatalf("state changed: %s", buf.String())
+	}
+	testTerraformUnlocked(t, tf)
+}
+
+func TestTerraformDrift_noState(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	drift, err := tf.Drift()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if drift != nil {
+		t.Fatalf("bad: %#v", drift)
+	}
+}
@@ -0,0 +1,134 @@
+package terraform
+
+import (
+	"bufio"
+	stdcontext "context"
+	"io"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/otto/ui"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// DefaultGracefulTimeout 是中断Terraform之后等待它自己退出的默认时间。
+// Terraform收到中断后会完成正在进行的操作并写入状态，这可能需要
+// 一段时间
+const DefaultGracefulTimeout = 5 * time.Minute
+
+// run 执行cmd并把输出转发到Ui。ctx取消的时候给Terraform发送一次中断，
+// 让它完成正在进行的操作并写入状态，超过timeout还没有退出才强制
+// 结束。被取消时返回terraform.interrupted错误
+func run(ctx stdcontext.Context, u ui.Ui, cmd *exec.Cmd, timeout time.Duration) error {
+	outR, outW := io.Pipe()
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			if u != nil {
+				u.Raw(scanner.Text() + "\n")
+			}
+		}
+
+		// 行太长的时候Scanner会停止，剩下的输出也要读完，否则
+		// Terraform会阻塞在写输出上
+		io.Copy(ioutil.Discard, outR)
+	}()
+	defer func() {
+		outW.Close()
+		<-doneCh
+	}()
+
+	cmd.Stdout = outW
+	cmd.Stderr = outW
+	setProcAttr(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-waitCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("[INFO] interrupting terraform, waiting up to %s for it to exit", timeout)
+	if u != nil {
+		u.Message(i18n.T("terraform.interrupting", timeout))
+	}
+	if err := interrupt(cmd.Process); err != nil {
+		log.Printf("[WARN] error interrupting terraform, killing it: %s", err)
+		kill(cmd.Process)
+	}
+
+	// 再次中断的时候不再等待
+	forceCh, _ := ctx.Value(forceKey{}).(<-chan struct{})
+	select {
+	case <-waitCh:
+	case <-forceCh:
+		log.Printf("[WARN] interrupted again, killing terraform")
+		if u != nil {
+			u.Message(i18n.T("terraform.interrupt_force"))
+		}
+		kill(cmd.Process)
+		<-waitCh
+	case <-time.After(timeout):
+		log.Printf("[WARN] terraform didn't exit in %s, killing it", timeout)
+		if u != nil {
+			u.Message(i18n.T("terraform.interrupt_timeout", timeout))
+		}
+		kill(cmd.Process)
+		<-waitCh
+	}
+
+	return i18n.Errorf("terraform.interrupted")
+}
+
+// forceKey 是shutdownContext返回的Context中第二次中断的channel的key
+type forceKey struct{}
+
+// shutdownContext 返回一个在shutdownCh收到消息时取消的Context。
+// shutdownCh再次收到消息时，run不再等待Terraform自己退出而是立即
+// 结束它。shutdownCh为nil时只能用返回的CancelFunc取消
+func shutdownContext(shutdownCh <-chan struct{}) (stdcontext.Context, stdcontext.CancelFunc) {
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	if shutdownCh == nil {
+		return ctx, cancel
+	}
+
+	forceCh := make(chan struct{})
+	ctx = stdcontext.WithValue(ctx, forceKey{}, (<-chan struct{})(forceCh))
+
+	// ctx取消之后还要继续等第二次中断，所以用单独的channel停止
+	stopCh := make(chan struct{})
+	go func() {
+		select {
+		case <-shutdownCh:
+			cancel()
+		case <-stopCh:
+			return
+		}
+
+		select {
+		case <-shutdownCh:
+			close(forceCh)
+		case <-stopCh:
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		cancel()
+		once.Do(func() { close(stopCh) })
+	}
+}
@@ -0,0 +1,26 @@
+// +build !windows
+
+package terraform
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcAttr 让Terraform在自己的进程组中运行。这样终端的Ctrl-C只发给
+// Otto，由Otto转发一次中断。Terraform收到两次中断会立即退出，不写
+// 状态
+func setProcAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interrupt 中断Terraform和它的插件进程
+func interrupt(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGINT)
+}
+
+// kill 强制结束Terraform和它的插件进程
+func kill(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
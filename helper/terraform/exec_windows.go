@@ -0,0 +1,18 @@
+package terraform
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcAttr(cmd *exec.Cmd) {}
+
+// interrupt 在Windows上不能给其它进程发送中断，所以总是返回错误，
+// 调用者会强制结束Terraform
+func interrupt(p *os.Process) error {
+	return p.Signal(os.Interrupt)
+}
+
+func kill(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,439 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/kuuyee/otto-learn/appfile"
+	"github.com/kuuyee/otto-learn/context"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/infrastructure"
+)
+
+const (
+	// testHarnessVersion is the Terraform version the fake is installed as.
+	testHarnessVersion = "0.6.16"
+
+	// fakeConfigFile and fakeLogFile are read and written by the fake
+	// terraform in its working directory.
+	fakeConfigFile = "fake-terraform.json"
+	fakeLogFile    = "fake-terraform.log"
+)
+
+func TestMain(m *testing.M) {
+	// The test binary is installed as a fake terraform by testHarness.
+	// When it runs under that name it acts as terraform instead.
+	if filepath.Base(os.Args[0]) == binaryName() {
+		os.Exit(fakeTerraform(os.Args[1:]))
+	}
+
+	os.Exit(m.Run())
+}
+
+// testHarness runs Infrastructure actions against a fake terraform and an
+// in-memory directory.
+//
+// The fake terraform is this test binary, installed as the pinned
+// Terraform version, so the real Project and Installer code find it. It
+// records every call, reads the -state file and writes the -state-out
+// file like Terraform does. See fakeTerraform.
+type testHarness struct {
+	Infra     *Infrastructure
+	Directory directory.Backend
+	Ui        *testUi
+
+	// Config is the Appfile infrastructure the actions run for.
+	Config *appfile.Infrastructure
+
+	// Dir is the compiled infrastructure directory that terraform runs in.
+	Dir string
+
+	root string
+}
+
+func newTestHarness(t *testing.T) *testHarness {
+	root, err := ioutil.TempDir("", "otto-tf-harness")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	h := &testHarness{
+		Infra: &Infrastructure{
+			Variables: map[string]string{"aws_region": "us-east-1"},
+			Sensitive: []string{"aws_secret_key"},
+		},
+		Directory: new(directory.MemoryBackend),
+		Ui:        new(testUi),
+		Dir:       filepath.Join(root, "compiled"),
+		root:      root,
+		Config: &appfile.Infrastructure{
+			Name:   "aws",
+			Type:   "aws",
+			Flavor: "simple",
+		},
+	}
+	if err := os.MkdirAll(h.Dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Install the fake where Installer looks for the pinned version
+	installDir := filepath.Join(h.installDir(), "terraform-versions", testHarnessVersion)
+	if err := os.MkdirAll(installDir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := testInstallFake(filepath.Join(installDir, binaryName())); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return h
+}
+
+func (h *testHarness) Close() {
+	os.RemoveAll(h.root)
+}
+
+// Context returns the context to execute action with.
+func (h *testHarness) Context(action string, args ...string) *infrastructure.Context {
+	return &infrastructure.Context{
+		Shared: context.Shared{
+			InfraCreds: map[string]string{
+				"aws_access_key": "access",
+				"aws_secret_key": "secret",
+			},
+			Ui:               h.Ui,
+			Directory:        h.Directory,
+			InstallDir:       h.installDir(),
+			TerraformVersion: testHarnessVersion,
+		},
+		Action:     action,
+		ActionArgs: args,
+		Dir:        h.Dir,
+		Infra:      h.Config,
+	}
+}
+
+// Execute executes action and returns its error.
+func (h *testHarness) Execute(action string, args ...string) error {
+	return h.Infra.Execute(h.Context(action, args...))
+}
+
+// Configure sets what the fake terraform does in the next calls.
+func (h *testHarness) Configure(t *testing.T, config *fakeConfig) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(h.Dir, fakeConfigFile), data, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// Calls returns the calls the fake terraform recorded so far.
+func (h *testHarness) Calls(t *testing.T) []*fakeCall {
+	f, err := os.Open(filepath.Join(h.Dir, fakeLogFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var result []*fakeCall
+	dec := json.NewDecoder(f)
+	for {
+		var call fakeCall
+		if err := dec.Decode(&call); err != nil {
+			if err == io.EOF {
+				return result
+			}
+
+			t.Fatalf("err: %s", err)
+		}
+
+		result = append(result, &call)
+	}
+}
+
+// InfraRecord returns the infrastructure stored in the directory.
+func (h *testHarness) InfraRecord(t *testing.T) *directory.Infra {
+	infra, err := h.Directory.GetInfra(&directory.Infra{
+		Lookup: directory.Lookup{Infra: "aws"}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return infra
+}
+
+// State returns the stored Terraform state, or nil if there is none.
+func (h *testHarness) State(t *testing.T) *fakeState {
+	infra := h.InfraRecord(t)
+	if infra == nil {
+		return nil
+	}
+
+	data, err := h.Directory.GetBlob(infra.ID)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if data == nil {
+		return nil
+	}
+	defer data.Close()
+
+	var result fakeState
+	if err := json.NewDecoder(data.Data).Decode(&result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &result
+}
+
+func (h *testHarness) installDir() string {
+	return filepath.Join(h.root, "binaries")
+}
+
+// testInstallFake installs this test binary at path.
+func testInstallFake(path string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.Link(self, path); err == nil {
+		return nil
+	}
+
+	// Hard links don't work across file systems, so copy instead
+	src, err := os.Open(self)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// fakeConfig is what the fake terraform does.
+type fakeConfig struct {
+	// Outputs are the outputs apply writes to the state.
+	Outputs map[string]interface{}
+
+	// Fail makes apply and destroy fail after writing a partial state.
+	Fail bool
+}
+
+// fakeCall is a recorded call of the fake terraform.
+type fakeCall struct {
+	Args []string
+
+	// VarFile is the path of the -var-file and Vars its contents.
+	VarFile string
+	Vars    map[string]string
+
+	// Serial is the serial of the -state file, or 0 without state.
+	Serial int
+}
+
+// fakeState is the part of the Terraform state the fake reads and writes.
+type fakeState struct {
+	Version int                `json:"version"`
+	Serial  int                `json:"serial"`
+	Modules []*fakeModuleState `json:"modules"`
+}
+
+type fakeModuleState struct {
+	Path      []string                    `json:"path"`
+	Outputs   map[string]*fakeOutput      `json:"outputs"`
+	Resources map[string]*tfResourceState `json:"resources"`
+}
+
+type fakeOutput struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// fakeTerraform is the fake terraform. It supports apply, destroy and
+// output with the flags Terraform passes, and returns the exit status.
+// Apply with a saved plan prints the plan file.
+func fakeTerraform(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "no command")
+		return 1
+	}
+
+	var config fakeConfig
+	if data, err := ioutil.ReadFile(fakeConfigFile); err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			fmt.Fprintf(os.Stderr, "bad config: %s\n", err)
+			return 1
+		}
+	}
+
+	call := &fakeCall{Args: args}
+	var statePath, stateOut string
+	var names []string
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "-state", "-state-out", "-var-file", "-out":
+			if i+1 == len(args) {
+				fmt.Fprintf(os.Stderr, "%s needs a value\n", args[i])
+				return 1
+			}
+
+			switch args[i] {
+			case "-state":
+				statePath = args[i+1]
+			case "-state-out":
+				stateOut = args[i+1]
+			case "-var-file":
+				call.VarFile = args[i+1]
+			}
+			i++
+		default:
+			if args[i][0] != '-' {
+				names = append(names, args[i])
+			}
+		}
+	}
+
+	if call.VarFile != "" {
+		data, err := ioutil.ReadFile(call.VarFile)
+		if err == nil {
+			err = json.Unmarshal(data, &call.Vars)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading var file: %s\n", err)
+			return 1
+		}
+	}
+
+	state := &fakeState{Version: 1}
+	if statePath != "" {
+		if data, err := ioutil.ReadFile(statePath); err == nil && len(data) > 0 {
+			if err := json.Unmarshal(data, state); err != nil {
+				fmt.Fprintf(os.Stderr, "error reading state: %s\n", err)
+				return 1
+			}
+		}
+	}
+	call.Serial = state.Serial
+
+	if err := fakeRecord(call); err != nil {
+		fmt.Fprintf(os.Stderr, "error recording call: %s\n", err)
+		return 1
+	}
+
+	root := &fakeModuleState{
+		Path:      []string{"root"},
+		Outputs:   make(map[string]*fakeOutput),
+		Resources: make(map[string]*tfResourceState),
+	}
+	if len(state.Modules) > 0 {
+		root = state.Modules[0]
+	}
+
+	switch args[0] {
+	case "output":
+		keys := make([]string, 0, len(root.Outputs))
+		for k := range root.Outputs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if len(names) > 0 && names[0] != k {
+				continue
+			}
+
+			fmt.Printf("%s = %v\n", k, root.Outputs[k].Value)
+		}
+
+		return 0
+	case "apply":
+		// Terraform can print variable values from a saved plan
+		if len(names) > 0 {
+			data, err := ioutil.ReadFile(names[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error reading plan: %s\n", err)
+				return 1
+			}
+
+			fmt.Printf("plan: %s\n", data)
+		}
+
+		root.Resources["aws_instance.web"] = &tfResourceState{
+			Primary: &tfInstanceState{ID: "i-1"},
+		}
+		root.Outputs = make(map[string]*fakeOutput)
+		for k, v := range config.Outputs {
+			typ := "string"
+			switch v.(type) {
+			case []interface{}:
+				typ = "list"
+			case map[string]interface{}:
+				typ = "map"
+			}
+
+			root.Outputs[k] = &fakeOutput{Type: typ, Value: v}
+		}
+	case "destroy":
+		root.Resources = make(map[string]*tfResourceState)
+		root.Outputs = make(map[string]*fakeOutput)
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported command: %s\n", args[0])
+		return 1
+	}
+
+	state.Serial++
+	state.Modules = []*fakeModuleState{root}
+	if stateOut != "" {
+		data, err := json.Marshal(state)
+		if err == nil {
+			err = ioutil.WriteFile(stateOut, data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing state: %s\n", err)
+			return 1
+		}
+	}
+
+	if config.Fail {
+		fmt.Fprintf(os.Stderr, "Error applying plan: fake failure\n")
+		return 1
+	}
+
+	fmt.Printf("%s complete! Resources: %d\n", args[0], len(root.Resources))
+	return 0
+}
+
+func fakeRecord(call *fakeCall) error {
+	f, err := os.OpenFile(fakeLogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = json.NewEncoder(f).Encode(call)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -1,15 +1,33 @@
 package terraform
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
-	"github.com/hashicorp/otto/directory"
 	"github.com/hashicorp/otto/helper/bindata"
 	"github.com/hashicorp/otto/helper/router"
+	"github.com/hashicorp/otto/ui"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/kuuyee/otto-learn/helper/redact"
 	"github.com/kuuyee/otto-learn/infrastructure"
 )
 
+// EnvVarPrefix 是设置Terraform变量的环境变量的前缀，和Terraform自己
+// 使用的一样。环境变量的值覆盖Infrastructure.Variables中的默认值
+const EnvVarPrefix = "TF_VAR_"
+
 // Infrastructure 实现了infrastructure.Infrastructure以及
 // 一个高层框架用Tarraform来实现infrastructure实现
 //
@@ -35,6 +53,10 @@ type Infrastructure struct {
 
 	// Variables是传递给Terraform的附加参数
 	Variables map[string]string
+
+	// Sensitive 是值需要保密的变量的名字，比如凭证。这些值在日志和
+	// Terraform的输出中会被替换掉
+	Sensitive []string
 }
 
 func (i *Infrastructure) Creds(ctx *infrastructure.Context) (map[string]string, error) {
@@ -53,11 +75,36 @@ func (i *Infrastructure) Execute(ctx *infrastructure.Context) error {
 				SynopsisText: infraApplySyn,
 				HelpText:     strings.TrimSpace(infraApplyHelp),
 			},
+			"plan": &router.SimpleAction{
+				ExecuteFunc:  i.actionPlan,
+				SynopsisText: infraPlanSyn,
+				HelpText:     strings.TrimSpace(infraPlanHelp),
+			},
+			"check": &router.SimpleAction{
+				ExecuteFunc:  i.actionCheck,
+				SynopsisText: infraCheckSyn,
+				HelpText:     strings.TrimSpace(infraCheckHelp),
+			},
 			"destroy": &router.SimpleAction{
 				ExecuteFunc:  i.actionDestroy,
 				SynopsisText: infraDestroySyn,
 				HelpText:     strings.TrimSpace(infraDestroyHelp),
 			},
+			"force-unlock": &router.SimpleAction{
+				ExecuteFunc:  i.actionForceUnlock,
+				SynopsisText: infraForceUnlockSyn,
+				HelpText:     strings.TrimSpace(infraForceUnlockHelp),
+			},
+			"state": &router.SimpleAction{
+				ExecuteFunc:  i.actionState,
+				SynopsisText: infraStateSyn,
+				HelpText:     strings.TrimSpace(infraStateHelp),
+			},
+			"flavors": &router.SimpleAction{
+				ExecuteFunc:  i.actionFlavors,
+				SynopsisText: infraFlavorsSyn,
+				HelpText:     strings.TrimSpace(infraFlavorsHelp),
+			},
 			"info": &router.SimpleAction{
 				ExecuteFunc:  i.actionInfo,
 				SynopsisText: infraInfoSyn,
@@ -69,83 +116,611 @@ func (i *Infrastructure) Execute(ctx *infrastructure.Context) error {
 }
 
 func (i *Infrastructure) actionDestroy(rctx router.Context) error {
-	rctx.UI().Header("Destroying main infrastructure...")
+	rctx.UI().Header(i18n.T("terraform.destroying"))
 	ctx := rctx.(*infrastructure.Context)
-	return i.execute(ctx, "destroy", "-force")
+	return i.execute(ctx, "", "destroy", "-force")
 }
 
 func (i *Infrastructure) actionApply(rctx router.Context) error {
-	rctx.UI().Header("Building main infrastructure...")
 	ctx := rctx.(*infrastructure.Context)
-	return i.execute(ctx, "apply")
+
+	// `otto infra -plan=PATH` applies a plan saved by `otto infra plan`
+	var planPath string
+	for _, arg := range ctx.ActionArgs {
+		if !strings.HasPrefix(arg, "-plan=") {
+			return errors.New(strings.TrimSpace(infraApplyHelp))
+		}
+
+		planPath = arg[len("-plan="):]
+	}
+
+	if planPath != "" {
+		rctx.UI().Header(i18n.T("terraform.building_plan"))
+	} else {
+		rctx.UI().Header(i18n.T("terraform.building"))
+	}
+	return i.execute(ctx, planPath, "apply")
 }
 
-func (i *Infrastructure) actionInfo(rctx router.Context) error {
+func (i *Infrastructure) actionPlan(rctx router.Context) error {
 	ctx := rctx.(*infrastructure.Context)
+
+	planPath := DefaultPlanPath
+	for _, arg := range ctx.ActionArgs {
+		if !strings.HasPrefix(arg, "-out=") {
+			return errors.New(strings.TrimSpace(infraPlanHelp))
+		}
+
+		planPath = arg[len("-out="):]
+	}
+	planPath, err := filepath.Abs(planPath)
+	if err != nil {
+		return i18n.Errorf("terraform.plan_write_err", err)
+	}
+
+	ctx.Ui.Header(i18n.T("terraform.planning"))
+
 	project, err := Project(&ctx.Shared)
 	if err != nil {
 		return err
 	}
 
+	// The plan is made against the stored state. If the infrastructure
+	// was never created there is no state and everything will be added.
 	lookup := directory.Lookup{Infra: ctx.Infra.Name}
 	infra, err := ctx.Directory.GetInfra(&directory.Infra{Lookup: lookup})
 	if err != nil {
-		return fmt.Errorf(
-			"Error looking up existing infrastructure data: %s\n\n"+
-				"These errors are usually transient and can be fixed by retrying\n"+
-				"the command. Additional causes of errors are networking or disk\n"+
-				"issues that can be resolved external to Otto.",
-			err)
+		return i18n.Errorf("terraform.lookup_err", err)
+	}
+
+	// Capture the output so we can summarize the plan
+	output := &captureUi{Ui: ctx.Ui}
+	tf := &Terraform{
+		Path:      project.Path(),
+		Dir:       ctx.Dir,
+		Ui:        output,
+		Variables: i.vars(ctx),
+		Sensitive: i.Sensitive,
+	}
+
+	// Remember which state the plan was made for so that it can't be
+	// applied after the state changed.
+	meta := &PlanMeta{
+		Infra:   ctx.Infra.Name,
+		Created: time.Now().UTC(),
+	}
+	if infra != nil {
+		tf.Directory = ctx.Directory
+		tf.StateId = infra.ID
+
+		meta.StateId = infra.ID
+		meta.StateVersion, err = currentVersion(tf.History())
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx.Ui.Header(i18n.T("terraform.executing"))
+	ctx.Ui.Message(i18n.T("terraform.executing_note"))
+
+	// Remove an older plan first so that a failed plan doesn't leave it
+	// behind looking like the result.
+	removePlan(planPath)
+	runCtx, cancel := shutdownContext(ctx.ShutdownCh)
+	defer cancel()
+	if err := tf.ExecuteContext(runCtx, "plan", "-no-color", "-out", planPath); err != nil {
+		removePlan(planPath)
+		return err
+	}
+
+	// The plan contains the variables, including the credentials
+	if err := os.Chmod(planPath, 0600); err != nil {
+		removePlan(planPath)
+		return i18n.Errorf("terraform.plan_write_err", err)
+	}
+
+	if strings.Contains(output.String(), "No changes") {
+		removePlan(planPath)
+		ctx.Ui.Header(i18n.T("terraform.plan_no_changes"))
+		return nil
+	}
+
+	summary := ParsePlan(output.String())
+	ctx.Ui.Header(i18n.T("terraform.plan_summary",
+		len(summary.Add)+len(summary.Replace),
+		len(summary.Change),
+		len(summary.Destroy)+len(summary.Replace)))
+	for _, group := range []struct {
+		Prefix    string
+		Resources []string
+	}{
+		{"+", summary.Add},
+		{"~", summary.Change},
+		{"-", summary.Destroy},
+		{"-/+", summary.Replace},
+	} {
+		for _, r := range group.Resources {
+			ctx.Ui.Message(fmt.Sprintf("  %s %s", group.Prefix, r))
+		}
+	}
+
+	if err := WritePlanMeta(planPath, meta); err != nil {
+		removePlan(planPath)
+		return i18n.Errorf("terraform.plan_write_err", err)
+	}
+
+	ctx.Ui.Message("")
+	ctx.Ui.Message(i18n.T("terraform.plan_saved", planPath, planPath))
+	return nil
+}
+
+func (i *Infrastructure) actionCheck(rctx router.Context) error {
+	ctx := rctx.(*infrastructure.Context)
+	ctx.Ui.Header(i18n.T("terraform.checking"))
+
+	project, err := Project(&ctx.Shared)
+	if err != nil {
+		return err
+	}
+
+	lookup := directory.Lookup{Infra: ctx.Infra.Name}
+	infra, err := ctx.Directory.GetInfra(&directory.Infra{Lookup: lookup})
+	if err != nil {
+		return i18n.Errorf("terraform.lookup_err", err)
 	}
 	if infra == nil {
-		return fmt.Errorf("Infrastructure not created. Nothing to display.")
+		return i18n.Errorf("terraform.info_none")
 	}
 
 	tf := &Terraform{
 		Path:      project.Path(),
 		Dir:       ctx.Dir,
 		Ui:        ctx.Ui,
+		Variables: i.vars(ctx),
+		Sensitive: i.Sensitive,
 		Directory: ctx.Directory,
 		StateId:   infra.ID,
 	}
 
-	// Start the Terraform command
-	args := make([]string, len(ctx.ActionArgs)+1)
-	args[0] = "output"
-	copy(args[1:], ctx.ActionArgs)
-	if err := tf.Execute(args...); err != nil {
-		return fmt.Errorf("Error running Terraform: %s", err)
+	ctx.Ui.Header(i18n.T("terraform.executing"))
+	ctx.Ui.Message(i18n.T("terraform.executing_note"))
+
+	runCtx, cancel := shutdownContext(ctx.ShutdownCh)
+	defer cancel()
+	drift, err := tf.DriftContext(runCtx)
+	if err != nil {
+		return err
+	}
+
+	// Record the result so `otto status` can show it. A partially
+	// created infrastructure stays partial, only `otto infra` fixes that.
+	state := infra.State
+	switch {
+	case len(drift) > 0 && infra.State == directory.InfraStateReady:
+		state = directory.InfraStateDrifted
+	case len(drift) == 0 && infra.State == directory.InfraStateDrifted:
+		state = directory.InfraStateReady
 	}
+	if state != infra.State {
+		infra.State = state
+		if err := ctx.Directory.PutInfra(infra); err != nil {
+			return i18n.Errorf("terraform.store_err", err)
+		}
+	}
+
+	if len(drift) == 0 {
+		ctx.Ui.Header(i18n.T("terraform.drift_none"))
+		return nil
+	}
+
+	ctx.Ui.Header(i18n.T("terraform.drift_found", len(drift)))
+	for _, d := range drift {
+		if d.Deleted {
+			ctx.Ui.Message(i18n.T("terraform.drift_deleted", d.Address))
+		} else {
+			ctx.Ui.Message(i18n.T("terraform.drift_changed",
+				d.Address, strings.Join(d.Attributes, ", ")))
+		}
+	}
+	ctx.Ui.Message("")
+	ctx.Ui.Message(i18n.T("terraform.drift_hint"))
+
+	// Exit non-zero so the check can be used in scripts
+	return i18n.Errorf("terraform.drift_err")
+}
+
+func (i *Infrastructure) actionFlavors(rctx router.Context) error {
+	ctx := rctx.(*infrastructure.Context)
+	ctx.Ui.Header(i18n.T("terraform.flavors_header", ctx.Infra.Type))
+	for _, f := range i.Flavors() {
+		if f == ctx.Infra.Flavor {
+			ctx.Ui.Message(i18n.T("terraform.flavors_current", f))
+		} else {
+			ctx.Ui.Message(fmt.Sprintf("  %s", f))
+		}
+	}
+
 	return nil
 }
 
-func (i *Infrastructure) execute(ctx *infrastructure.Context, command ...string) error {
+func (i *Infrastructure) actionInfo(rctx router.Context) error {
+	ctx := rctx.(*infrastructure.Context)
+	lookup := directory.Lookup{Infra: ctx.Infra.Name}
+	infra, err := ctx.Directory.GetInfra(&directory.Infra{Lookup: lookup})
+	if err != nil {
+		return i18n.Errorf("terraform.lookup_err", err)
+	}
+	if infra == nil {
+		return i18n.Errorf("terraform.info_none")
+	}
+
+	// -json prints the typed outputs saved in the directory, so it
+	// doesn't need Terraform at all.
+	var args []string
+	jsonOutput := false
+	for _, arg := range ctx.ActionArgs {
+		if arg == "-json" {
+			jsonOutput = true
+			continue
+		}
+
+		args = append(args, arg)
+	}
+	if jsonOutput {
+		return infoJSON(ctx, infra, args)
+	}
+
 	project, err := Project(&ctx.Shared)
 	if err != nil {
 		return err
 	}
 
-	// Build the variables
+	// Outputs may contain the credentials, even though Terraform doesn't
+	// need the variables to read them.
+	i.redactVars(i.vars(ctx))
+	tf := &Terraform{
+		Path:      project.Path(),
+		Dir:       ctx.Dir,
+		Ui:        ctx.Ui,
+		Directory: ctx.Directory,
+		StateId:   infra.ID,
+	}
+
+	// Start the Terraform command
+	runCtx, cancel := shutdownContext(ctx.ShutdownCh)
+	defer cancel()
+	args = append([]string{"output"}, args...)
+	if err := tf.ExecuteContext(runCtx, args...); err != nil {
+		return i18n.Errorf("terraform.run_err", err)
+	}
+	return nil
+}
+
+// infoJSON 把infrastructure的输出以JSON输出。args有一个名字时只输出
+// 这个输出
+func infoJSON(ctx *infrastructure.Context, infra *directory.Infra, args []string) error {
+	var v interface{}
+	switch len(args) {
+	case 0:
+		outputs := infra.AllOutputs()
+		if outputs == nil {
+			outputs = directory.Outputs{}
+		}
+		v = outputs
+	case 1:
+		output := infra.Output(args[0])
+		if output == nil {
+			return i18n.Errorf("terraform.output_none", args[0])
+		}
+		v = output
+	default:
+		return errors.New(strings.TrimSpace(infraInfoHelp))
+	}
+
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	ctx.Ui.Raw(string(data) + "\n")
+	return nil
+}
+
+func (i *Infrastructure) actionForceUnlock(rctx router.Context) error {
+	ctx := rctx.(*infrastructure.Context)
+	locker, ok := ctx.Directory.(directory.Locker)
+	if !ok {
+		return i18n.Errorf("terraform.lock_unsupported")
+	}
+
+	lookup := directory.Lookup{Infra: ctx.Infra.Name}
+	infra, err := ctx.Directory.GetInfra(&directory.Infra{Lookup: lookup})
+	if err != nil {
+		return i18n.Errorf("terraform.lookup_err", err)
+	}
+	if infra == nil {
+		return i18n.Errorf("terraform.info_none")
+	}
+
+	l, err := locker.GetLock(infra.ID)
+	if err != nil {
+		return i18n.Errorf("terraform.state_lock_err", err)
+	}
+	if l == nil {
+		ctx.Ui.Message(i18n.T("terraform.unlock_not_locked"))
+		return nil
+	}
+
+	ctx.Ui.Message(i18n.T("terraform.unlock_holder",
+		l.Holder, l.Operation, l.Created.Format(time.RFC1123)))
+
+	// Removing a lock that is still in use can corrupt the state, so
+	// ask first unless -force was given.
+	force := false
+	for _, arg := range ctx.ActionArgs {
+		if arg == "-force" {
+			force = true
+		}
+	}
+	if !force {
+		v, err := ctx.Ui.Input(&ui.InputOpts{
+			Id:          "force_unlock",
+			Query:       i18n.T("terraform.unlock_query"),
+			Description: i18n.T("terraform.unlock_desc"),
+		})
+		if err != nil {
+			return err
+		}
+		if v != "yes" {
+			return i18n.Errorf("terraform.unlock_aborted")
+		}
+	}
+
+	if err := locker.ForceUnlock(infra.ID); err != nil {
+		return i18n.Errorf("terraform.state_lock_err", err)
+	}
+
+	ctx.Ui.Header(i18n.T("terraform.unlocked"))
+	return nil
+}
+
+func (i *Infrastructure) actionState(rctx router.Context) error {
+	ctx := rctx.(*infrastructure.Context)
+	args := ctx.ActionArgs
+	if len(args) == 0 {
+		return errors.New(strings.TrimSpace(infraStateHelp))
+	}
+
+	lookup := directory.Lookup{Infra: ctx.Infra.Name}
+	infra, err := ctx.Directory.GetInfra(&directory.Infra{Lookup: lookup})
+	if err != nil {
+		return i18n.Errorf("terraform.lookup_err", err)
+	}
+	if infra == nil {
+		return i18n.Errorf("terraform.info_none")
+	}
+
+	tf := &Terraform{
+		Ui:        ctx.Ui,
+		Directory: ctx.Directory,
+		StateId:   infra.ID,
+	}
+	history := tf.History()
+
+	switch args[0] {
+	case "list":
+		versions, err := history.List()
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			ctx.Ui.Message(i18n.T("terraform.state_no_history"))
+			return nil
+		}
+
+		var buf bytes.Buffer
+		w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("terraform.state_list_header"))
+		for idx, v := range versions {
+			current := ""
+			if idx == len(versions)-1 {
+				current = i18n.T("terraform.state_current")
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n",
+				v.Version, v.Time.Local().Format("2006-01-02 15:04:05"),
+				v.Operation, v.Size, v.TerraformVersion, current)
+		}
+		w.Flush()
+		ctx.Ui.Raw(buf.String())
+
+	case "show":
+		v, err := stateVersionArg(args)
+		if err != nil {
+			return err
+		}
+
+		_, data, err := history.Get(v)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return i18n.Errorf("terraform.state_version_none", v)
+		}
+		defer data.Close()
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, data.Data); err != nil {
+			return err
+		}
+		ctx.Ui.Raw(buf.String())
+
+	case "restore":
+		v, err := stateVersionArg(args)
+		if err != nil {
+			return err
+		}
+
+		sv, data, err := history.Get(v)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return i18n.Errorf("terraform.state_version_none", v)
+		}
+		data.Close()
+
+		// Restoring replaces the current state, so ask first unless
+		// -force was given.
+		force := false
+		for _, arg := range args[2:] {
+			if arg == "-force" {
+				force = true
+			}
+		}
+		if !force {
+			answer, err := ctx.Ui.Input(&ui.InputOpts{
+				Id: "state_restore",
+				Query: i18n.T("terraform.state_restore_query",
+					v, sv.Time.Local().Format("2006-01-02 15:04:05")),
+				Description: i18n.T("terraform.state_restore_desc"),
+			})
+			if err != nil {
+				return err
+			}
+			if answer != "yes" {
+				return i18n.Errorf("terraform.state_restore_aborted")
+			}
+		}
+
+		// Hold the state lock so nobody runs Terraform while we change
+		// the state under them.
+		unlock, err := tf.lock("state restore")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		restored, err := history.Restore(v)
+		if err != nil {
+			return i18n.Errorf("terraform.state_restore_err", err)
+		}
+
+		newVersion := 0
+		if restored != nil {
+			newVersion = restored.Version
+		}
+		ctx.Ui.Header(i18n.T("terraform.state_restored", v, newVersion))
+
+	default:
+		return errors.New(strings.TrimSpace(infraStateHelp))
+	}
+
+	return nil
+}
+
+// stateVersionArg 解析 `otto infra state show|restore VERSION` 的版本
+func stateVersionArg(args []string) (int, error) {
+	if len(args) < 2 {
+		return 0, errors.New(strings.TrimSpace(infraStateHelp))
+	}
+
+	v, err := strconv.Atoi(args[1])
+	if err != nil || v <= 0 {
+		return 0, i18n.Errorf("terraform.state_version_err", args[1])
+	}
+
+	return v, nil
+}
+
+// vars 返回传递给Terraform的变量。优先级从低到高是凭证、Variables中
+// 的默认值、环境变量 "TF_VAR_<名字>" 和Appfile中设置的变量
+func (i *Infrastructure) vars(ctx *infrastructure.Context) map[string]string {
 	vars := make(map[string]string)
 	for k, v := range ctx.InfraCreds {
 		vars[k] = v
 	}
 	for k, v := range i.Variables {
+		// Terraform自己也读取TF_VAR_，但是var文件中的值优先，所以
+		// 默认值要在这里被环境变量覆盖
+		if env, ok := os.LookupEnv(EnvVarPrefix + k); ok {
+			v = env
+		}
+
 		vars[k] = v
 	}
+	if ctx.Infra != nil {
+		for k, v := range ctx.Infra.Variables {
+			vars[k] = v
+		}
+	}
+
+	return vars
+}
+
+// redactVars 把vars中Sensitive的变量的值注册为敏感的，这样Terraform
+// 没有通过var文件收到这些值的时候，它们也会从输出和日志中被替换
+func (i *Infrastructure) redactVars(vars map[string]string) {
+	for _, k := range i.Sensitive {
+		redact.Add(vars[k])
+	}
+}
+
+// execute 执行修改infrastructure的Terraform命令并保存结果。planPath
+// 不为空时应用这个保存的plan
+func (i *Infrastructure) execute(ctx *infrastructure.Context, planPath string, command ...string) error {
+	project, err := Project(&ctx.Shared)
+	if err != nil {
+		return err
+	}
+
+	// Build the variables. Their sensitive values are registered now,
+	// since a saved plan is applied without them but still prints them.
+	vars := i.vars(ctx)
+	i.redactVars(vars)
 
 	// Setup the lookup information and query the existing infra so we
 	// can get our UUID for storing data.
 	lookup := directory.Lookup{Infra: ctx.Infra.Name}
 	infra, err := ctx.Directory.GetInfra(&directory.Infra{Lookup: lookup})
 	if err != nil {
-		return fmt.Errorf(
-			"Error looking up existing infrastructure data: %s\n\n"+
-				"These errors are usually transient and can be fixed by retrying\n"+
-				"the command. Additional causes of errors are networking or disk\n"+
-				"issues that can be resolved external to Otto.",
-			err)
+		return i18n.Errorf("terraform.lookup_err", err)
+	}
+
+	// A saved plan can only be applied to the state it was made for.
+	// It is checked here so a bad plan fails before anything changes,
+	// and again by Terraform while it holds the state lock, since the
+	// state may change in between. The plan already contains the
+	// variables, so no var file is passed.
+	var planCheck func() error
+	if planPath != "" {
+		current := &PlanMeta{Infra: ctx.Infra.Name}
+		if infra != nil {
+			current.StateId = infra.ID
+		}
+
+		planCheck = func() error {
+			if current.StateId != "" {
+				v, err := currentVersion(&StateHistory{
+					Directory: ctx.Directory,
+					StateId:   current.StateId,
+				})
+				if err != nil {
+					return err
+				}
+				current.StateVersion = v
+			}
+
+			return checkPlan(planPath, current)
+		}
+		if err := planCheck(); err != nil {
+			return err
+		}
+
+		vars = nil
+		command = append(command, planPath)
 	}
+
 	if infra == nil {
 		// If we don't have an infra, create one
 		infra = &directory.Infra{Lookup: lookup}
@@ -153,12 +728,7 @@ func (i *Infrastructure) execute(ctx *infrastructure.Context, command ...string)
 
 		// Put the infrastructure so we can get the UUID to use for our state
 		if err := ctx.Directory.PutInfra(infra); err != nil {
-			return fmt.Errorf(
-				"Error preparing infrastructure: %s\n\n"+
-					"These errors are usually transient and can be fixed by retrying\n"+
-					"the command. Additional causes of errors are networking or disk\n"+
-					"issues that can be resolved external to Otto.",
-				err)
+			return i18n.Errorf("terraform.prepare_err", err)
 		}
 	}
 
@@ -166,42 +736,56 @@ func (i *Infrastructure) execute(ctx *infrastructure.Context, command ...string)
 	tf := &Terraform{
 		Path:      project.Path(),
 		Dir:       ctx.Dir,
+		Version:   ProjectVersion(project),
 		Ui:        ctx.Ui,
 		Variables: vars,
+		Sensitive: i.Sensitive,
 		Directory: ctx.Directory,
 		StateId:   infra.ID,
+		Check:     planCheck,
 	}
 
-	ctx.Ui.Header("Executing Terraform to manage infrastructure...")
-	ctx.Ui.Message(
-		"Raw Terraform output will begin streaming in below. Otto\n" +
-			"does not create this output. It is mirrored directly from\n" +
-			"Terraform while the infrastructure is being created.\n\n" +
-			"Terraform may ask for input. For infrastructure provider\n" +
-			"credentials, be sure to enter the same credentials\n" +
-			"consistently within the same Otto environment." +
-			"\n\n")
+	ctx.Ui.Header(i18n.T("terraform.executing"))
+	ctx.Ui.Message(i18n.T("terraform.executing_note"))
 
-	// Start the Terraform command
-	err = tf.Execute(command...)
+	// 状态是另一个版本的Terraform写入的时候提醒用户，新版本写入
+	// 的状态旧版本可能读不了
+	if v, err := lastTerraformVersion(tf.History()); err == nil && v != "" &&
+		tf.Version != "" && v != tf.Version {
+		ctx.Ui.Message(i18n.T("terraform.version_changed", v, tf.Version))
+	}
+
+	// Start the Terraform command. An interrupt stops Terraform
+	// gracefully, and the partial state is stored like after any
+	// other error.
+	runCtx, cancel := shutdownContext(ctx.ShutdownCh)
+	defer cancel()
+	err = tf.ExecuteContext(runCtx, command...)
 	if err != nil {
-		err = fmt.Errorf("Error running Terraform: %s", err)
+		err = i18n.Errorf("terraform.run_err", err)
 		infra.State = directory.InfraStatePartial
 	}
 
-	ctx.Ui.Header("Terraform execution complete. Saving results...")
+	ctx.Ui.Header(i18n.T("terraform.complete"))
 
 	if err == nil {
 		if ctx.Action == "destroy" {
 			// If we just destroyed successfully, the infra is now empty.
 			infra.State = directory.InfraStateInvalid
-			infra.Outputs = map[string]string{}
+			infra.SetOutputs(directory.Outputs{})
 		} else {
 			// If an apply was successful, populate the state and outputs.
+			// A plan can only be applied once, so remove it.
+			if planPath != "" {
+				removePlan(planPath)
+			}
+
 			infra.State = directory.InfraStateReady
-			infra.Outputs, err = tf.Outputs()
+			var outputs directory.Outputs
+			outputs, err = tf.TypedOutputs()
+			infra.SetOutputs(outputs)
 			if err != nil {
-				err = fmt.Errorf("Error reading Terraform outputs: %s", err)
+				err = i18n.Errorf("terraform.outputs_err", err)
 				infra.State = directory.InfraStatePartial
 			}
 		}
@@ -209,61 +793,155 @@ func (i *Infrastructure) execute(ctx *infrastructure.Context, command ...string)
 
 	// Save the infrastructure information
 	if err := ctx.Directory.PutInfra(infra); err != nil {
-		return fmt.Errorf(
-			"Error storing infrastructure data: %s\n\n"+
-				"This means that Otto won't be able to know that your infrastructure\n"+
-				"was successfully created. Otto tries a few times to save the\n"+
-				"infrastructure. At this point in time, Otto doesn't support gracefully\n"+
-				"recovering from this error. Your infrastructure is now orphaned from\n"+
-				"Otto's management. Please reference the community for help.\n\n"+
-				"A future version of Otto will resolve this.",
-			err)
+		return i18n.Errorf("terraform.store_err", err)
 	}
 
 	// If there was an error during the process, then return that.
 	if err != nil {
-		return fmt.Errorf("Error reading Terraform outputs: %s\n\n"+
-			"In this case, Otto is unable to consider the infrastructure ready.\n"+
-			"Otto won't lose your infrastructure information. You may just need\n"+
-			"to run `otto infra` again and it may work. If this problem persists,\n"+
-			"please see the error message and consult the community for help.",
-			err)
+		return i18n.Errorf("terraform.not_ready", err)
 	}
 
 	return nil
 }
 
-// TODO: test
 func (i *Infrastructure) Compile(ctx *infrastructure.Context) (*infrastructure.CompileResult, error) {
 
 	if err := i.Bindata.CopyDir(ctx.Dir, "data/"+ctx.Infra.Flavor); err != nil {
-		fmt.Printf("[KuuYee]====> 编译terraform 报错\n")
 		return nil, err
 	}
 
 	return nil, nil
 }
 
-// TODO: impl and test
+// FlavorVariables 返回flavor的Terraform配置中声明的变量，按字母排序。
+// 变量一般声明在variables.tf中，但是 "data/<flavor>" 中所有的.tf文件
+// 都会被读取
+func (i *Infrastructure) FlavorVariables(flavor string) []string {
+	if i.Bindata == nil || i.Bindata.AssetDir == nil {
+		return nil
+	}
+
+	dir := "data/" + flavor
+	names, err := i.Bindata.AssetDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	result := make([]string, 0)
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".tf") {
+			continue
+		}
+
+		data, err := i.Bindata.Asset(dir + "/" + name)
+		if err != nil {
+			continue
+		}
+
+		for _, m := range variableRegexp.FindAllSubmatch(data, -1) {
+			v := string(m[1])
+			if _, ok := seen[v]; !ok {
+				seen[v] = struct{}{}
+				result = append(result, v)
+			}
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// variableRegexp 匹配Terraform配置中的变量声明: variable "名字" {
+var variableRegexp = regexp.MustCompile(`(?m)^\s*variable\s+"([^"]+)"\s*\{`)
+
+// Flavors 返回Bindata中 "data/<flavor>" 目录的名字，按字母排序
 func (i *Infrastructure) Flavors() []string {
-	return nil
+	if i.Bindata == nil || i.Bindata.AssetDir == nil {
+		return nil
+	}
+
+	names, err := i.Bindata.AssetDir("data")
+	if err != nil {
+		return nil
+	}
+
+	// AssetDir fails for files, so this only keeps the directories
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, err := i.Bindata.AssetDir("data/" + name); err == nil {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+
+	return result
 }
 
 // Synopsis text for actions
 const (
 	infraApplySyn   = "Create or update infrastructure resources for this application"
+	infraPlanSyn    = "Show and save the changes Otto would make to the infrastructure"
+	infraCheckSyn   = "Check whether the infrastructure was changed outside of Otto"
 	infraDestroySyn = "Destroy infrastructure resources for this application"
 	infraInfoSyn    = "Display information about this application's infrastructure"
+	infraFlavorsSyn = "List the flavors this infrastructure type supports"
+
+	infraForceUnlockSyn = "Remove a stale lock on the infrastructure state"
+	infraStateSyn       = "List, show and restore previous versions of the infrastructure state"
 )
 
 // Help text for actions
 const infraApplyHelp = `
-Usage: otto infra
+Usage: otto infra [-plan=PATH]
 
   Creates infrastructure for your application.
 
   This command will create all the resource required to serve as an
   infrastructure for your application.
+
+  If -plan is given, exactly the changes in a plan saved by
+  'otto infra plan' are made. The plan is rejected if the infrastructure
+  state changed after it was saved.
+
+  Terraform variables of the flavor, such as the region, can be set with
+  a "variables" block in the Appfile infrastructure or with TF_VAR_<name>
+  environment variables. Appfile values take precedence:
+
+      infrastructure "aws" {
+          variables {
+              aws_region = "eu-west-1"
+          }
+      }
+`
+
+const infraPlanHelp = `
+Usage: otto infra plan [-out=PATH]
+
+  Shows the changes 'otto infra' would make to the infrastructure.
+
+  This command runs 'terraform plan' against the stored infrastructure
+  state and shows a summary of the resources that would be added,
+  changed and destroyed. Nothing is changed.
+
+  The plan is saved to PATH, otto.tfplan in the current directory by
+  default. Apply exactly this plan with 'otto infra -plan=PATH'. The plan
+  contains the infrastructure credentials, so keep it private.
+`
+
+const infraCheckHelp = `
+Usage: otto infra check
+
+  Checks whether the infrastructure was changed outside of Otto.
+
+  This command refreshes the stored infrastructure state against the real
+  resources and reports every resource that was changed or deleted, for
+  example by hand in the cloud provider's console. The stored state is
+  not changed.
+
+  If anything drifted, the infrastructure is marked as drifted in
+  'otto status' and the command exits with status 1. The mark is removed
+  by the next 'otto infra' or a check that finds no drift.
 `
 
 const infraDestroyHelp = `
@@ -280,11 +958,59 @@ Usage: otto infra destroy [-force]
 `
 
 const infraInfoHelp = `
-Usage: otto infra info [NAME]
+Usage: otto infra info [-json] [NAME]
 
   Displays information about this application's infrastructure.
 
   This command will show any variables the infrastructure has specified as
   outputs. If no NAME is specified, all outputs will be listed. If NAME is
   specified, just the contents of that output will be printed.
+
+  With -json, the outputs are printed as JSON with their type, so list
+  and map outputs keep their structure:
+
+    {"subnet_ids": {"type": "list", "value": ["subnet-1", "subnet-2"]}}
+`
+
+const infraFlavorsHelp = `
+Usage: otto infra flavors
+
+  Lists the flavors this infrastructure type supports.
+
+  The flavor is set with "flavor" in the infrastructure block of the
+  Appfile. The flavor used by this Appfile is marked.
+`
+
+const infraForceUnlockHelp = `
+Usage: otto infra force-unlock [-force]
+
+  Removes the lock on the infrastructure state.
+
+  Otto locks the Terraform state while it changes the infrastructure so
+  that two people can't change it at the same time. If Otto is killed
+  while it holds the lock, the lock stays until it expires. This command
+  shows who holds the lock and removes it.
+
+  Only remove the lock if the Otto holding it is no longer running.
+  Otto will ask for confirmation first. You can provide the -force flag
+  to skip this check.
+`
+
+const infraStateHelp = `
+Usage: otto infra state <list|show|restore> [VERSION] [-force]
+
+  Lists, shows and restores previous versions of the infrastructure state.
+
+  Every time Otto writes the Terraform state of the infrastructure it
+  keeps a numbered version of it. The most recent versions are kept.
+
+Actions:
+
+  list               List the stored versions. The last one is the
+                     current state
+  show VERSION       Print the state of a version
+  restore VERSION    Make a version the current state. The current state
+                     stays in the history, so a restore can be undone.
+                     Otto will ask for confirmation first. You can
+                     provide the -force flag to skip this check
 `
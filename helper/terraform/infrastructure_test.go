@@ -0,0 +1,448 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/helper/bindata"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/redact"
+)
+
+func TestInfrastructureFlavors(t *testing.T) {
+	tree := map[string][]string{
+		"data":                    {"vpc-public-private", "simple", "README.md"},
+		"data/simple":             {"main.tf"},
+		"data/vpc-public-private": {"main.tf", "variables.tf"},
+	}
+
+	i := &Infrastructure{
+		Bindata: &bindata.Data{
+			AssetDir: func(name string) ([]string, error) {
+				if children, ok := tree[name]; ok {
+					return children, nil
+				}
+
+				return nil, fmt.Errorf("not found: %s", name)
+			},
+		},
+	}
+
+	actual := i.Flavors()
+	expected := []string{"simple", "vpc-public-private"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestInfrastructureFlavors_noBindata(t *testing.T) {
+	i := new(Infrastructure)
+	if f := i.Flavors(); f != nil {
+		t.Fatalf("bad: %#v", f)
+	}
+}
+
+func TestInfrastructureFlavorVariables(t *testing.T) {
+	assets := map[string]string{
+		"data/simple/main.tf": `
+variable "aws_region" {
+    description = "Region"
+}
+
+resource "aws_vpc" "main" {
+  cidr_block = "${var.vpc_cidr}"
+}`,
+		"data/simple/variables.tf": `
+variable "vpc_cidr" { default = "10.0.0.0/16" }
+  variable "aws_region" {}
+# variable "commented" {}`,
+		"data/simple/README.md": `variable "ignored" {}`,
+	}
+	tree := map[string][]string{
+		"data":        {"simple"},
+		"data/simple": {"README.md", "main.tf", "variables.tf"},
+	}
+
+	i := &Infrastructure{
+		Bindata: &bindata.Data{
+			Asset: func(name string) ([]byte, error) {
+				if data, ok := assets[name]; ok {
+					return []byte(data), nil
+				}
+
+				return nil, fmt.Errorf("not found: %s", name)
+			},
+			AssetDir: func(name string) ([]string, error) {
+				if children, ok := tree[name]; ok {
+					return children, nil
+				}
+
+				return nil, fmt.Errorf("not found: %s", name)
+			},
+		},
+	}
+
+	actual := i.FlavorVariables("simple")
+	expected := []string{"aws_region", "vpc_cidr"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if v := i.FlavorVariables("unknown"); v != nil {
+		t.Fatalf("bad: %#v", v)
+	}
+	if v := new(Infrastructure).FlavorVariables("simple"); v != nil {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestInfrastructureExecute_variables(t *testing.T) {
+	for _, name := range []string{"aws_region", "instance_type"} {
+		k := EnvVarPrefix + name
+		if v, ok := os.LookupEnv(k); ok {
+			defer os.Setenv(k, v)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+
+	h := newTestHarness(t)
+	defer h.Close()
+	h.Infra.Variables["instance_type"] = "t2.micro"
+	h.Infra.Variables["vpc_cidr"] = "10.0.0.0/16"
+
+	// The environment overrides the builtin defaults
+	os.Setenv(EnvVarPrefix+"aws_region", "eu-west-1")
+	os.Setenv(EnvVarPrefix+"instance_type", "t2.small")
+
+	// The Appfile overrides both
+	h.Config.Variables = map[string]string{"instance_type": "m4.large"}
+
+	if err := h.Execute(""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	calls := h.Calls(t)
+	if len(calls) == 0 {
+		t.Fatal("terraform should be called")
+	}
+	expected := map[string]string{
+		"aws_access_key": "access",
+		"aws_secret_key": "secret",
+		"aws_region":     "eu-west-1",
+		"instance_type":  "m4.large",
+		"vpc_cidr":       "10.0.0.0/16",
+	}
+	if !reflect.DeepEqual(calls[0].Vars, expected) {
+		t.Fatalf("bad: %#v", calls[0].Vars)
+	}
+}
+
+func TestInfrastructureExecute_apply(t *testing.T) {
+	h := newTestHarness(t)
+	defer h.Close()
+	h.Configure(t, &fakeConfig{
+		Outputs: map[string]interface{}{
+			"vpc_id":  "vpc-1",
+			"subnets": []string{"subnet-1", "subnet-2"},
+		},
+	})
+
+	if err := h.Execute(""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Terraform got the state, the creds and the variables
+	calls := h.Calls(t)
+	if len(calls) != 1 || calls[0].Args[0] != "apply" || calls[0].Serial != 0 {
+		t.Fatalf("bad: %#v", calls)
+	}
+	expected := map[string]string{
+		"aws_access_key": "access",
+		"aws_secret_key": "secret",
+		"aws_region":     "us-east-1",
+	}
+	if !reflect.DeepEqual(calls[0].Vars, expected) {
+		t.Fatalf("bad: %#v", calls[0].Vars)
+	}
+	if _, err := os.Stat(calls[0].VarFile); !os.IsNotExist(err) {
+		t.Fatalf("var file should be removed: %v", err)
+	}
+
+	// The infrastructure is ready with typed outputs
+	infra := h.InfraRecord(t)
+	if !infra.IsReady() {
+		t.Fatalf("bad: %#v", infra)
+	}
+	if v := infra.Output("vpc_id").String(); v != "vpc-1" {
+		t.Fatalf("bad: %q", v)
+	}
+	if v := infra.Output("subnets").List(); !reflect.DeepEqual(v, []string{"subnet-1", "subnet-2"}) {
+		t.Fatalf("bad: %#v", v)
+	}
+
+	// The state is stored and its version records the Terraform version
+	if state := h.State(t); state == nil || state.Serial != 1 {
+		t.Fatalf("bad: %#v", state)
+	}
+	versions, err := (&StateHistory{Directory: h.Directory, StateId: infra.ID}).List()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 1 || versions[0].TerraformVersion != testHarnessVersion {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// Applying again starts from the stored state
+	if err := h.Execute(""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if calls := h.Calls(t); len(calls) != 2 || calls[1].Serial != 1 {
+		t.Fatalf("bad: %#v", calls)
+	}
+	if state := h.State(t); state.Serial != 2 {
+		t.Fatalf("bad: %#v", state)
+	}
+}
+
+func TestInfrastructureExecute_applyFailed(t *testing.T) {
+	h := newTestHarness(t)
+	defer h.Close()
+	h.Configure(t, &fakeConfig{Fail: true})
+
+	if err := h.Execute(""); err == nil {
+		t.Fatal("should error")
+	}
+
+	// Terraform's own error is mirrored to the UI
+	if !testTerraformHasOutput(&Terraform{Ui: h.Ui}, "Error applying plan: fake failure") {
+		t.Fatalf("bad: %#v", h.Ui.Messages)
+	}
+
+	// The partial state is kept so the next run can continue from it
+	if infra := h.InfraRecord(t); !infra.IsPartial() {
+		t.Fatalf("bad: %#v", infra)
+	}
+	state := h.State(t)
+	if state == nil || state.Modules[0].Resources["aws_instance.web"] == nil {
+		t.Fatalf("bad: %#v", state)
+	}
+}
+
+func TestInfrastructureExecute_destroy(t *testing.T) {
+	h := newTestHarness(t)
+	defer h.Close()
+	h.Configure(t, &fakeConfig{
+		Outputs: map[string]interface{}{"vpc_id": "vpc-1"},
+	})
+
+	if err := h.Execute(""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := h.Execute("destroy"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	calls := h.Calls(t)
+	if len(calls) != 2 || calls[1].Args[0] != "destroy" || calls[1].Serial != 1 {
+		t.Fatalf("bad: %#v", calls)
+	}
+	if !testHasArg(calls[1].Args, "-force") {
+		t.Fatalf("destroy should not ask: %#v", calls[1].Args)
+	}
+
+	// The infrastructure is gone along with its outputs
+	infra := h.InfraRecord(t)
+	if infra.State != directory.InfraStateInvalid || len(infra.AllOutputs()) != 0 {
+		t.Fatalf("bad: %#v", infra)
+	}
+	if state := h.State(t); len(state.Modules[0].Resources) != 0 {
+		t.Fatalf("bad: %#v", state)
+	}
+}
+
+func TestInfrastructureExecute_info(t *testing.T) {
+	h := newTestHarness(t)
+	defer h.Close()
+
+	// Nothing to show before the infrastructure is created
+	if err := h.Execute("info"); err == nil {
+		t.Fatal("should error")
+	}
+
+	h.Configure(t, &fakeConfig{
+		Outputs: map[string]interface{}{"vpc_id": "vpc-1", "zone": "a"},
+	})
+	if err := h.Execute(""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	h.Ui.Messages = nil
+	if err := h.Execute("info", "vpc_id"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	output := strings.Join(h.Ui.Messages, "")
+	if !strings.Contains(output, "vpc_id = vpc-1") || strings.Contains(output, "zone") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// Output reads the state and doesn't change it
+	calls := h.Calls(t)
+	last := calls[len(calls)-1]
+	if last.Args[0] != "output" || testHasArg(last.Args, "-state-out") || last.VarFile != "" {
+		t.Fatalf("bad: %#v", last)
+	}
+	if state := h.State(t); state.Serial != 1 {
+		t.Fatalf("bad: %#v", state)
+	}
+}
+
+func TestInfrastructureExecute_stateSaveFailed(t *testing.T) {
+	h := newTestHarness(t)
+	defer h.Close()
+
+	// Create the infrastructure record first so the state key is known
+	infra := &directory.Infra{Lookup: directory.Lookup{Infra: "aws"}}
+	if err := h.Directory.PutInfra(infra); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	h.Directory = &testFailingBackend{Backend: h.Directory, FailKey: infra.ID}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(h.root); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(wd)
+
+	err = h.Execute("")
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	// The state is copied to the working directory and the
+	// infrastructure is marked partial
+	path := filepath.Join(h.root, fmt.Sprintf("otto-%s.tfstate", infra.ID))
+	if !strings.Contains(err.Error(), filepath.Base(path)) {
+		t.Fatalf("bad: %s", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infra := h.InfraRecord(t); !infra.IsPartial() {
+		t.Fatalf("bad: %#v", infra)
+	}
+}
+
+func TestInfrastructureExecute_redact(t *testing.T) {
+	h := newTestHarness(t)
+	defer h.Close()
+	h.Configure(t, &fakeConfig{
+		Outputs: map[string]interface{}{"echo": "secret"},
+	})
+
+	if err := h.Execute(""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	h.Ui.Messages = nil
+	if err := h.Execute("info"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	output := strings.Join(h.Ui.Messages, "")
+	if strings.Contains(output, "secret") || !strings.Contains(output, redact.Placeholder) {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestInfrastructureExecute_redactPlan(t *testing.T) {
+	h := newTestHarness(t)
+	defer h.Close()
+	h.Infra.Variables["aws_secret_key"] = "plan-s3cr3t"
+
+	// A saved plan contains the variables. It's applied without a var
+	// file, but the values must still be redacted.
+	planPath := filepath.Join(h.Dir, "otto.tfplan")
+	if err := ioutil.WriteFile(planPath, []byte("key=plan-s3cr3t"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := WritePlanMeta(planPath, &PlanMeta{Infra: "aws"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := h.Execute("", "-plan="+planPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	calls := h.Calls(t)
+	if len(calls) == 0 || calls[0].VarFile != "" {
+		t.Fatalf("bad: %#v", calls)
+	}
+	output := strings.Join(h.Ui.Messages, "")
+	if strings.Contains(output, "plan-s3cr3t") || !strings.Contains(output, "plan: key="+redact.Placeholder) {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestInfrastructureCompile(t *testing.T) {
+	assets := map[string]string{
+		"data/simple/main.tf":      "resource {}",
+		"data/simple/variables.tf": "variable {}",
+	}
+	tree := map[string][]string{
+		"data":        {"simple"},
+		"data/simple": {"main.tf", "variables.tf"},
+	}
+
+	i := &Infrastructure{
+		Bindata: &bindata.Data{
+			Asset: func(name string) ([]byte, error) {
+				if data, ok := assets[name]; ok {
+					return []byte(data), nil
+				}
+
+				return nil, fmt.Errorf("not found: %s", name)
+			},
+			AssetDir: func(name string) ([]string, error) {
+				if children, ok := tree[name]; ok {
+					return children, nil
+				}
+
+				return nil, fmt.Errorf("not found: %s", name)
+			},
+		},
+	}
+
+	h := newTestHarness(t)
+	defer h.Close()
+	if _, err := i.Compile(h.Context("")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for name, expected := range assets {
+		data, err := ioutil.ReadFile(filepath.Join(h.Dir, filepath.Base(name)))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(data) != expected {
+			t.Fatalf("bad %s: %q", name, data)
+		}
+	}
+}
+
+func testHasArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,242 @@
+package terraform
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/otto/ui"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// DefaultReleasesURL 是下载Terraform的默认地址。镜像使用同样的
+// 目录结构：每个版本一个目录，里面是各个平台的zip包和
+// "terraform_<版本>_SHA256SUMS" 文件
+const DefaultReleasesURL = "https://releases.hashicorp.com/terraform"
+
+// Installer 安装固定版本的Terraform。每个版本安装在Dir下自己的
+// 目录中，所以不同的版本可以同时存在，切换版本不需要重新下载
+//
+// 安装包从Mirror获取，Mirror可以是本地目录或者http(s)的URL，为空
+// 时使用DefaultReleasesURL。安装包的SHA256和SHA256SUMS文件中的
+// 不一致时拒绝安装
+type Installer struct {
+	Version *version.Version
+	Dir     string
+	Mirror  string
+	Ui      ui.Ui
+}
+
+// InstallAsk 实现hashitools.Installer。版本是用户固定的，所以
+// 不需要再询问
+func (i *Installer) InstallAsk(installed, latest *version.Version) (bool, error) {
+	return true, nil
+}
+
+// InstallIfNeeded 在Version还没有安装时安装它
+func (i *Installer) InstallIfNeeded() error {
+	_, err := os.Stat(i.Path())
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	return i.Install(i.Version)
+}
+
+// Install 下载、验证并安装版本v
+func (i *Installer) Install(v *version.Version) error {
+	if i.Ui != nil {
+		i.Ui.Header(i18n.T("terraform.installing", v))
+		i.Ui.Message(i18n.T("terraform.installing_from", i.source()))
+	}
+
+	name := fmt.Sprintf("terraform_%s_%s_%s.zip", v, runtime.GOOS, runtime.GOARCH)
+	sums, err := i.sums(v)
+	if err != nil {
+		return i18n.Errorf("terraform.install_err", v, err)
+	}
+	expected, ok := sums[name]
+	if !ok {
+		return i18n.Errorf("terraform.install_no_sum", name)
+	}
+
+	if err := os.MkdirAll(i.Dir, 0755); err != nil {
+		return i18n.Errorf("terraform.install_err", v, err)
+	}
+
+	// 先下载到临时文件，验证之后才解压
+	f, err := ioutil.TempFile(i.Dir, ".download-")
+	if err != nil {
+		return i18n.Errorf("terraform.install_err", v, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	r, err := i.open(v, name)
+	if err != nil {
+		return i18n.Errorf("terraform.install_err", v, err)
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	r.Close()
+	if err != nil {
+		return i18n.Errorf("terraform.install_err", v, err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		return i18n.Errorf("terraform.install_checksum_err", name, expected, actual)
+	}
+
+	// 解压到临时目录再改名，这样中断的安装不会留下不完整的版本
+	tmpDir, err := ioutil.TempDir(i.Dir, ".install-")
+	if err != nil {
+		return i18n.Errorf("terraform.install_err", v, err)
+	}
+	if err := unzip(f, size, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return i18n.Errorf("terraform.install_err", v, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, binaryName())); err != nil {
+		os.RemoveAll(tmpDir)
+		return i18n.Errorf("terraform.install_no_binary", name)
+	}
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		return i18n.Errorf("terraform.install_err", v, err)
+	}
+
+	path := i.versionDir(v)
+	if err := os.Rename(tmpDir, path); err != nil {
+		os.RemoveAll(tmpDir)
+
+		// 另一个Otto可能同时安装了同一个版本
+		if _, serr := os.Stat(filepath.Join(path, binaryName())); serr == nil {
+			return nil
+		}
+
+		return i18n.Errorf("terraform.install_err", v, err)
+	}
+
+	return nil
+}
+
+// Path 返回Version的terraform的路径
+func (i *Installer) Path() string {
+	return filepath.Join(i.versionDir(i.Version), binaryName())
+}
+
+func (i *Installer) versionDir(v *version.Version) string {
+	return filepath.Join(i.Dir, v.String())
+}
+
+func (i *Installer) source() string {
+	if i.Mirror != "" {
+		return i.Mirror
+	}
+
+	return DefaultReleasesURL
+}
+
+// open 打开版本v的目录中的文件
+func (i *Installer) open(v *version.Version, name string) (io.ReadCloser, error) {
+	source := i.source()
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(filepath.Join(source, v.String(), name))
+	}
+
+	url := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(source, "/"), v, name)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// sums 读取版本v的SHA256SUMS，key是文件名，值是十六进制的SHA256
+func (i *Installer) sums(v *version.Version) (map[string]string, error) {
+	r, err := i.open(v, fmt.Sprintf("terraform_%s_SHA256SUMS", v))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		result[fields[1]] = strings.ToLower(fields[0])
+	}
+
+	return result, scanner.Err()
+}
+
+// unzip 把zip包中的文件解压到dir。Terraform 0.6的插件是和terraform
+// 放在一起的单独的程序，所以要解压所有的文件。包中的目录被忽略
+func unzip(r io.ReaderAt, size int64, dir string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+
+		if err := unzipFile(zf, filepath.Join(dir, filepath.Base(zf.Name))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unzipFile(zf *zip.File, path string) error {
+	src, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// binaryName 返回当前平台上terraform程序的文件名
+func binaryName() string {
+	if runtime.GOOS == "windows" {
+		return "terraform.exe"
+	}
+
+	return "terraform"
+}
@@ -0,0 +1,203 @@
+package terraform
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/kuuyee/otto-learn/context"
+)
+
+func TestInstaller(t *testing.T) {
+	mirror, dir := testMirror(t, "0.6.16", "0.6.3")
+	defer os.RemoveAll(mirror)
+	defer os.RemoveAll(dir)
+
+	// Both versions are installed side by side
+	for _, v := range []string{"0.6.16", "0.6.3"} {
+		i := &Installer{
+			Version: version.Must(version.NewVersion(v)),
+			Dir:     dir,
+			Mirror:  mirror,
+			Ui:      new(testUi),
+		}
+		if err := i.InstallIfNeeded(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		data, err := ioutil.ReadFile(i.Path())
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(data) != "terraform "+v {
+			t.Fatalf("bad: %q", data)
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(i.Path()), "terraform-provider-aws")); err != nil {
+			t.Fatalf("plugins should be installed: %s", err)
+		}
+	}
+
+	// Installed versions aren't downloaded again
+	if err := os.RemoveAll(mirror); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	i := &Installer{
+		Version: version.Must(version.NewVersion("0.6.16")),
+		Dir:     dir,
+		Mirror:  mirror,
+	}
+	if err := i.InstallIfNeeded(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestInstaller_checksum(t *testing.T) {
+	mirror, dir := testMirror(t, "0.6.16")
+	defer os.RemoveAll(mirror)
+	defer os.RemoveAll(dir)
+
+	// Tamper with the package after the sums were written
+	zipPath := filepath.Join(mirror, "0.6.16", testZipName("0.6.16"))
+	f, err := os.OpenFile(zipPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f.Write([]byte("evil"))
+	f.Close()
+
+	i := &Installer{
+		Version: version.Must(version.NewVersion("0.6.16")),
+		Dir:     dir,
+		Mirror:  mirror,
+	}
+	err = i.InstallIfNeeded()
+	if err == nil || !strings.Contains(err.Error(), testZipName("0.6.16")) {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := os.Stat(i.Path()); !os.IsNotExist(err) {
+		t.Fatalf("should not be installed: %v", err)
+	}
+}
+
+func TestInstaller_noSum(t *testing.T) {
+	mirror, dir := testMirror(t, "0.6.16")
+	defer os.RemoveAll(mirror)
+	defer os.RemoveAll(dir)
+
+	sums := filepath.Join(mirror, "0.6.16", "terraform_0.6.16_SHA256SUMS")
+	if err := ioutil.WriteFile(sums, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	i := &Installer{
+		Version: version.Must(version.NewVersion("0.6.16")),
+		Dir:     dir,
+		Mirror:  mirror,
+	}
+	if err := i.InstallIfNeeded(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestProject_pinned(t *testing.T) {
+	mirror, dir := testMirror(t, "0.6.16")
+	defer os.RemoveAll(mirror)
+	defer os.RemoveAll(dir)
+
+	ctx := &context.Shared{
+		InstallDir:       dir,
+		TerraformVersion: "0.6.16",
+		TerraformMirror:  mirror,
+		Ui:               new(testUi),
+	}
+	p, err := Project(ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v := ProjectVersion(p); v != "0.6.16" {
+		t.Fatalf("bad: %q", v)
+	}
+	if _, err := os.Stat(p.Installer.Path()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ctx.TerraformVersion = "0.5.0"
+	if _, err := Project(ctx); err == nil {
+		t.Fatal("versions before the minimum should error")
+	}
+	ctx.TerraformVersion = "latest"
+	if _, err := Project(ctx); err == nil {
+		t.Fatal("invalid versions should error")
+	}
+}
+
+// testMirror creates a mirror with fake terraform packages of the given
+// versions for this platform, and a directory to install them into.
+func testMirror(t *testing.T, versions ...string) (string, string) {
+	mirror, err := ioutil.TempDir("", "otto-tf-mirror")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dir, err := ioutil.TempDir("", "otto-tf-install")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, v := range versions {
+		vDir := filepath.Join(mirror, v)
+		if err := os.MkdirAll(vDir, 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		name := testZipName(v)
+		f, err := os.Create(filepath.Join(vDir, name))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		h := sha256.New()
+		zw := zip.NewWriter(f)
+		for file, content := range map[string]string{
+			binaryName():             "terraform " + v,
+			"terraform-provider-aws": "aws " + v,
+		} {
+			w, err := zw.Create(file)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			w.Write([]byte(content))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		f.Close()
+
+		data, err := ioutil.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		h.Write(data)
+
+		sums := fmt.Sprintf("%s  %s\n%s  terraform_%s_plan9_mips.zip\n",
+			hex.EncodeToString(h.Sum(nil)), name, strings.Repeat("0", 64), v)
+		err = ioutil.WriteFile(
+			filepath.Join(vDir, fmt.Sprintf("terraform_%s_SHA256SUMS", v)),
+			[]byte(sums), 0644)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	return mirror, dir
+}
+
+func testZipName(v string) string {
+	return fmt.Sprintf("terraform_%s_%s_%s.zip", v, runtime.GOOS, runtime.GOARCH)
+}
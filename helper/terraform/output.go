@@ -1,33 +1,125 @@
 package terraform
 
 import (
-	"os"
+	"encoding/json"
+	"io/ioutil"
 
-	"github.com/hashicorp/terraform/terraform"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
 )
 
-// Outputs reads the outputs from the Terraform state at the given path.
-//
-// This is currently done by using the Terraform API to read from the
-// state file directory. In the future, this may work by shelling out to
-// Terraform which might be more stable.
+// Outputs reads the outputs from the Terraform state at the given path
+// as strings. Lists are joined with commas. Use TypedOutputs to keep
+// list and map outputs intact.
 func Outputs(path string) (map[string]string, error) {
-	// Read the state structure itself
-	state, err := readState(path)
+	outputs, err := TypedOutputs(path)
 	if err != nil {
 		return nil, err
 	}
 
-	// Return the outputs
-	return state.RootModule().Outputs, nil
+	return outputs.Strings(), nil
 }
 
-func readState(path string) (*terraform.State, error) {
-	f, err := os.Open(path)
+// TypedOutputs reads the outputs of the root module from the Terraform
+// state at the given path.
+//
+// Terraform before 0.7 only has string outputs and stores them as plain
+// strings. Later versions store every output as an object with a type
+// and a value. Both forms are read.
+func TypedOutputs(path string) (directory.Outputs, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	return terraform.ReadState(f)
+	var state struct {
+		Modules []struct {
+			Path    []string                   `json:"path"`
+			Outputs map[string]json.RawMessage `json:"outputs"`
+		} `json:"modules"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	result := make(directory.Outputs)
+	for _, m := range state.Modules {
+		if len(m.Path) != 1 || m.Path[0] != "root" {
+			continue
+		}
+
+		for k, raw := range m.Outputs {
+			output, err := parseOutput(raw)
+			if err != nil {
+				return nil, i18n.Errorf("terraform.output_read_err", k, err)
+			}
+
+			result[k] = output
+		}
+	}
+
+	return result, nil
+}
+
+func parseOutput(raw json.RawMessage) (*directory.Output, error) {
+	// Terraform 0.6 and earlier
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return directory.StringOutput(s), nil
+	}
+
+	var typed struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return nil, err
+	}
+
+	switch typed.Type {
+	case "string":
+		var v string
+		if err := json.Unmarshal(typed.Value, &v); err != nil {
+			return nil, err
+		}
+
+		return directory.StringOutput(v), nil
+	case "list":
+		var v []json.RawMessage
+		if err := json.Unmarshal(typed.Value, &v); err != nil {
+			return nil, err
+		}
+
+		list := make([]string, len(v))
+		for i, elem := range v {
+			list[i] = outputString(elem)
+		}
+
+		return directory.ListOutput(list), nil
+	case "map":
+		var v map[string]json.RawMessage
+		if err := json.Unmarshal(typed.Value, &v); err != nil {
+			return nil, err
+		}
+
+		m := make(map[string]string, len(v))
+		for k, elem := range v {
+			m[k] = outputString(elem)
+		}
+
+		return directory.MapOutput(m), nil
+	default:
+		return nil, i18n.Errorf("terraform.output_type_err", typed.Type)
+	}
+}
+
+// outputString returns an element of a list or map output as a string.
+// Elements that aren't strings, like nested lists, are kept as JSON.
+func outputString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	return string(raw)
 }
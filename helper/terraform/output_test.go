@@ -0,0 +1,107 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kuuyee/otto-learn/directory"
+)
+
+func TestTypedOutputs(t *testing.T) {
+	path := testStateFile(t, `{
+    "version": 3,
+    "modules": [
+        {
+            "path": ["root"],
+            "outputs": {
+                "vpc_id": {"type": "string", "value": "vpc-1"},
+                "subnet_ids": {"type": "list", "value": ["subnet-1", "subnet-2"]},
+                "zones": {"type": "map", "value": {"a": "us-east-1a", "count": 2}}
+            }
+        },
+        {
+            "path": ["root", "vpc"],
+            "outputs": {"ignored": {"type": "string", "value": "x"}}
+        }
+    ]
+}`)
+	defer os.Remove(path)
+
+	actual, err := TypedOutputs(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := directory.Outputs{
+		"vpc_id":     directory.StringOutput("vpc-1"),
+		"subnet_ids": directory.ListOutput([]string{"subnet-1", "subnet-2"}),
+		"zones":      directory.MapOutput(map[string]string{"a": "us-east-1a", "count": "2"}),
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The string form joins lists
+	flat, err := Outputs(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if flat["subnet_ids"] != "subnet-1,subnet-2" {
+		t.Fatalf("bad: %#v", flat)
+	}
+}
+
+func TestTypedOutputs_strings(t *testing.T) {
+	// Terraform 0.6 stores outputs as plain strings
+	path := testStateFile(t, `{
+    "version": 1,
+    "modules": [
+        {
+            "path": ["root"],
+            "outputs": {"subnet_ids": "subnet-1,subnet-2"}
+        }
+    ]
+}`)
+	defer os.Remove(path)
+
+	actual, err := TypedOutputs(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	o := actual["subnet_ids"]
+	if o == nil || o.Type != directory.OutputTypeString {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if !reflect.DeepEqual(o.List(), []string{"subnet-1", "subnet-2"}) {
+		t.Fatalf("bad: %#v", o.List())
+	}
+}
+
+func TestTypedOutputs_unknownType(t *testing.T) {
+	path := testStateFile(t, `{
+    "modules": [
+        {"path": ["root"], "outputs": {"foo": {"type": "set", "value": []}}}
+    ]
+}`)
+	defer os.Remove(path)
+
+	if _, err := TypedOutputs(path); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func testStateFile(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "otto-tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return f.Name()
+}
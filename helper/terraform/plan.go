@@ -0,0 +1,173 @@
+package terraform
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/otto/ui"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// DefaultPlanPath 是 `otto infra plan` 默认保存plan的文件，相对于
+// 当前目录
+const DefaultPlanPath = "otto.tfplan"
+
+// PlanSummary 是 `terraform plan` 要做的修改的汇总
+type PlanSummary struct {
+	// Add、Change 和 Destroy 是要创建、修改和删除的资源。Replace
+	// 是要先删除再创建的资源
+	Add     []string
+	Change  []string
+	Destroy []string
+	Replace []string
+}
+
+// Empty 返回plan是否没有任何修改
+func (s *PlanSummary) Empty() bool {
+	return len(s.Add)+len(s.Change)+len(s.Destroy)+len(s.Replace) == 0
+}
+
+// ParsePlan 从 `terraform plan -no-color` 的输出中解析要修改的资源。
+// 资源行的前缀是 "+"、"-"、"~" 或 "-/+"，后面是资源的地址，比如
+// "aws_instance.web"。属性行以属性名开始，说明符号的行后面不是资源
+// 地址，所以都不会被当成资源
+func ParsePlan(output string) *PlanSummary {
+	result := new(PlanSummary)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.Contains(fields[1], ".") {
+			continue
+		}
+
+		name := fields[1]
+		switch fields[0] {
+		case "+":
+			result.Add = append(result.Add, name)
+		case "~":
+			result.Change = append(result.Change, name)
+		case "-":
+			result.Destroy = append(result.Destroy, name)
+		case "-/+":
+			result.Replace = append(result.Replace, name)
+		}
+	}
+
+	return result
+}
+
+// PlanMeta 是和plan文件一起保存的信息，用来在apply的时候检查
+// plan是否还有效
+type PlanMeta struct {
+	// Infra 是plan的infrastructure的名字
+	Infra string
+
+	// StateId 和 StateVersion 是生成plan时的状态。如果之后状态
+	// 改变了，plan就过期了
+	StateId      string
+	StateVersion int
+
+	Created time.Time
+}
+
+// PlanMetaPath 返回plan文件的PlanMeta的保存路径
+func PlanMetaPath(planPath string) string {
+	return planPath + ".otto"
+}
+
+// ReadPlanMeta 读取plan文件的PlanMeta
+func ReadPlanMeta(planPath string) (*PlanMeta, error) {
+	data, err := ioutil.ReadFile(PlanMetaPath(planPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var result PlanMeta
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// WritePlanMeta 保存plan文件的PlanMeta
+func WritePlanMeta(planPath string, m *PlanMeta) error {
+	data, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(PlanMetaPath(planPath), data, 0600)
+}
+
+// checkPlan 检查plan文件是不是为current的infrastructure和状态创建的
+func checkPlan(planPath string, current *PlanMeta) error {
+	meta, err := ReadPlanMeta(planPath)
+	if err != nil {
+		return i18n.Errorf("terraform.plan_read_err", planPath, err)
+	}
+	if _, err := os.Stat(planPath); err != nil {
+		return i18n.Errorf("terraform.plan_read_err", planPath, err)
+	}
+
+	if meta.Infra != current.Infra {
+		return i18n.Errorf("terraform.plan_other_infra",
+			planPath, meta.Infra, current.Infra)
+	}
+	if meta.StateId != current.StateId || meta.StateVersion != current.StateVersion {
+		return i18n.Errorf("terraform.plan_stale", planPath)
+	}
+
+	return nil
+}
+
+// currentVersion 返回状态历史中最新的版本号，还没有保存过版本时返回0。
+// 不保存历史(Retain小于0)时无法知道状态是否改变了，所以返回错误
+func currentVersion(h *StateHistory) (int, error) {
+	if h.Retain < 0 {
+		return 0, i18n.Errorf("terraform.plan_no_history")
+	}
+
+	versions, err := h.List()
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+
+	return versions[len(versions)-1].Version, nil
+}
+
+// removePlan 删除plan文件和它的PlanMeta
+func removePlan(planPath string) {
+	os.Remove(planPath)
+	os.Remove(PlanMetaPath(planPath))
+}
+
+// captureUi 记录Raw的输出，同时仍然把输出传给包装的Ui
+type captureUi struct {
+	ui.Ui
+
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (u *captureUi) Raw(msg string) {
+	u.lock.Lock()
+	u.buf.WriteString(msg)
+	u.lock.Unlock()
+
+	if u.Ui != nil {
+		u.Ui.Raw(msg)
+	}
+}
+
+func (u *captureUi) String() string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.buf.String()
+}
@@ -0,0 +1,171 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testPlanOutput = `
+Refreshing Terraform state prior to plan...
+
+The Terraform execution plan has been generated and is shown below.
+Resources are shown in alphabetical order for quick scanning. Green resources
+will be created (or destroyed and then created if an existing resource
+exists), yellow resources are being changed in-place, and red resources
+will be destroyed.
+
+Your plan was also saved to the path below. Call the "apply" subcommand
+with this plan file and Terraform will exactly execute this execution
+plan.
+
+Path: otto.tfplan
+
++ aws_instance.web
+    ami:           "" => "ami-1"
+    instance_type: "" => "t2.micro"
+
+~ aws_security_group.web
+    description: "old" => "new"
+
+- aws_eip.old
+
+-/+ aws_subnet.public (new resource required)
+    cidr_block: "10.0.1.0/24" => "10.0.2.0/24" (forces new resource)
+
+~ module.vpc.aws_vpc.main
+    tags.Name: "a" => "b"
+
+
+Plan: 2 to add, 2 to change, 2 to destroy.
+`
+
+func TestParsePlan(t *testing.T) {
+	actual := ParsePlan(testPlanOutput)
+	expected := &PlanSummary{
+		Add:     []string{"aws_instance.web"},
+		Change:  []string{"aws_security_group.web", "module.vpc.aws_vpc.main"},
+		Destroy: []string{"aws_eip.old"},
+		Replace: []string{"aws_subnet.public"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestParsePlan_legend(t *testing.T) {
+	// Newer Terraform versions explain the symbols before the plan
+	actual := ParsePlan(`
+Resource actions are indicated with the following symbols:
+  + create
+  ~ update in-place
+  - destroy
+-/+ destroy and then create replacement
+
+No changes. Infrastructure is up-to-date.
+`)
+	if !actual.Empty() {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestTerraformExecute_plan(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	output := &captureUi{Ui: tf.Ui}
+	tf.Ui = output
+
+	planPath := filepath.Join(dir, "otto.tfplan")
+	if err := tf.Execute("plan", "-no-color", "-out", planPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(planPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	summary := ParsePlan(output.String())
+	if !reflect.DeepEqual(summary.Add, []string{"aws_instance.web"}) {
+		t.Fatalf("bad: %#v", summary)
+	}
+
+	// Plan only reads the state
+	testTerraformState(t, tf, "")
+	testTerraformUnlocked(t, tf)
+}
+
+func TestCheckPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otto-tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	planPath := filepath.Join(dir, "otto.tfplan")
+	meta := &PlanMeta{Infra: "aws", StateId: "state", StateVersion: 2}
+
+	// No plan
+	if err := checkPlan(planPath, meta); err == nil {
+		t.Fatal("should error")
+	}
+
+	if err := ioutil.WriteFile(planPath, []byte("plan"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := WritePlanMeta(planPath, meta); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := checkPlan(planPath, meta); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []*PlanMeta{
+		{Infra: "other", StateId: "state", StateVersion: 2},
+		{Infra: "aws", StateId: "other", StateVersion: 2},
+		{Infra: "aws", StateId: "state", StateVersion: 3},
+		{Infra: "aws"},
+	}
+	for _, current := range cases {
+		if err := checkPlan(planPath, current); err == nil {
+			t.Fatalf("should error: %#v", current)
+		}
+	}
+
+	// Without the plan itself the metadata isn't enough
+	removePlan(planPath)
+	if err := checkPlan(planPath, meta); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCurrentVersion(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	v, err := currentVersion(tf.History())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != 0 {
+		t.Fatalf("bad: %d", v)
+	}
+
+	if err := tf.Execute("apply"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	v, err = currentVersion(tf.History())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != 1 {
+		t.Fatalf("bad: %d", v)
+	}
+
+	// Without history a changed state can't be told apart
+	tf.StateHistory = -1
+	if _, err := currentVersion(tf.History()); err == nil {
+		t.Fatal("should error")
+	}
+}
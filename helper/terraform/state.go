@@ -0,0 +1,229 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// DefaultStateHistory 是默认保留的状态版本数
+const DefaultStateHistory = 10
+
+// StateVersion 是Terraform状态的一个历史版本
+type StateVersion struct {
+	// Version 是版本号，每次写入状态加一
+	Version int
+
+	// Time 是写入的时间，Operation 是写入状态的操作，比如 "apply"
+	Time      time.Time
+	Operation string
+
+	// Size 是状态的字节数
+	Size int64
+
+	// TerraformVersion 是写入状态的Terraform的版本，不知道时为空
+	TerraformVersion string
+}
+
+// StateHistory 保存Terraform状态的历史版本。Terraform每次写入状态
+// 的时候同时保存一个带编号的版本，只保留最近的Retain个
+//
+// 版本保存在目录的blob中，key是 "state-history/<StateId>/<版本>"，
+// 版本列表保存在 "state-history/<StateId>/index"。修改历史的时候
+// 调用者应该持有状态的锁
+type StateHistory struct {
+	Directory directory.Backend
+	StateId   string
+
+	// Retain 是保留的版本数，为0时使用DefaultStateHistory，小于0
+	// 时不保存历史
+	Retain int
+
+	// TerraformVersion 是写入状态的Terraform的版本，记录在保存的
+	// 版本中
+	TerraformVersion string
+}
+
+// List 返回保存的版本，按照版本号从小到大排列。最后一个是当前
+// 的状态
+func (h *StateHistory) List() ([]*StateVersion, error) {
+	data, err := h.Directory.GetBlob(h.indexKey())
+	if err != nil || data == nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	var result []*StateVersion
+	if err := json.NewDecoder(data.Data).Decode(&result); err != nil {
+		return nil, i18n.Errorf("terraform.state_history_parse_err", err)
+	}
+
+	return result, nil
+}
+
+// Get 返回版本v的状态，版本不存在时返回nil
+func (h *StateHistory) Get(v int) (*StateVersion, *directory.BlobData, error) {
+	versions, err := h.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, sv := range versions {
+		if sv.Version != v {
+			continue
+		}
+
+		data, err := h.Directory.GetBlob(h.versionKey(v))
+		if err != nil || data == nil {
+			return nil, nil, err
+		}
+
+		return sv, data, nil
+	}
+
+	return nil, nil, nil
+}
+
+// Save 把状态保存为一个新的版本，并删除超过保留数量的旧版本。
+// 当前的状态还是要由调用者写入目录
+func (h *StateHistory) Save(state []byte, operation string) (*StateVersion, error) {
+	if h.Retain < 0 {
+		return nil, nil
+	}
+
+	versions, err := h.List()
+	if err != nil {
+		return nil, err
+	}
+
+	sv := &StateVersion{
+		Version:   1,
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		Size:      int64(len(state)),
+
+		TerraformVersion: h.TerraformVersion,
+	}
+	if len(versions) > 0 {
+		sv.Version = versions[len(versions)-1].Version + 1
+	}
+
+	err = h.Directory.PutBlob(h.versionKey(sv.Version), &directory.BlobData{
+		Data: bytes.NewReader(state),
+	})
+	if err != nil {
+		return nil, err
+	}
+	versions = append(versions, sv)
+
+	// 删除超过保留数量的旧版本。先更新列表再删除数据，这样列表中
+	// 的版本总是存在的
+	retain := h.Retain
+	if retain == 0 {
+		retain = DefaultStateHistory
+	}
+	var pruned []*StateVersion
+	if len(versions) > retain {
+		pruned = versions[:len(versions)-retain]
+		versions = versions[len(versions)-retain:]
+	}
+
+	index, err := json.Marshal(versions)
+	if err != nil {
+		return nil, err
+	}
+	err = h.Directory.PutBlob(h.indexKey(), &directory.BlobData{
+		Data: bytes.NewReader(index),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, old := range pruned {
+		if err := h.Directory.DeleteBlob(h.versionKey(old.Version)); err != nil {
+			return nil, err
+		}
+	}
+
+	return sv, nil
+}
+
+// Restore 把版本v恢复成当前的状态。恢复也是一次写入，所以会保存
+// 一个新的版本，恢复之后还可以再回到恢复之前的状态
+func (h *StateHistory) Restore(v int) (*StateVersion, error) {
+	sv, data, err := h.Get(v)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, i18n.Errorf("terraform.state_version_none", v)
+	}
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, data.Data)
+	data.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.Directory.PutBlob(h.StateId, &directory.BlobData{
+		Data: bytes.NewReader(buf.Bytes()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 恢复的状态还是原来的Terraform写入的
+	restored := *h
+	restored.TerraformVersion = sv.TerraformVersion
+	return restored.Save(buf.Bytes(), fmt.Sprintf("restore %d", v))
+}
+
+// lastTerraformVersion 返回写入当前状态的Terraform的版本，没有历史
+// 或者没有记录时返回空字符串
+func lastTerraformVersion(h *StateHistory) (string, error) {
+	versions, err := h.List()
+	if err != nil || len(versions) == 0 {
+		return "", err
+	}
+
+	return versions[len(versions)-1].TerraformVersion, nil
+}
+
+func (h *StateHistory) indexKey() string {
+	return fmt.Sprintf("state-history/%s/index", h.StateId)
+}
+
+func (h *StateHistory) versionKey(v int) string {
+	return fmt.Sprintf("state-history/%s/%d", h.StateId, v)
+}
+
+// copyStateLocal 把状态复制到当前目录，在无法把状态保存到目录的时候
+// 使用，这样状态不会丢失。返回复制到的路径
+func copyStateLocal(stateId string, state []byte) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(
+		filepath.Join(wd, fmt.Sprintf("otto-%s.tfstate", stateId)),
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(state)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return f.Name(), err
+}
@@ -0,0 +1,157 @@
+package terraform
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kuuyee/otto-learn/directory"
+)
+
+func TestStateHistory(t *testing.T) {
+	h, dir := testStateHistory(t)
+	defer os.RemoveAll(dir)
+	h.Retain = 2
+
+	for _, state := range []string{"1", "2", "3"} {
+		if _, err := h.Save([]byte(state), "apply"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Only the last two are kept
+	versions, err := h.List()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 3 {
+		t.Fatalf("bad: %#v", versions)
+	}
+	if versions[1].Operation != "apply" || versions[1].Size != 1 {
+		t.Fatalf("bad: %#v", versions[1])
+	}
+
+	// The pruned version is deleted
+	data, err := h.Directory.GetBlob(h.versionKey(1))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if data != nil {
+		data.Close()
+		t.Fatal("version 1 should be deleted")
+	}
+
+	if sv, data, err := h.Get(1); err != nil || sv != nil || data != nil {
+		t.Fatalf("bad: %#v %#v %v", sv, data, err)
+	}
+	testStateHistoryGet(t, h, 3, "3")
+}
+
+func TestStateHistory_restore(t *testing.T) {
+	h, dir := testStateHistory(t)
+	defer os.RemoveAll(dir)
+
+	for _, state := range []string{"1", "2"} {
+		if _, err := h.Save([]byte(state), "apply"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	sv, err := h.Restore(1)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if sv.Version != 3 || sv.Operation != "restore 1" {
+		t.Fatalf("bad: %#v", sv)
+	}
+	testStateHistoryGet(t, h, 3, "1")
+
+	// The current state is the restored one
+	data, err := h.Directory.GetBlob(h.StateId)
+	if err != nil || data == nil {
+		t.Fatalf("bad: %#v %v", data, err)
+	}
+	defer data.Close()
+	actual, _ := ioutil.ReadAll(data.Data)
+	if string(actual) != "1" {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	if _, err := h.Restore(42); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestStateHistory_terraformVersion(t *testing.T) {
+	h, dir := testStateHistory(t)
+	defer os.RemoveAll(dir)
+
+	h.TerraformVersion = "0.6.3"
+	if _, err := h.Save([]byte("1"), "apply"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	h.TerraformVersion = "0.6.16"
+	if _, err := h.Save([]byte("2"), "apply"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v, err := lastTerraformVersion(h); err != nil || v != "0.6.16" {
+		t.Fatalf("bad: %q %v", v, err)
+	}
+
+	// A restored state keeps the version that wrote it
+	sv, err := h.Restore(1)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if sv.TerraformVersion != "0.6.3" {
+		t.Fatalf("bad: %#v", sv)
+	}
+}
+
+func TestStateHistory_disabled(t *testing.T) {
+	h, dir := testStateHistory(t)
+	defer os.RemoveAll(dir)
+	h.Retain = -1
+
+	if _, err := h.Save([]byte("1"), "apply"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	versions, err := h.List()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 0 {
+		t.Fatalf("bad: %#v", versions)
+	}
+}
+
+func testStateHistory(t *testing.T) (*StateHistory, string) {
+	dir, err := ioutil.TempDir("", "otto-tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &StateHistory{
+		Directory: &directory.FileBackend{Dir: dir},
+		StateId:   "state",
+	}, dir
+}
+
+func testStateHistoryGet(t *testing.T, h *StateHistory, v int, expected string) {
+	sv, data, err := h.Get(v)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if sv == nil || data == nil {
+		t.Fatalf("version %d missing", v)
+	}
+	defer data.Close()
+
+	var buf bytes.Buffer
+	buf.ReadFrom(data.Data)
+	if buf.String() != expected {
+		t.Fatalf("bad version %d: %q", v, buf.String())
+	}
+}
@@ -1,29 +1,40 @@
 package terraform
 
 import (
+	"bytes"
+	stdcontext "context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
 
 	"github.com/hashicorp/go-version"
-	"github.com/hashicorp/otto/directory"
 	execHelper "github.com/hashicorp/otto/helper/exec"
 	"github.com/hashicorp/otto/helper/hashitools"
 	"github.com/hashicorp/otto/ui"
 	"github.com/kuuyee/otto-learn/context"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/kuuyee/otto-learn/helper/redact"
 )
 
 var (
 	tfMinVersion = version.Must(version.NewVersion("0.6.3"))
 )
 
-// Project returns the hashitools Project for this.
+// Project returns the hashitools Project for this. If a Terraform version
+// is pinned with ctx.TerraformVersion, exactly that version is installed
+// next to any other installed versions. Otherwise any installed version
+// of at least tfMinVersion is used.
 func Project(ctx *context.Shared) (*hashitools.Project, error) {
+	if ctx.TerraformVersion != "" {
+		return pinnedProject(ctx)
+	}
+
 	p := &hashitools.Project{
 		Name:       "terraform",
 		MinVersion: tfMinVersion,
@@ -36,6 +47,46 @@ func Project(ctx *context.Shared) (*hashitools.Project, error) {
 	return p, p.InstallIfNeeded()
 }
 
+// pinnedProject returns the Project for the pinned ctx.TerraformVersion,
+// installing it from ctx.TerraformMirror if needed.
+func pinnedProject(ctx *context.Shared) (*hashitools.Project, error) {
+	v, err := version.NewVersion(ctx.TerraformVersion)
+	if err != nil {
+		return nil, i18n.Errorf("terraform.version_invalid", ctx.TerraformVersion, err)
+	}
+	if v.LessThan(tfMinVersion) {
+		return nil, i18n.Errorf("terraform.version_too_old", v, tfMinVersion)
+	}
+
+	installer := &Installer{
+		Version: v,
+		Dir:     filepath.Join(ctx.InstallDir, "terraform-versions"),
+		Mirror:  ctx.TerraformMirror,
+		Ui:      ctx.Ui,
+	}
+	p := &hashitools.Project{
+		Name:       "terraform",
+		MinVersion: v,
+		Installer:  installer,
+	}
+	return p, installer.InstallIfNeeded()
+}
+
+// ProjectVersion returns the version of Terraform the Project runs, or
+// an empty string if it can't be determined.
+func ProjectVersion(p *hashitools.Project) string {
+	if i, ok := p.Installer.(*Installer); ok {
+		return i.Version.String()
+	}
+
+	v, err := p.Version()
+	if err != nil || v == nil {
+		return ""
+	}
+
+	return v.String()
+}
+
 // Terraform wraps `terraform` execution into an easy-to-use API
 type Terraform struct {
 	// Path is the path to Terraform itself. If empty, "terraform"
@@ -45,6 +96,11 @@ type Terraform struct {
 	// Dir is the working directory where all Terraform commands are executed
 	Dir string
 
+	// Version is the version of the Terraform at Path. It is recorded
+	// with every version of the state in the state history. See
+	// ProjectVersion.
+	Version string
+
 	// Ui, if given, will be used to stream output from the Terraform commands.
 	// If this is nil, then the output will be logged but won't be visible
 	// to the user.
@@ -53,6 +109,11 @@ type Terraform struct {
 	// Variables is a list of variables to pass to Terraform.
 	Variables map[string]string
 
+	// Sensitive is the list of variables whose values are secret, such
+	// as credentials. Their values are replaced in the log and in the
+	// Terraform output shown on the Ui.
+	Sensitive []string
+
 	// Directory can be set to point to a directory where data can be
 	// stored. If this is set, then the state will be loaded/stored here
 	// automatically.
@@ -67,10 +128,39 @@ type Terraform struct {
 	// case we can't write it to a directory.
 	Directory directory.Backend
 	StateId   string
+
+	// StateHistory is how many versions of the state are kept so that
+	// they can be restored. If zero, DefaultStateHistory is used. If
+	// negative, no history is kept, and saved plans can't be made or
+	// applied because there is no state version to check them against.
+	StateHistory int
+
+	// LockTTL is how long the state lock is valid without being
+	// refreshed. The lock is only taken if Directory implements
+	// directory.Locker. If zero, directory.DefaultLockTTL is used.
+	LockTTL time.Duration
+
+	// Check, if set, is called while the state is locked, right before
+	// Terraform runs. If it returns an error, Terraform isn't run. This
+	// is used to make sure a saved plan is applied to the state it was
+	// made for.
+	Check func() error
+
+	// GracefulTimeout is how long Terraform is given to exit on its own
+	// after it was interrupted before it is killed. If zero,
+	// DefaultGracefulTimeout is used.
+	GracefulTimeout time.Duration
 }
 
 // Execute executes a raw Terraform command
 func (t *Terraform) Execute(commandRaw ...string) error {
+	return t.ExecuteContext(stdcontext.Background(), commandRaw...)
+}
+
+// ExecuteContext executes a raw Terraform command. If ctx is cancelled,
+// Terraform is interrupted so it can finish what it is doing and write
+// its state, which is then stored like after any other failed run.
+func (t *Terraform) ExecuteContext(ctx stdcontext.Context, commandRaw ...string) error {
 	command := make([]string, 1, len(commandRaw)*2)
 	command[0] = commandRaw[0]
 	commandArgs := commandRaw[1:]
@@ -85,9 +175,7 @@ func (t *Terraform) Execute(commandRaw ...string) error {
 		if err != nil {
 			return err
 		}
-		if execHelper.ShouldCleanup() {
-			defer os.Remove(varfile)
-		}
+		defer t.removeVarfile(varfile)
 
 		// Append the varfile onto our command.
 		command = append(command, "-var-file", varfile)
@@ -103,14 +191,24 @@ func (t *Terraform) Execute(commandRaw ...string) error {
 	stateSkip := false
 	stateSkip = command[0] == "get"
 
-	// Output needs state but not state-out; more hard-coding
+	// Output and plan need state but not state-out; more hard-coding
 	stateOutSkip := false
-	stateOutSkip = command[0] == "output"
+	stateOutSkip = command[0] == "output" || command[0] == "plan"
 
 	// If we care about state, then setup the state directory and
 	// load it up.
 	var stateDir, statePath string
 	if !stateSkip && t.StateId != "" && t.Directory != nil {
+		// Lock the state so nobody else changes it while we run. Output
+		// and plan only read the state so they don't need the lock.
+		if !stateOutSkip {
+			unlock, err := t.lock(command[0])
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+
 		var err error
 		stateDir, err = ioutil.TempDir("", "otto-tf")
 		if err != nil {
@@ -127,7 +225,7 @@ func (t *Terraform) Execute(commandRaw ...string) error {
 		// Load the state from the directory
 		data, err := t.Directory.GetBlob(t.StateId)
 		if err != nil {
-			return fmt.Errorf("Error loading Terraform state: %s", err)
+			return i18n.Errorf("terraform.state_load_err", err)
 		}
 		if data == nil && command[0] == "destroy" {
 			// Destroy we can just execute, we don't need state
@@ -138,7 +236,7 @@ func (t *Terraform) Execute(commandRaw ...string) error {
 			data.Close()
 		}
 		if err != nil {
-			return fmt.Errorf("Error writing Terraform state: %s", err)
+			return i18n.Errorf("terraform.state_write_err", err)
 		}
 
 		// Append the state to the args
@@ -148,6 +246,14 @@ func (t *Terraform) Execute(commandRaw ...string) error {
 		}
 	}
 
+	// The state is locked now, so it can't change between the check
+	// and the run.
+	if t.Check != nil {
+		if err := t.Check(); err != nil {
+			return err
+		}
+	}
+
 	// Append all the final args
 	command = append(command, commandArgs...)
 
@@ -163,49 +269,98 @@ func (t *Terraform) Execute(commandRaw ...string) error {
 	// Start the Terraform command. If there is an error we just store
 	// the error but can't exit yet because we have to store partial
 	// state if there is any.
-	err := execHelper.Run(t.Ui, cmd)
+	err := run(ctx, t.ui(), cmd, t.gracefulTimeout())
 	if err != nil {
-		err = fmt.Errorf("Error running Terraform: %s", err)
+		err = i18n.Errorf("terraform.run_err", err)
 	}
 
 	// Save the state file if we have it.
 	if t.StateId != "" && t.Directory != nil && statePath != "" && !stateOutSkip {
-		f, ferr := os.Open(statePath)
+		state, ferr := ioutil.ReadFile(statePath)
 		if ferr != nil {
-			return fmt.Errorf(
-				"Error reading Terraform state for saving: %s", ferr)
+			// If Terraform failed before writing any state, the stored
+			// state is unchanged and the run error is what matters.
+			if err != nil && os.IsNotExist(ferr) {
+				return err
+			}
+
+			return stateErr(err, i18n.Errorf("terraform.state_read_err", ferr))
 		}
 
 		// Store the state
 		derr := t.Directory.PutBlob(t.StateId, &directory.BlobData{
-			Data: f,
+			Data: bytes.NewReader(state),
 		})
 
-		// Always close the file
-		f.Close()
-
 		// If we couldn't save the data, then note the error. This is a
 		// _really_ bad error to get since there isn't a good way to
-		// recover. For now, we just copy the state to the pwd and note
-		// the user.
+		// recover. We copy the state to the pwd so it isn't lost and
+		// tell the user where it is.
 		if derr != nil {
-			// TODO: copy state
+			path, cerr := copyStateLocal(t.StateId, state)
+			if cerr != nil {
+				path = i18n.T("terraform.state_copy_err", cerr)
+			}
+
+			return stateErr(err, i18n.Errorf("terraform.state_save_err", derr, path))
+		}
 
-			err = fmt.Errorf(
-				"Failed to save Terraform state: %s\n\n"+
-					"This means that Otto was unable to store the state of your infrastructure.\n"+
-					"At this time, Otto doesn't support gracefully recovering from this\n"+
-					"scenario. The state should be in the path below. Please ask the\n"+
-					"community for assistance.",
-				derr)
+		// Keep a version of the state so it can be restored later. The
+		// state itself is saved at this point, so this is only a warning.
+		if _, herr := t.History().Save(state, command[0]); herr != nil {
+			log.Printf("[ERR] error saving state history: %s", herr)
+			if t.Ui != nil {
+				t.Ui.Message(i18n.T("terraform.state_history_err", herr))
+			}
 		}
 	}
 
 	return err
 }
 
-// Outputs reads the outputs from the configured directory storage.
+// stateErr returns err, the error saving the state. If Terraform itself
+// failed too, runErr is included so the cause of the failure isn't lost.
+func stateErr(runErr, err error) error {
+	if runErr == nil {
+		return err
+	}
+
+	return i18n.Errorf("terraform.run_state_err", runErr, err)
+}
+
+func (t *Terraform) gracefulTimeout() time.Duration {
+	if t.GracefulTimeout > 0 {
+		return t.GracefulTimeout
+	}
+
+	return DefaultGracefulTimeout
+}
+
+// History returns the version history of the state.
+func (t *Terraform) History() *StateHistory {
+	return &StateHistory{
+		Directory: t.Directory,
+		StateId:   t.StateId,
+		Retain:    t.StateHistory,
+
+		TerraformVersion: t.Version,
+	}
+}
+
+// Outputs reads the outputs from the configured directory storage as
+// strings. See TypedOutputs.
 func (t *Terraform) Outputs() (map[string]string, error) {
+	outputs, err := t.TypedOutputs()
+	if err != nil {
+		return nil, err
+	}
+
+	return outputs.Strings(), nil
+}
+
+// TypedOutputs reads the outputs with their types from the configured
+// directory storage.
+func (t *Terraform) TypedOutputs() (directory.Outputs, error) {
 	// Make a temporary file to store our state
 	tf, err := ioutil.TempFile("", "otto-tf")
 	if err != nil {
@@ -228,24 +383,127 @@ func (t *Terraform) Outputs() (map[string]string, error) {
 	}
 	tf.Close()
 	if err != nil {
-		return nil, fmt.Errorf("Error loading Terraform state: %s", err)
+		return nil, i18n.Errorf("terraform.state_load_err", err)
 	}
 
 	// Read the outputs as normal. Defers will clean up our temp file.
-	return Outputs(tf.Name())
+	return TypedOutputs(tf.Name())
 }
 
+// lock acquires the lock on the state if the directory supports locking
+// and returns a function that releases it. While the lock is held it is
+// refreshed in the background so long Terraform runs don't lose it.
+func (t *Terraform) lock(operation string) (func(), error) {
+	locker, ok := t.Directory.(directory.Locker)
+	if !ok {
+		log.Printf("[WARN] directory doesn't support locking, state %s not locked", t.StateId)
+		return func() {}, nil
+	}
+
+	l := directory.NewLock(t.StateId, "terraform "+operation)
+	if t.LockTTL > 0 {
+		l.TTL = t.LockTTL
+	}
+	if err := locker.Lock(l); err != nil {
+		if lerr, ok := err.(*directory.LockedError); ok {
+			return nil, i18n.Errorf("terraform.state_locked",
+				lerr.Lock.Holder, lerr.Lock.Operation,
+				lerr.Lock.Created.Format(time.RFC1123),
+				lerr.Lock.Expires.Format(time.RFC1123))
+		}
+
+		return nil, i18n.Errorf("terraform.state_lock_err", err)
+	}
+	log.Printf("[DEBUG] locked state %s (lock %s)", t.StateId, l.ID)
+
+	doneCh := make(chan struct{})
+	exitCh := make(chan struct{})
+	go func() {
+		defer close(exitCh)
+
+		ticker := time.NewTicker(l.TTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-doneCh:
+				return
+			case <-ticker.C:
+				if err := locker.Lock(l); err != nil {
+					log.Printf("[ERR] error refreshing state lock: %s", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		// Stop refreshing first so the lock isn't taken again after
+		// we release it.
+		close(doneCh)
+		<-exitCh
+
+		if err := locker.Unlock(l); err != nil {
+			log.Printf("[ERR] error unlocking state %s: %s", t.StateId, err)
+			if t.Ui != nil {
+				t.Ui.Message(i18n.T("terraform.state_unlock_err", err))
+			}
+		}
+	}, nil
+}
+
+// ui returns the Ui that Terraform output is mirrored to. The values of
+// sensitive variables are registered with the redact package so that
+// they are replaced in the output and in the log.
+func (t *Terraform) ui() ui.Ui {
+	for _, k := range t.Sensitive {
+		redact.Add(t.Variables[k])
+	}
+
+	if t.Ui == nil {
+		return nil
+	}
+
+	return &redact.Ui{Ui: t.Ui}
+}
+
+// varfile writes the variables to a var file in a new temporary
+// directory that only the current user can read, since the variables
+// usually contain credentials. Remove it with removeVarfile.
 func (t *Terraform) varfile() (string, error) {
-	f, err := ioutil.TempFile("", "otto-tf")
+	dir, err := ioutil.TempDir("", "otto-tf")
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	path := filepath.Join(dir, "vars.tfvars.json")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
 	if err != nil {
+		os.RemoveAll(dir)
 		return "", err
 	}
 
 	err = json.NewEncoder(f).Encode(t.Variables)
-	f.Close()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
-		os.Remove(f.Name())
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// removeVarfile removes a var file written by varfile, unless cleanup
+// is disabled for debugging.
+func (t *Terraform) removeVarfile(path string) {
+	if !execHelper.ShouldCleanup() {
+		log.Printf("[WARN] not removing var file, it may contain credentials: %s", path)
+		return
 	}
 
-	return f.Name(), err
+	os.RemoveAll(filepath.Dir(path))
 }
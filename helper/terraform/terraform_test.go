@@ -0,0 +1,603 @@
+package terraform
+
+import (
+	"bytes"
+	stdcontext "context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/otto/ui"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/kuuyee/otto-learn/helper/redact"
+)
+
+// testTerraformScript is a fake terraform. It reads the number in the
+// -state file, sleeps so concurrent runs overlap, and writes the number
+// plus one to the -state-out file. Plan adds a resource if there is no
+// state yet and writes the number to the -out file. Refresh writes
+// refreshed.tfstate next to the script to the -state-out file. Vars
+// prints the -var-file. Fail writes the state like apply and exits 1.
+// Interrupt runs until it gets SIGINT and then writes 42 to the
+// -state-out file, hang ignores SIGINT.
+const testTerraformScript = `#!/bin/sh
+cmd="$1"
+state=""
+out=""
+plan=""
+vars=""
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-state) state="$2"; shift ;;
+	-state-out) out="$2"; shift ;;
+	-out) plan="$2"; shift ;;
+	-var-file) vars="$2"; shift ;;
+	esac
+	shift
+done
+
+n=0
+if [ -n "$state" ] && [ -f "$state" ]; then
+	n=$(cat "$state")
+fi
+if [ "$cmd" = "interrupt" ]; then
+	trap 'echo interrupted; echo 42 > "$out"; exit 1' INT
+	echo started
+	while true; do sleep 0.1; done
+fi
+if [ "$cmd" = "hang" ]; then
+	trap '' INT
+	echo started
+	while true; do sleep 0.1; done
+fi
+if [ "$cmd" = "fail" ]; then
+	echo $((n + 1)) > "$out"
+	echo "fake failure" >&2
+	exit 1
+fi
+if [ "$cmd" = "vars" ]; then
+	cat "$vars"
+	exit 0
+fi
+if [ "$cmd" = "refresh" ]; then
+	cp "$(dirname "$0")/refreshed.tfstate" "$out"
+	exit 0
+fi
+if [ "$cmd" = "plan" ]; then
+	if [ "$n" = "0" ]; then
+		echo "+ aws_instance.web"
+		echo '    ami: "" => "ami-1"'
+	else
+		echo "No changes. Infrastructure is up-to-date."
+	fi
+	echo "$n" > "$plan"
+	exit 0
+fi
+sleep 0.5
+if [ -n "$out" ]; then
+	echo $((n + 1)) > "$out"
+fi
+`
+
+func TestTerraformExecute_lockConcurrent(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			tf := *tf
+			errs[i] = tf.Execute("apply")
+		}(i)
+	}
+	wg.Wait()
+
+	// Exactly one of them gets the lock
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+			if !strings.Contains(err.Error(), "pid") {
+				t.Fatalf("should show the holder: %s", err)
+			}
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	// The state was changed once and the lock was released
+	testTerraformState(t, tf, "1")
+	testTerraformUnlocked(t, tf)
+}
+
+func TestTerraformExecute_lockSequential(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 2; i++ {
+		if err := tf.Execute("apply"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	testTerraformState(t, tf, "2")
+	testTerraformUnlocked(t, tf)
+}
+
+func TestTerraformExecute_lockOtherState(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	other := *tf
+	other.StateId = "other"
+
+	// Different states don't block each other
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, tf := range []*Terraform{tf, &other} {
+		wg.Add(1)
+		go func(i int, tf *Terraform) {
+			defer wg.Done()
+			errs[i] = tf.Execute("apply")
+		}(i, tf)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	testTerraformState(t, tf, "1")
+	testTerraformState(t, &other, "1")
+}
+
+func TestTerraformExecute_locked(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	// A lock left behind by someone else
+	locker := tf.Directory.(directory.Locker)
+	l := directory.NewLock(tf.StateId, "terraform apply")
+	if err := locker.Lock(l); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := tf.Execute("apply"); err == nil {
+		t.Fatal("should error")
+	}
+	testTerraformState(t, tf, "")
+
+	// After force-unlock it works again
+	if err := locker.ForceUnlock(tf.StateId); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := tf.Execute("apply"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testTerraformState(t, tf, "1")
+}
+
+func TestTerraformExecute_check(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	// The check runs while the state is locked
+	locker := tf.Directory.(directory.Locker)
+	checkErr := errors.New("check failed")
+	tf.Check = func() error {
+		l, err := locker.GetLock(tf.StateId)
+		if err != nil {
+			return err
+		}
+		if l == nil {
+			t.Error("state should be locked")
+		}
+
+		return checkErr
+	}
+
+	// If the check fails, Terraform doesn't run
+	if err := tf.Execute("apply"); err != checkErr {
+		t.Fatalf("bad: %v", err)
+	}
+	testTerraformState(t, tf, "")
+	testTerraformUnlocked(t, tf)
+
+	tf.Check = func() error { return nil }
+	if err := tf.Execute("apply"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testTerraformState(t, tf, "1")
+}
+
+func TestTerraformExecute_lockRefresh(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	// The run takes longer than the TTL, so the lock has to be
+	// refreshed to keep others out.
+	tf.LockTTL = 150 * time.Millisecond
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- tf.Execute("apply")
+	}()
+
+	time.Sleep(350 * time.Millisecond)
+	other := directory.NewLock(tf.StateId, "terraform apply")
+	if err := tf.Directory.(directory.Locker).Lock(other); err == nil {
+		t.Fatal("lock should still be held")
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testTerraformUnlocked(t, tf)
+}
+
+func TestTerraformExecute_history(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 3; i++ {
+		if err := tf.Execute("apply"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	versions, err := tf.History().List()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 3 || versions[2].Operation != "apply" {
+		t.Fatalf("bad: %#v", versions)
+	}
+	testStateHistoryGet(t, tf.History(), 1, "1\n")
+	testStateHistoryGet(t, tf.History(), 3, "3\n")
+}
+
+func TestTerraformExecute_saveFailed(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+	tf.Directory = &testFailingBackend{Backend: tf.Directory, FailKey: tf.StateId}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(wd)
+
+	err = tf.Execute("apply")
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	// The state is copied to the working directory and the error says
+	// where it is
+	path := filepath.Join(dir, "otto-state.tfstate")
+	if !strings.Contains(err.Error(), "otto-state.tfstate") {
+		t.Fatalf("bad: %s", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.TrimSpace(string(data)) != "1" {
+		t.Fatalf("bad: %q", data)
+	}
+}
+
+func TestTerraformExecute_saveFailedRunFailed(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+	tf.Directory = &testFailingBackend{Backend: tf.Directory, FailKey: tf.StateId}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(wd)
+
+	// Both the Terraform failure and the failure saving the state
+	// are reported
+	err = tf.Execute("fail")
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "Error running Terraform") {
+		t.Fatalf("bad: %s", err)
+	}
+	if !strings.Contains(err.Error(), "otto-state.tfstate") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestTerraformExecuteContext_interrupt(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	go func() {
+		testTerraformOutput(t, tf, "started")
+		cancel()
+	}()
+
+	if err := tf.ExecuteContext(ctx, "interrupt"); err == nil {
+		t.Fatal("should error")
+	}
+
+	// Terraform exited on its own and its partial state was stored
+	if !testTerraformHasOutput(tf, "interrupted") {
+		t.Fatalf("terraform should get the interrupt: %#v", tf.Ui.(*testUi).Messages)
+	}
+	testTerraformState(t, tf, "42")
+	testTerraformUnlocked(t, tf)
+}
+
+func TestTerraformExecuteContext_timeout(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+	tf.GracefulTimeout = 200 * time.Millisecond
+
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	go func() {
+		testTerraformOutput(t, tf, "started")
+		cancel()
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- tf.ExecuteContext(ctx, "hang")
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("should error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("terraform should be killed after the timeout")
+	}
+
+	// Nothing was written, so the stored state is unchanged
+	testTerraformState(t, tf, "")
+	testTerraformUnlocked(t, tf)
+}
+
+func TestTerraformExecuteContext_force(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+	tf.GracefulTimeout = time.Minute
+
+	// A second interrupt kills Terraform without waiting for the timeout
+	shutdownCh := make(chan struct{})
+	ctx, cancel := shutdownContext(shutdownCh)
+	defer cancel()
+	go func() {
+		testTerraformOutput(t, tf, "started")
+		shutdownCh <- struct{}{}
+		shutdownCh <- struct{}{}
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- tf.ExecuteContext(ctx, "hang")
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("should error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("terraform should be killed after the second interrupt")
+	}
+
+	msg := strings.TrimSpace(i18n.T("terraform.interrupt_force"))
+	if !testTerraformHasOutput(tf, msg) {
+		t.Fatalf("bad: %#v", tf.Ui.(*testUi).Messages)
+	}
+	testTerraformUnlocked(t, tf)
+}
+
+func TestTerraformExecute_redact(t *testing.T) {
+	tf, dir := testTerraform(t)
+	defer os.RemoveAll(dir)
+	tf.StateId = ""
+	tf.Variables = map[string]string{
+		"aws_region":     "us-east-1",
+		"aws_secret_key": "s3cr3t-k3y",
+	}
+	tf.Sensitive = []string{"aws_secret_key"}
+
+	if err := tf.Execute("vars"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Terraform got the secret, but the Ui only sees the placeholder
+	output := strings.Join(tf.Ui.(*testUi).Messages, "")
+	if strings.Contains(output, "s3cr3t-k3y") {
+		t.Fatalf("secret in output: %s", output)
+	}
+	if !strings.Contains(output, redact.Placeholder) {
+		t.Fatalf("bad: %s", output)
+	}
+	if !strings.Contains(output, "us-east-1") {
+		t.Fatalf("other variables should be kept: %s", output)
+	}
+}
+
+func TestTerraformVarfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are different on windows")
+	}
+
+	tf := &Terraform{Variables: map[string]string{"foo": "bar"}}
+	path, err := tf.varfile()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0600 {
+		t.Fatalf("bad file mode: %o", mode)
+	}
+	fi, err = os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0700 {
+		t.Fatalf("bad dir mode: %o", mode)
+	}
+
+	// The whole directory is removed
+	tf.removeVarfile(path)
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Fatalf("var file directory not removed: %s", err)
+	}
+}
+
+// testTerraformOutput waits until the fake terraform printed line.
+func testTerraformOutput(t *testing.T, tf *Terraform, line string) {
+	for i := 0; i < 100; i++ {
+		if testTerraformHasOutput(tf, line) {
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Errorf("terraform didn't print %q", line)
+}
+
+func testTerraformHasOutput(tf *Terraform, line string) bool {
+	u := tf.Ui.(*testUi)
+	u.Lock()
+	defer u.Unlock()
+
+	for _, msg := range u.Messages {
+		if strings.TrimSpace(msg) == line {
+			return true
+		}
+	}
+
+	return false
+}
+
+// testFailingBackend fails to write the blob FailKey.
+type testFailingBackend struct {
+	directory.Backend
+	FailKey string
+}
+
+func (b *testFailingBackend) PutBlob(key string, data *directory.BlobData) error {
+	if key == b.FailKey {
+		data.Close()
+		return errors.New("put failed")
+	}
+
+	return b.Backend.PutBlob(key, data)
+}
+
+// testTerraform returns a Terraform that runs the fake terraform script
+// with its state in a file directory.
+func testTerraform(t *testing.T) (*Terraform, string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake terraform is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "otto-tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := filepath.Join(dir, "terraform")
+	if err := ioutil.WriteFile(path, []byte(testTerraformScript), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &Terraform{
+		Path:      path,
+		Dir:       dir,
+		Ui:        new(testUi),
+		Directory: &directory.FileBackend{Dir: filepath.Join(dir, "directory")},
+		StateId:   "state",
+	}, dir
+}
+
+// testTerraformState checks the stored state. An empty expected value
+// means no state was stored.
+func testTerraformState(t *testing.T, tf *Terraform, expected string) {
+	data, err := tf.Directory.GetBlob(tf.StateId)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if data == nil {
+		if expected != "" {
+			t.Fatalf("no state, expected %q", expected)
+		}
+
+		return
+	}
+	defer data.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(data.Data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := strings.TrimSpace(buf.String()); actual != expected {
+		t.Fatalf("bad state: %q, expected %q", actual, expected)
+	}
+}
+
+func testTerraformUnlocked(t *testing.T, tf *Terraform) {
+	l, err := tf.Directory.(directory.Locker).GetLock(tf.StateId)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if l != nil {
+		t.Fatalf("state still locked: %#v", l)
+	}
+}
+
+// testUi is a ui.Ui that records messages.
+type testUi struct {
+	sync.Mutex
+	Messages []string
+}
+
+func (u *testUi) Header(msg string)  { u.add(msg) }
+func (u *testUi) Message(msg string) { u.add(msg) }
+func (u *testUi) Raw(msg string)     { u.add(msg) }
+
+func (u *testUi) Input(*ui.InputOpts) (string, error) {
+	return "", nil
+}
+
+func (u *testUi) add(msg string) {
+	u.Lock()
+	defer u.Unlock()
+	u.Messages = append(u.Messages, msg)
+}
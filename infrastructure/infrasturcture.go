@@ -22,6 +22,10 @@ type Infrastructure interface {
 	Execute(*Context) error
 	Compile(*Context) (*CompileResult, error)
 	Flavors() []string
+
+	// FlavorVariables 返回flavor声明的Terraform变量，Appfile只能设置
+	// 这些变量。返回nil表示不知道声明了哪些变量
+	FlavorVariables(flavor string) []string
 }
 
 // Context是操作infrastructure的上下文环境。下面的字段
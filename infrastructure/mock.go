@@ -0,0 +1,58 @@
+package infrastructure
+
+// Mock 是infrastructure.Infrastructure的模拟实现，用于测试
+type Mock struct {
+	CredsCalled  bool
+	CredsContext *Context
+	CredsResult  map[string]string
+	CredsErr     error
+
+	VerifyCredsCalled  bool
+	VerifyCredsContext *Context
+	VerifyCredsErr     error
+
+	ExecuteCalled  bool
+	ExecuteContext *Context
+	ExecuteErr     error
+
+	CompileCalled  bool
+	CompileContext *Context
+	CompileResult  *CompileResult
+	CompileErr     error
+
+	FlavorsResult []string
+
+	FlavorVariablesResult []string
+}
+
+func (m *Mock) Creds(ctx *Context) (map[string]string, error) {
+	m.CredsCalled = true
+	m.CredsContext = ctx
+	return m.CredsResult, m.CredsErr
+}
+
+func (m *Mock) VerifyCreds(ctx *Context) error {
+	m.VerifyCredsCalled = true
+	m.VerifyCredsContext = ctx
+	return m.VerifyCredsErr
+}
+
+func (m *Mock) Execute(ctx *Context) error {
+	m.ExecuteCalled = true
+	m.ExecuteContext = ctx
+	return m.ExecuteErr
+}
+
+func (m *Mock) Compile(ctx *Context) (*CompileResult, error) {
+	m.CompileCalled = true
+	m.CompileContext = ctx
+	return m.CompileResult, m.CompileErr
+}
+
+func (m *Mock) Flavors() []string {
+	return m.FlavorsResult
+}
+
+func (m *Mock) FlavorVariables(flavor string) []string {
+	return m.FlavorVariablesResult
+}
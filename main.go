@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/kuuyee/otto-learn/helper/redact"
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/panicwrap"
 	"github.com/mitchellh/prefixedio"
@@ -26,7 +27,6 @@ func realMain() int {
 	//fmt.Printf("warpConfig : %+v\n", wrapConfig)
 
 	if !panicwrap.Wrapped(&wrapConfig) {
-		fmt.Println("没有封装wrapConfig配置")
 		logWriter, err := logOutput()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "不能设置Log输出到：%s", err)
@@ -78,24 +78,32 @@ func realMain() int {
 
 	}
 
-	fmt.Printf("[KuuYee_DEBUG]====> %s\n", "main.go/realMain/81")
 	//调用真正的main函数
 	return wrappedMain()
 }
 
 func wrappedMain() int {
-	log.SetOutput(os.Stderr)
+	// 父进程把这个输出写到日志文件和crash.log，所以在这里去掉
+	// 敏感的值，比如凭证
+	log.SetOutput(redact.NewWriter(os.Stderr))
 	log.Printf("[INFO] KuuYee Otto version: %s %s %s", Version, VersionPrerelease, GitCommit)
 
 	// 设置信号处理器
 	initSignalHandlers()
 
 	// 载入配置
-	config := BuiltinConfig
-	fmt.Printf("[KuuYee]====> config: %+v\n", config)
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	log.Printf("[DEBUG] config: %+v", config)
 
 	// 运行检查点
-	go runCheckpoint(&config)
+	go runCheckpoint(config)
+
+	// 初始化命令
+	initCommands(config)
 
 	// 获取命令行参数。通过"--version"和"-v"显示版本
 	args := os.Args[1:]
@@ -5,17 +5,20 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
-	"github.com/hashicorp/otto/directory"
 	"github.com/hashicorp/otto/helper/localaddr"
 	"github.com/hashicorp/otto/ui"
 	"github.com/hashicorp/terraform/dag"
 	"github.com/kuuyee/otto-learn/app"
 	"github.com/kuuyee/otto-learn/appfile"
 	"github.com/kuuyee/otto-learn/context"
+	"github.com/kuuyee/otto-learn/directory"
 	"github.com/kuuyee/otto-learn/foundation"
+	"github.com/kuuyee/otto-learn/helper/i18n"
 	"github.com/kuuyee/otto-learn/infrastructure"
 )
 
@@ -31,6 +34,15 @@ type Core struct {
 	localDir        string
 	compileDir      string
 	ui              ui.Ui
+
+	terraformVersion string
+	terraformMirror  string
+	shutdownCh       <-chan struct{}
+
+	// credsPassword 是这次运行中已经输入的认证加密密码，
+	// 这样每次运行只需要输入一次密码
+	credsPassword string
+	credsLock     sync.Mutex
 }
 
 // CoreConfig是创建NewCore的配置
@@ -67,6 +79,49 @@ type CoreConfig struct {
 
 	// Ui 用来于用户交互
 	Ui ui.Ui
+
+	// TerraformVersion 固定使用的Terraform版本，Appfile的infrastructure
+	// 中设置的版本优先。TerraformMirror 是安装固定版本的镜像，参看
+	// context.Shared
+	TerraformVersion string
+	TerraformMirror  string
+
+	// ShutdownCh 在用户中断Otto的时候收到消息，参看context.Shared
+	ShutdownCh <-chan struct{}
+}
+
+// Flavors 返回Infrastructures中一种infrastructure类型支持的flavor。
+// 类型不存在或者实现没有列出flavor时返回nil。可以用作
+// appfile.CompileOpts的Flavors
+func (c *CoreConfig) Flavors(infraType string) []string {
+	f, ok := c.Infrastructures[infraType]
+	if !ok {
+		return nil
+	}
+
+	infra, err := f()
+	if err != nil {
+		return nil
+	}
+
+	return infra.Flavors()
+}
+
+// FlavorVariables 返回Infrastructures中一种infrastructure类型的flavor
+// 声明的变量。类型不存在或者实现不知道声明了哪些变量时返回nil。可以
+// 用作appfile.CompileOpts的Variables
+func (c *CoreConfig) FlavorVariables(infraType, flavor string) []string {
+	f, ok := c.Infrastructures[infraType]
+	if !ok {
+		return nil
+	}
+
+	infra, err := f()
+	if err != nil {
+		return nil
+	}
+
+	return infra.FlavorVariables(flavor)
 }
 
 // NewCore创建一个core
@@ -85,6 +140,10 @@ func NewCore(c *CoreConfig) (*Core, error) {
 		localDir:        c.LocalDir,
 		compileDir:      c.CompileDir,
 		ui:              c.Ui,
+
+		terraformVersion: c.TerraformVersion,
+		terraformMirror:  c.TerraformMirror,
+		shutdownCh:       c.ShutdownCh,
 	}, nil
 }
 
@@ -107,9 +166,8 @@ func (c *Core) Compile() error {
 
 	// 编译Infrastructure给应用
 	log.Printf("[INFO] 运行infra编译...")
-	c.ui.Message("2015-12-09 编译 infra...")
+	c.ui.Message(i18n.T("core.compile.infra"))
 	if _, err := infra.Compile(infraCtx); err != nil {
-		fmt.Printf("[KuuYee]====> 编译Infra 报错\n")
 		return err
 	}
 
@@ -118,27 +176,22 @@ func (c *Core) Compile() error {
 	log.Printf("[INFO] 运行foundation编译...")
 	for i, f := range foundcations {
 		ctx := foundationCtxs[i]
-		c.ui.Message(fmt.Sprintf(
-			"编译foundation: %s", ctx.Tuple.Type))
+		c.ui.Message(i18n.T("core.compile.foundation", ctx.Tuple.Type))
 		if _, err := f.Compile(ctx); err != nil {
-			fmt.Printf("[KuuYee]====> 编译foundcations 报错\n")
 			return err
 		}
 	}
 
-	fmt.Printf("[KuuYee]====> 编译foundcations 结束\n")
-
 	//排除所有依赖，并全部编译。我们必须编译每个依赖以备
 	//dev构建
 	var resultLock sync.Mutex
 	results := make([]*app.CompileResult, 0, len(c.appfileCompiled.Graph.Vertices()))
 	err = c.walk(func(app app.App, ctx *app.Context, root bool) error {
 		if !root {
-			c.ui.Header(fmt.Sprintf(
-				"编译依赖 '%s'...", ctx.Appfile.Application.Name))
+			c.ui.Header(i18n.T(
+				"core.compile.dep", ctx.Appfile.Application.Name))
 		} else {
-			c.ui.Header(fmt.Sprintf(
-				"编译主应用"))
+			c.ui.Header(i18n.T("core.compile.main"))
 		}
 
 		// 如果是root，设置dev dep fragments
@@ -154,7 +207,6 @@ func (c *Core) Compile() error {
 			}
 			resultLock.Unlock()
 		}
-		fmt.Printf("[KuuYee]====> root 阶段\n")
 		// 编译！
 		result, err := app.Compile(ctx)
 		if err != nil {
@@ -194,10 +246,426 @@ func (c *Core) Compile() error {
 	return err
 }
 
+// Build 为当前编译的Appfile构建可部署的产物
+func (c *Core) Build() error {
+	// 获取infra实现
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return err
+	}
+	if err := c.creds(infra, infraCtx); err != nil {
+		return err
+	}
+
+	// 这个任务只使用root应用，上游依赖不影响构建
+	rootApp, rootCtx, err := c.root()
+	if err != nil {
+		return err
+	}
+
+	// 把认证传递给应用
+	rootCtx.InfraCreds = infraCtx.InfraCreds
+
+	err = rootApp.Build(rootCtx)
+
+	// 记录最后一次构建
+	build := &directory.Build{
+		Lookup: c.appLookup(c.appfile, infraCtx.Infra),
+		State:  directory.BuildStateSuccess,
+		Time:   time.Now().UTC(),
+	}
+	if err != nil {
+		build.State = directory.BuildStateFail
+	}
+	if berr := c.dir.PutBuild(build); berr != nil && err == nil {
+		err = i18n.Errorf("core.build.save_err", berr)
+	}
+
+	return err
+}
+
+// Deploy 部署应用
+//
+// Deploy 支持子动作，通过action和args指定。action为""时执行
+// 默认的部署动作。部署前infrastructure必须已经创建好
+func (c *Core) Deploy(action string, args []string) error {
+	// 获取infra实现
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return err
+	}
+
+	// 部署需要infrastructure已经可用，help除外
+	if action != "help" {
+		if err := c.infraReady(infraCtx); err != nil {
+			return err
+		}
+	}
+
+	// 特殊情况: 在`help`或`info`时不获取认证
+	if action != "help" && action != "info" {
+		if err := c.creds(infra, infraCtx); err != nil {
+			return err
+		}
+	}
+
+	// 这个任务只使用root应用，上游依赖不影响部署
+	rootApp, rootCtx, err := c.root()
+	if err != nil {
+		return err
+	}
+
+	// 把认证和请求的动作传递给应用
+	rootCtx.InfraCreds = infraCtx.InfraCreds
+	rootCtx.Action = action
+	rootCtx.ActionArgs = args
+
+	// help和info不改变部署状态，直接执行
+	if action == "help" || action == "info" {
+		return rootApp.Deploy(rootCtx)
+	}
+
+	// 在部署之前先把部署记录到目录中，这样即使部署失败
+	// 也能找到部署
+	deploy, err := c.deploy(infraCtx)
+	if err != nil {
+		return err
+	}
+
+	err = rootApp.Deploy(rootCtx)
+	switch {
+	case err != nil:
+		deploy.MarkFailed()
+	case action == "destroy":
+		deploy.MarkGone()
+	default:
+		deploy.MarkSuccessful()
+	}
+
+	// 保存部署状态
+	if derr := c.dir.PutDeploy(deploy); derr != nil && err == nil {
+		err = i18n.Errorf("core.deploy.save_err", derr)
+	}
+
+	return err
+}
+
+// Infra 管理Appfile的infrastructure
+//
+// Infra 支持子动作，通过action和args指定。有两个特殊的动作：""
+// (空字符串)创建或更新完整的infrastructure，"destroy"销毁
+// infrastructure。其它的动作直接交给infrastructure实现处理
+func (c *Core) Infra(action string, args []string) error {
+	// 获取infra实现
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return err
+	}
+
+	// 销毁之前确保没有应用还部署在这个infrastructure上
+	if action == "destroy" {
+		if err := c.noDeploys(infraCtx); err != nil {
+			return err
+		}
+	}
+
+	// 只有创建、销毁、计划和检查需要认证
+	if action == "" || action == "destroy" || action == "plan" || action == "check" {
+		if err := c.creds(infra, infraCtx); err != nil {
+			return err
+		}
+	}
+
+	// 设置动作和参数
+	infraCtx.Action = action
+	infraCtx.ActionArgs = args
+
+	// 创建和销毁需要foundation
+	var foundations []foundation.Foundation
+	var foundationCtxs []*foundation.Context
+	if action == "" || action == "destroy" {
+		foundations, foundationCtxs, err = c.foundations()
+		if err != nil {
+			return err
+		}
+	}
+
+	// 如果不是销毁，现在就执行
+	if action != "destroy" {
+		if err := infra.Execute(infraCtx); err != nil {
+			return err
+		}
+	}
+
+	// 执行foundation的infrastructure。只有创建和销毁会执行，因为
+	// 只有这两种情况会装载foundation
+	foundationDirs := make([]string, len(foundationCtxs))
+	for i, ctx := range foundationCtxs {
+		foundationDirs[i] = ctx.Dir
+	}
+
+	// 刚刚执行的infrastructure改变了它的记录，foundation需要新的输出
+	var infraRecord *directory.Infra
+	if len(foundations) > 0 {
+		infraRecord, err = c.infraRecord(infraCtx.Infra)
+		if err != nil {
+			return err
+		}
+	}
+	for i, f := range foundations {
+		ctx := foundationCtxs[i]
+		ctx.Action = action
+		ctx.ActionArgs = args
+		ctx.InfraCreds = infraCtx.InfraCreds
+		ctx.FoundationDirs = foundationDirs
+		ctx.Infra = infraRecord
+
+		log.Printf(
+			"[INFO] 在foundation '%s' 上执行infra动作 '%s'",
+			ctx.Tuple.Type, action)
+		switch action {
+		case "":
+			infraCtx.Ui.Header(i18n.T(
+				"core.infra.foundation_build", ctx.Tuple.Type))
+		case "destroy":
+			infraCtx.Ui.Header(i18n.T(
+				"core.infra.foundation_destroy", ctx.Tuple.Type))
+		}
+
+		if err := f.Infra(ctx); err != nil {
+			return err
+		}
+	}
+
+	// 销毁的时候最后执行infrastructure，和创建相反，因为必须先
+	// 销毁使用这个infrastructure的foundation
+	if action == "destroy" {
+		if err := infra.Execute(infraCtx); err != nil {
+			return err
+		}
+	}
+
+	// 输出结果
+	switch action {
+	case "":
+		infraCtx.Ui.Header(i18n.T("core.infra.created_header"))
+		infraCtx.Ui.Message(i18n.T("core.infra.created"))
+	case "destroy":
+		infraCtx.Ui.Header(i18n.T("core.infra.destroyed_header"))
+		infraCtx.Ui.Message(i18n.T("core.infra.destroyed"))
+	}
+
+	return nil
+}
+
+// noDeploys 验证Appfile中没有任何应用还部署在infrastructure上
+func (c *Core) noDeploys(infraCtx *infrastructure.Context) error {
+	var deployed []string
+	for _, raw := range c.appfileCompiled.Graph.Vertices() {
+		f := raw.(*appfile.CompiledGraphVertex).File
+		deploy, err := c.dir.GetDeploy(&directory.Deploy{
+			Lookup: c.appLookup(f, infraCtx.Infra)})
+		if err != nil {
+			return i18n.Errorf("core.deploy.lookup_err", err)
+		}
+
+		if deploy.IsDeployed() || deploy.IsFailed() {
+			deployed = append(deployed, f.Application.Name)
+		}
+	}
+
+	if len(deployed) > 0 {
+		return i18n.Errorf("core.infra.deployed", strings.Join(deployed, ", "))
+	}
+
+	return nil
+}
+
+// Dev 管理应用的开发环境
+//
+// Dev 支持子动作，通过action和args指定。action为""或"up"时创建
+// 开发环境，其它的动作有ssh、halt、destroy和address。子动作由
+// 应用实现通过app.Context.Action路由
+func (c *Core) Dev(action string, args []string) error {
+	// "up"是默认动作的别名
+	if action == "up" {
+		action = ""
+	}
+
+	// 确保已经编译过
+	if _, err := os.Stat(c.compileDir); err != nil {
+		if os.IsNotExist(err) {
+			return i18n.Errorf("core.not_compiled")
+		}
+
+		return err
+	}
+
+	// 需要开发环境已经创建的动作
+	state, err := readDevState(c.localDir)
+	if err != nil {
+		return i18n.Errorf("core.dev.state_read_err", err)
+	}
+	if action == "ssh" || action == "halt" {
+		if state == DevStateNone {
+			return i18n.Errorf("core.dev.not_created")
+		}
+	}
+
+	// 单独获取root数据，因为所有依赖的函数调用都需要它
+	rootApp, rootCtx, err := c.root()
+	if err != nil {
+		return err
+	}
+
+	// 创建开发环境时先构建所有依赖的开发环境片段
+	if action == "" {
+		if err := c.devDeps(rootCtx); err != nil {
+			return err
+		}
+	}
+
+	// 所有的依赖都已经构建或装载，现在可以构建完整的开发环境
+	log.Printf("[INFO] 在root应用上执行dev: %q", action)
+	rootCtx.Action = action
+	rootCtx.ActionArgs = args
+	if err := rootApp.Dev(rootCtx); err != nil {
+		return err
+	}
+
+	// 记录开发环境的状态
+	switch action {
+	case "":
+		state = DevStateReady
+	case "halt":
+		state = DevStateHalted
+	case "destroy":
+		state = DevStateNone
+	default:
+		return nil
+	}
+	if err := writeDevState(c.localDir, state); err != nil {
+		return i18n.Errorf("core.dev.state_save_err", err)
+	}
+
+	return nil
+}
+
+// devDeps 构建所有依赖的开发环境片段。构建的结果缓存在
+// 依赖的CacheDir中，已经缓存的依赖不会再构建
+func (c *Core) devDeps(rootCtx *app.Context) error {
+	return c.walk(func(appImpl app.App, ctx *app.Context, root bool) error {
+		// root是特殊情况，在这里构建真正的开发环境
+		if root {
+			return nil
+		}
+
+		// 检查是否已经缓存，如果缓存了就直接使用
+		cachePath := filepath.Join(ctx.CacheDir, "dev-dep.json")
+		if _, err := app.ReadDevDep(cachePath); err == nil {
+			ctx.Ui.Header(i18n.T(
+				"core.dev.dep_cached", ctx.Appfile.Application.Name))
+			return nil
+		}
+
+		// 构建开发依赖
+		ctx.Ui.Header(i18n.T(
+			"core.dev.dep_building", ctx.Appfile.Application.Name))
+		dep, err := appImpl.DevDep(rootCtx, ctx)
+		if err != nil {
+			return i18n.Errorf(
+				"core.dev.dep_build_err", ctx.Appfile.Application.Name, err)
+		}
+
+		// 如果依赖有文件，把文件改成相对路径并保存到缓存目录
+		if dep != nil && len(dep.Files) > 0 {
+			if err := dep.RelFiles(ctx.CacheDir); err != nil {
+				return i18n.Errorf(
+					"core.dev.dep_cache_err", ctx.Appfile.Application.Name, err)
+			}
+
+			if err := app.WriteDevDep(cachePath, dep); err != nil {
+				return i18n.Errorf(
+					"core.dev.dep_cache_err", ctx.Appfile.Application.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// infraReady 验证Appfile使用的infrastructure已经创建好
+func (c *Core) infraReady(infraCtx *infrastructure.Context) error {
+	infra, err := c.infraRecord(infraCtx.Infra)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case infra.IsPartial():
+		return i18n.Errorf("core.infra.partial")
+	case !infra.IsReady():
+		return i18n.Errorf("core.infra.not_ready")
+	}
+
+	return nil
+}
+
+// infraRecord 从目录读取infrastructure的记录，还没有创建时返回nil
+func (c *Core) infraRecord(config *appfile.Infrastructure) (*directory.Infra, error) {
+	infra, err := c.dir.GetInfra(&directory.Infra{
+		Lookup: directory.Lookup{Infra: config.Name}})
+	if err != nil {
+		return nil, i18n.Errorf("core.infra.lookup_err", err)
+	}
+
+	return infra, nil
+}
+
+// deploy 返回root应用在当前infrastructure上的部署记录，如果
+// 不存在就创建一个新的部署记录
+func (c *Core) deploy(infraCtx *infrastructure.Context) (*directory.Deploy, error) {
+	lookup := c.appLookup(c.appfile, infraCtx.Infra)
+	deploy, err := c.dir.GetDeploy(&directory.Deploy{Lookup: lookup})
+	if err != nil {
+		return nil, i18n.Errorf("core.deploy.lookup_err", err)
+	}
+	if deploy != nil {
+		return deploy, nil
+	}
+
+	deploy = &directory.Deploy{Lookup: lookup, State: directory.DeployStateNew}
+	if err := c.dir.PutDeploy(deploy); err != nil {
+		return nil, i18n.Errorf("core.deploy.save_err", err)
+	}
+
+	return deploy, nil
+}
+
+// root 返回Appfile中root应用的实现和上下文
+func (c *Core) root() (app.App, *app.Context, error) {
+	root, err := c.appfileCompiled.Graph.Root()
+	if err != nil {
+		return nil, nil, i18n.Errorf("core.app.load_err", err)
+	}
+
+	rootCtx, err := c.appContext(root.(*appfile.CompiledGraphVertex).File)
+	if err != nil {
+		return nil, nil, i18n.Errorf("core.app.load_err", err)
+	}
+
+	rootApp, err := c.app(rootCtx)
+	if err != nil {
+		return nil, nil, i18n.Errorf("core.app.load_err", err)
+	}
+
+	return rootApp, rootCtx, nil
+}
+
 func (c *Core) walk(f func(app.App, *app.Context, bool) error) error {
 	root, err := c.appfileCompiled.Graph.Root()
 	if err != nil {
-		return fmt.Errorf("装载App报错: %s", err)
+		return i18n.Errorf("core.app.load_err", err)
 	}
 
 	//Walk the appfile graph
@@ -224,14 +692,14 @@ func (c *Core) walk(f func(app.App, *app.Context, bool) error) error {
 		// 给appfile获取App上下文
 		appCtx, err := c.appContext(v.File)
 		if err != nil {
-			return fmt.Errorf(
-				"loading Appfile for '%s': %s 报错", dag.VertexName(raw), err)
+			return i18n.Errorf(
+				"core.app.load_vertex_err", dag.VertexName(raw), err)
 		}
 
 		app, err := c.app(appCtx)
 		if err != nil {
-			return fmt.Errorf(
-				"获取App实现报错 '%s': %s", dag.VertexName(raw), err)
+			return i18n.Errorf(
+				"core.app.impl_err", dag.VertexName(raw), err)
 		}
 
 		// 执行回调
@@ -243,16 +711,14 @@ func (c *Core) infra() (infrastructure.Infrastructure, *infrastructure.Context,
 	// 取得infrastructure配置
 	config := c.appfile.ActiveInfrastructure()
 	if config == nil {
-		return nil, nil, fmt.Errorf(
-			"infrastructure在Appfile中没找到： %s", c.appfile.Project.Infrastructure)
+		return nil, nil, i18n.Errorf(
+			"core.infra.not_found", c.appfile.Project.Infrastructure)
 	}
 
 	// 获取infrastructure工厂
 	f, ok := c.infras[config.Type]
 	if !ok {
-		return nil, nil, fmt.Errorf(
-			"infrastructure类型不支持：%s",
-			config.Type)
+		return nil, nil, i18n.Errorf("core.infra.unsupported", config.Type)
 	}
 
 	// 开始实现infrastructure
@@ -261,6 +727,16 @@ func (c *Core) infra() (infrastructure.Infrastructure, *infrastructure.Context,
 		return nil, nil, err
 	}
 
+	// 拒绝infrastructure不支持的flavor，而不是等到编译的时候才失败
+	if err := config.ValidateFlavor(infra.Flavors()); err != nil {
+		return nil, nil, err
+	}
+
+	// 同样拒绝flavor没有声明的变量，否则Terraform会忽略它们
+	if err := config.ValidateVariables(infra.FlavorVariables(config.Flavor)); err != nil {
+		return nil, nil, err
+	}
+
 	// 数据输出目录
 	outputDir := filepath.Join(
 		c.compileDir, fmt.Sprintf("infra-%s", c.appfile.Project.Infrastructure))
@@ -270,20 +746,33 @@ func (c *Core) infra() (infrastructure.Infrastructure, *infrastructure.Context,
 		Dir:   outputDir,
 		Infra: config,
 		Shared: context.Shared{
-			Appfile:    c.appfile,
-			InstallDir: filepath.Join(c.dataDir, "binaries"),
-			Directory:  c.dir,
-			Ui:         c.ui,
+			Appfile:          c.appfile,
+			InstallDir:       filepath.Join(c.dataDir, "binaries"),
+			TerraformVersion: c.tfVersion(),
+			TerraformMirror:  c.terraformMirror,
+			ShutdownCh:       c.shutdownCh,
+			Directory:        c.dir,
+			Ui:               c.ui,
 		},
 	}, nil
 }
 
+// tfVersion 返回固定的Terraform版本。Appfile的infrastructure中设置的
+// 版本优先于全局配置
+func (c *Core) tfVersion() string {
+	if config := c.appfile.ActiveInfrastructure(); config != nil && config.TerraformVersion != "" {
+		return config.TerraformVersion
+	}
+
+	return c.terraformVersion
+}
+
 func (c *Core) foundations() ([]foundation.Foundation, []*foundation.Context, error) {
 	// 取得infrastructure配置
 	config := c.appfile.ActiveInfrastructure()
 	if config == nil {
-		return nil, nil, fmt.Errorf(
-			"infrastructure在appfile中没找到：%s", c.appfile.Project.Infrastructure)
+		return nil, nil, i18n.Errorf(
+			"core.infra.not_found", c.appfile.Project.Infrastructure)
 	}
 
 	// 如果没有foundation，返回nil
@@ -291,6 +780,12 @@ func (c *Core) foundations() ([]foundation.Foundation, []*foundation.Context, er
 		return nil, nil, nil
 	}
 
+	// infrastructure的记录，foundation通过它读取infrastructure的输出
+	infra, err := c.infraRecord(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// 给列表创建一个数组
 	fs := make([]foundation.Foundation, 0, len(config.Foundations))
 	ctxs := make([]*foundation.Context, 0, cap(fs))
@@ -307,8 +802,7 @@ func (c *Core) foundations() ([]foundation.Foundation, []*foundation.Context, er
 		// 查找匹配的foundation
 		fun := foundation.TupleMap(c.foundationMap).Lookup(tuple)
 		if fun == nil {
-			return nil, nil, fmt.Errorf(
-				"tuple的foundation实现没找到: %s", tuple)
+			return nil, nil, i18n.Errorf("core.foundation.not_found", tuple)
 		}
 
 		// 实例化实现
@@ -327,10 +821,14 @@ func (c *Core) foundations() ([]foundation.Foundation, []*foundation.Context, er
 			Dir:    outputDir,
 			Tuple:  tuple,
 			Shared: context.Shared{
-				Appfile:    c.appfile,
-				InstallDir: filepath.Join(c.dataDir, "binaries."),
-				Directory:  c.dir,
-				Ui:         c.ui,
+				Appfile:          c.appfile,
+				InstallDir:       filepath.Join(c.dataDir, "binaries"),
+				TerraformVersion: c.tfVersion(),
+				TerraformMirror:  c.terraformMirror,
+				ShutdownCh:       c.shutdownCh,
+				Directory:        c.dir,
+				Infra:            infra,
+				Ui:               c.ui,
 			},
 		}
 
@@ -346,8 +844,14 @@ func (c *Core) appContext(f *appfile.File) (*app.Context, error) {
 	// 我们需要配置可用的Infrastructure,以便后面额可以build tuple
 	config := f.ActiveInfrastructure()
 	if config == nil {
-		return nil, fmt.Errorf(
-			"没有在appfile中找到infrastructure ：%s", f.Project.Infrastructure)
+		return nil, i18n.Errorf(
+			"core.infra.not_found", f.Project.Infrastructure)
+	}
+
+	// infrastructure的记录，应用通过它读取infrastructure的输出
+	infra, err := c.infraRecord(config)
+	if err != nil {
+		return nil, err
 	}
 
 	// The tuple we're looking for is the application type, the
@@ -370,8 +874,7 @@ func (c *Core) appContext(f *appfile.File) (*app.Context, error) {
 	//app的缓存目录
 	cacheDir := filepath.Join(c.dataDir, "cache", f.ID)
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return nil, fmt.Errorf(
-			"创建缓存目录报错 '%s': %s", cacheDir, err)
+		return nil, i18n.Errorf("core.app.cache_dir_err", cacheDir, err)
 	}
 
 	//为foundation构建context.We use this
@@ -388,8 +891,7 @@ func (c *Core) appContext(f *appfile.File) (*app.Context, error) {
 	}
 	ip, err := ipDB.IP()
 	if err != nil {
-		return nil, fmt.Errorf(
-			"接受dev IP地址报错： %s", err)
+		return nil, i18n.Errorf("core.app.dev_ip_err", err)
 	}
 	return &app.Context{
 		Dir:          outputDir,
@@ -399,11 +901,15 @@ func (c *Core) appContext(f *appfile.File) (*app.Context, error) {
 		Application:  f.Application,
 		DevIPAddress: ip.String(),
 		Shared: context.Shared{
-			Appfile:        f,
-			FoundationDirs: foundationDirs,
-			InstallDir:     filepath.Join(c.dataDir, "binaries"),
-			Directory:      c.dir,
-			Ui:             c.ui,
+			Appfile:          f,
+			FoundationDirs:   foundationDirs,
+			InstallDir:       filepath.Join(c.dataDir, "binaries"),
+			TerraformVersion: c.tfVersion(),
+			TerraformMirror:  c.terraformMirror,
+			ShutdownCh:       c.shutdownCh,
+			Directory:        c.dir,
+			Infra:            infra,
+			Ui:               c.ui,
 		},
 	}, nil
 }
@@ -414,15 +920,13 @@ func (c *Core) app(ctx *app.Context) (app.App, error) {
 	// 查找app实现，factory
 	f := app.TupleMap(c.apps).Lookup(ctx.Tuple)
 	if f == nil {
-		return nil, fmt.Errorf(
-			"tuple的app实现没有找到： %s", ctx.Tuple)
+		return nil, i18n.Errorf("core.app.not_found", ctx.Tuple)
 	}
 
 	// Start the impl.
 	result, err := f()
 	if err != nil {
-		return nil, fmt.Errorf(
-			"app failed to start properly: %s", err)
+		return nil, i18n.Errorf("core.app.start_err", err)
 	}
 	return result, nil
 }
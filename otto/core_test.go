@@ -0,0 +1,848 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/ui"
+	"github.com/hashicorp/terraform/dag"
+	"github.com/kuuyee/otto-learn/app"
+	"github.com/kuuyee/otto-learn/appfile"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/kuuyee/otto-learn/infrastructure"
+)
+
+func TestCoreDev(t *testing.T) {
+	mock := new(app.Mock)
+	core := testCore(t, mock)
+
+	// 创建开发环境
+	if err := core.Dev("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !mock.DevCalled {
+		t.Fatal("dev should be called")
+	}
+	if mock.DevContext.Action != "" {
+		t.Fatalf("bad: %#v", mock.DevContext.Action)
+	}
+	testDevState(t, core, DevStateReady)
+
+	// 停止
+	if err := core.Dev("halt", []string{"foo"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mock.DevContext.Action != "halt" {
+		t.Fatalf("bad: %#v", mock.DevContext.Action)
+	}
+	if len(mock.DevContext.ActionArgs) != 1 || mock.DevContext.ActionArgs[0] != "foo" {
+		t.Fatalf("bad: %#v", mock.DevContext.ActionArgs)
+	}
+	testDevState(t, core, DevStateHalted)
+
+	// "up"是默认动作的别名
+	if err := core.Dev("up", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mock.DevContext.Action != "" {
+		t.Fatalf("bad: %#v", mock.DevContext.Action)
+	}
+	testDevState(t, core, DevStateReady)
+
+	// address不改变状态
+	if err := core.Dev("address", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testDevState(t, core, DevStateReady)
+
+	// 销毁
+	if err := core.Dev("destroy", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testDevState(t, core, DevStateNone)
+}
+
+func TestCoreDev_notCreated(t *testing.T) {
+	mock := new(app.Mock)
+	core := testCore(t, mock)
+
+	err := core.Dev("ssh", nil)
+	if err == nil || !strings.Contains(err.Error(), "otto dev") {
+		t.Fatalf("bad: %v", err)
+	}
+	if mock.DevCalled {
+		t.Fatal("dev should not be called")
+	}
+}
+
+func TestCoreDev_notCompiled(t *testing.T) {
+	mock := new(app.Mock)
+	core := testCore(t, mock)
+	if err := os.RemoveAll(core.compileDir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := core.Dev("", nil)
+	if err == nil || !strings.Contains(err.Error(), "otto compile") {
+		t.Fatalf("bad: %v", err)
+	}
+	if mock.DevCalled {
+		t.Fatal("dev should not be called")
+	}
+}
+
+func TestCoreDev_error(t *testing.T) {
+	mock := &app.Mock{DevErr: os.ErrInvalid}
+	core := testCore(t, mock)
+
+	if err := core.Dev("", nil); err != os.ErrInvalid {
+		t.Fatalf("bad: %v", err)
+	}
+	testDevState(t, core, DevStateNone)
+}
+
+func TestCoreInfra(t *testing.T) {
+	infra := &infrastructure.Mock{CredsResult: map[string]string{"foo": "bar"}}
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.CredsCalled || !infra.VerifyCredsCalled {
+		t.Fatal("creds should be requested and verified")
+	}
+	if !infra.ExecuteCalled {
+		t.Fatal("execute should be called")
+	}
+	if infra.ExecuteContext.InfraCreds["foo"] != "bar" {
+		t.Fatalf("bad: %#v", infra.ExecuteContext.InfraCreds)
+	}
+}
+
+func TestCoreInfra_cachedCreds(t *testing.T) {
+	infra := &infrastructure.Mock{CredsResult: map[string]string{"foo": "bar"}}
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// 第二次使用加密保存的认证，不再询问认证和密码
+	*infra = infrastructure.Mock{}
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infra.CredsCalled {
+		t.Fatal("creds should not be requested")
+	}
+	if !infra.VerifyCredsCalled {
+		t.Fatal("cached creds should be verified")
+	}
+	if infra.ExecuteContext.InfraCreds["foo"] != "bar" {
+		t.Fatalf("bad: %#v", infra.ExecuteContext.InfraCreds)
+	}
+	if n := core.ui.(*testUi).InputCounts["creds_password"]; n != 1 {
+		t.Fatalf("password asked %d times", n)
+	}
+
+	// 清除之后重新询问
+	if err := core.ClearCreds(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	infra.CredsResult = map[string]string{"foo": "baz"}
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.CredsCalled {
+		t.Fatal("creds should be requested")
+	}
+	if infra.ExecuteContext.InfraCreds["foo"] != "baz" {
+		t.Fatalf("bad: %#v", infra.ExecuteContext.InfraCreds)
+	}
+}
+
+func TestCoreInfra_invalidCreds(t *testing.T) {
+	infra := &infrastructure.Mock{
+		CredsResult:    map[string]string{"foo": "bar"},
+		VerifyCredsErr: os.ErrPermission,
+	}
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+
+	err := core.Infra("", nil)
+	if err == nil || !strings.Contains(err.Error(), os.ErrPermission.Error()) {
+		t.Fatalf("bad: %v", err)
+	}
+	if infra.ExecuteCalled {
+		t.Fatal("execute should not be called")
+	}
+
+	// 验证失败的认证不保存，下次重新询问
+	*infra = infrastructure.Mock{CredsResult: map[string]string{"foo": "baz"}}
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.CredsCalled {
+		t.Fatal("creds should be requested")
+	}
+	if infra.ExecuteContext.InfraCreds["foo"] != "baz" {
+		t.Fatalf("bad: %#v", infra.ExecuteContext.InfraCreds)
+	}
+}
+
+func TestCoreInfra_emptyCredsPassword(t *testing.T) {
+	infra := &infrastructure.Mock{CredsResult: map[string]string{"foo": "bar"}}
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+
+	// 空的密码直接报错，不会一直重新询问
+	ui := core.ui.(*testUi)
+	ui.Inputs["creds_password"] = ""
+	err := core.Infra("", nil)
+	if err == nil || err.Error() != i18n.T("creds.pass_empty") {
+		t.Fatalf("bad: %v", err)
+	}
+	if n := ui.InputCounts["creds_password"]; n != 1 {
+		t.Fatalf("password asked %d times", n)
+	}
+	if infra.ExecuteCalled {
+		t.Fatal("execute should not be called")
+	}
+}
+
+func TestCoreInfra_wrongCredsPassword(t *testing.T) {
+	infra := &infrastructure.Mock{CredsResult: map[string]string{"foo": "bar"}}
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// 模拟下一次运行输入了错误的密码
+	*infra = infrastructure.Mock{}
+	core.credsPassword = ""
+	core.ui.(*testUi).Inputs["creds_password"] = "wrong"
+	if err := core.Infra("", nil); err == nil {
+		t.Fatal("should error")
+	}
+	if infra.CredsCalled || infra.ExecuteCalled {
+		t.Fatal("creds and execute should not be called")
+	}
+	if core.credsPassword != "" {
+		t.Fatal("wrong password should not be kept")
+	}
+
+	// 输入正确的密码之后还能读取保存的认证
+	core.ui.(*testUi).Inputs["creds_password"] = "password"
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infra.ExecuteContext.InfraCreds["foo"] != "bar" {
+		t.Fatalf("bad: %#v", infra.ExecuteContext.InfraCreds)
+	}
+}
+
+func TestCoreRotateCreds(t *testing.T) {
+	infra := &infrastructure.Mock{CredsResult: map[string]string{"foo": "bar"}}
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+	ui := core.ui.(*testUi)
+
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// 验证失败的新认证不替换保存的认证
+	*infra = infrastructure.Mock{
+		CredsResult:    map[string]string{"foo": "bad"},
+		VerifyCredsErr: os.ErrPermission,
+	}
+	if err := core.RotateCreds(); err == nil {
+		t.Fatal("should error")
+	}
+	*infra = infrastructure.Mock{}
+	core.credsPassword = ""
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infra.ExecuteContext.InfraCreds["foo"] != "bar" {
+		t.Fatalf("bad: %#v", infra.ExecuteContext.InfraCreds)
+	}
+
+	// 轮换之后用新的密码加密保存新的认证
+	*infra = infrastructure.Mock{CredsResult: map[string]string{"foo": "baz"}}
+	ui.Inputs["creds_password"] = "new-password"
+	if err := core.RotateCreds(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.CredsCalled || !infra.VerifyCredsCalled {
+		t.Fatal("creds should be requested and verified")
+	}
+
+	*infra = infrastructure.Mock{}
+	core.credsPassword = ""
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infra.CredsCalled {
+		t.Fatal("creds should not be requested")
+	}
+	if infra.ExecuteContext.InfraCreds["foo"] != "baz" {
+		t.Fatalf("bad: %#v", infra.ExecuteContext.InfraCreds)
+	}
+
+	// 旧的密码不能再解密
+	core.credsPassword = ""
+	ui.Inputs["creds_password"] = "password"
+	if err := core.Infra("", nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCoreInfra_destroyDeployed(t *testing.T) {
+	infra := new(infrastructure.Mock)
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+
+	// 记录一个成功的部署
+	err := core.dir.PutDeploy(&directory.Deploy{
+		Lookup: directory.Lookup{
+			AppID:       "foo",
+			Infra:       "aws",
+			InfraFlavor: "simple",
+		},
+		State: directory.DeployStateSuccess,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = core.Infra("destroy", nil)
+	if err == nil || !strings.Contains(err.Error(), "otto deploy destroy") {
+		t.Fatalf("bad: %v", err)
+	}
+	if infra.ExecuteCalled {
+		t.Fatal("execute should not be called")
+	}
+}
+
+func TestCoreInfra_unknownFlavor(t *testing.T) {
+	infra := &infrastructure.Mock{FlavorsResult: []string{"simple", "vpc-public-private"}}
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+	core.appfile.Infrastructure[0].Flavor = "vpc-privat"
+
+	err := core.Infra("", nil)
+	if err == nil || !strings.Contains(err.Error(), "simple, vpc-public-private") {
+		t.Fatalf("bad: %v", err)
+	}
+	if infra.ExecuteCalled {
+		t.Fatal("execute should not be called")
+	}
+
+	// 支持的flavor可以执行
+	core.appfile.Infrastructure[0].Flavor = "simple"
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestCoreInfra_unknownVariable(t *testing.T) {
+	infra := &infrastructure.Mock{FlavorVariablesResult: []string{"aws_region", "vpc_cidr"}}
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+	core.appfile.Infrastructure[0].Variables = map[string]string{
+		"aws_regoin": "eu-west-1",
+	}
+
+	err := core.Infra("", nil)
+	if err == nil || !strings.Contains(err.Error(), "aws_regoin") {
+		t.Fatalf("bad: %v", err)
+	}
+	if infra.ExecuteCalled {
+		t.Fatal("execute should not be called")
+	}
+
+	// 声明了的变量传递给infrastructure
+	core.appfile.Infrastructure[0].Variables = map[string]string{
+		"aws_region": "eu-west-1",
+	}
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v := infra.ExecuteContext.Infra.Variables["aws_region"]; v != "eu-west-1" {
+		t.Fatalf("bad: %q", v)
+	}
+}
+
+func TestCoreInfra_terraformVersion(t *testing.T) {
+	infra := new(infrastructure.Mock)
+	core := testCore(t, new(app.Mock))
+	core.infras["aws"] = testInfraFactory(infra)
+	core.terraformVersion = "0.6.3"
+	core.terraformMirror = "/mirror"
+
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v := infra.ExecuteContext.TerraformVersion; v != "0.6.3" {
+		t.Fatalf("bad: %q", v)
+	}
+	if m := infra.ExecuteContext.TerraformMirror; m != "/mirror" {
+		t.Fatalf("bad: %q", m)
+	}
+
+	// Appfile中固定的版本优先
+	core.appfile.Infrastructure[0].TerraformVersion = "0.6.16"
+	if err := core.Infra("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v := infra.ExecuteContext.TerraformVersion; v != "0.6.16" {
+		t.Fatalf("bad: %q", v)
+	}
+}
+
+func TestCoreConfigFlavors(t *testing.T) {
+	config := &CoreConfig{
+		Infrastructures: map[string]infrastructure.Factory{
+			"aws": testInfraFactory(&infrastructure.Mock{FlavorsResult: []string{"simple"}}),
+		},
+	}
+
+	if f := config.Flavors("aws"); len(f) != 1 || f[0] != "simple" {
+		t.Fatalf("bad: %#v", f)
+	}
+	if f := config.Flavors("unknown"); f != nil {
+		t.Fatalf("bad: %#v", f)
+	}
+}
+
+func TestCoreConfigFlavorVariables(t *testing.T) {
+	config := &CoreConfig{
+		Infrastructures: map[string]infrastructure.Factory{
+			"aws": testInfraFactory(&infrastructure.Mock{
+				FlavorVariablesResult: []string{"aws_region"},
+			}),
+		},
+	}
+
+	if v := config.FlavorVariables("aws", "simple"); len(v) != 1 || v[0] != "aws_region" {
+		t.Fatalf("bad: %#v", v)
+	}
+	if v := config.FlavorVariables("unknown", "simple"); v != nil {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestCoreBuild(t *testing.T) {
+	infra := &infrastructure.Mock{CredsResult: map[string]string{"foo": "bar"}}
+	mock := new(app.Mock)
+	core := testCore(t, mock)
+	core.infras["aws"] = testInfraFactory(infra)
+
+	if err := core.Build(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.CredsCalled || !infra.VerifyCredsCalled {
+		t.Fatal("creds should be requested and verified")
+	}
+	if !mock.BuildCalled {
+		t.Fatal("build should be called")
+	}
+	if mock.BuildContext.Application.Name != "foo" {
+		t.Fatalf("bad: %#v", mock.BuildContext.Application)
+	}
+	if mock.BuildContext.InfraCreds["foo"] != "bar" {
+		t.Fatalf("bad: %#v", mock.BuildContext.InfraCreds)
+	}
+	if b := testBuildRecord(t, core); b == nil || b.State != directory.BuildStateSuccess {
+		t.Fatalf("bad: %#v", b)
+	}
+
+	// 构建失败也记录下来
+	mock.BuildErr = os.ErrInvalid
+	if err := core.Build(); err != os.ErrInvalid {
+		t.Fatalf("bad: %v", err)
+	}
+	if b := testBuildRecord(t, core); b == nil || b.State != directory.BuildStateFail {
+		t.Fatalf("bad: %#v", b)
+	}
+}
+
+func TestCoreDeploy(t *testing.T) {
+	infra := &infrastructure.Mock{CredsResult: map[string]string{"foo": "bar"}}
+	mock := new(app.Mock)
+	core := testCore(t, mock)
+	core.infras["aws"] = testInfraFactory(infra)
+	testInfraReady(t, core)
+
+	// 应用部署的时候部署记录已经存在，状态是新的
+	observer := &testDeployApp{Mock: mock, Core: core}
+	core.apps[app.Tuple{App: "test", Infra: "aws", InfraFlavor: "simple"}] =
+		func() (app.App, error) { return observer, nil }
+
+	if err := core.Deploy("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.CredsCalled || !infra.VerifyCredsCalled {
+		t.Fatal("creds should be requested and verified")
+	}
+	if !mock.DeployCalled {
+		t.Fatal("deploy should be called")
+	}
+	if mock.DeployContext.InfraCreds["foo"] != "bar" {
+		t.Fatalf("bad: %#v", mock.DeployContext.InfraCreds)
+	}
+	if observer.Record == nil || observer.Record.State != directory.DeployStateNew {
+		t.Fatalf("bad: %#v", observer.Record)
+	}
+	if d := testDeployRecord(t, core); !d.IsDeployed() {
+		t.Fatalf("bad: %#v", d)
+	}
+
+	// 销毁之后部署记录回到新的状态
+	if err := core.Deploy("destroy", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mock.DeployContext.Action != "destroy" {
+		t.Fatalf("bad: %#v", mock.DeployContext.Action)
+	}
+	if d := testDeployRecord(t, core); d.State != directory.DeployStateNew {
+		t.Fatalf("bad: %#v", d)
+	}
+}
+
+func TestCoreDeploy_failed(t *testing.T) {
+	mock := &app.Mock{DeployErr: os.ErrInvalid}
+	core := testCore(t, mock)
+	core.infras["aws"] = testInfraFactory(new(infrastructure.Mock))
+	testInfraReady(t, core)
+
+	if err := core.Deploy("", nil); err != os.ErrInvalid {
+		t.Fatalf("bad: %v", err)
+	}
+	if d := testDeployRecord(t, core); !d.IsFailed() {
+		t.Fatalf("bad: %#v", d)
+	}
+}
+
+func TestCoreDeploy_infraNotReady(t *testing.T) {
+	cases := map[string]struct {
+		State directory.InfraState
+		Err   string
+	}{
+		"not created": {0, "core.infra.not_ready"},
+		"partial":     {directory.InfraStatePartial, "core.infra.partial"},
+	}
+
+	for name, tc := range cases {
+		infra := new(infrastructure.Mock)
+		mock := new(app.Mock)
+		core := testCore(t, mock)
+		core.infras["aws"] = testInfraFactory(infra)
+		if tc.State != 0 {
+			err := core.dir.PutInfra(&directory.Infra{
+				Lookup: directory.Lookup{Infra: "aws"},
+				State:  tc.State,
+			})
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+
+		err := core.Deploy("", nil)
+		if err == nil || err.Error() != i18n.T(tc.Err) {
+			t.Fatalf("%s: bad: %v", name, err)
+		}
+		if infra.CredsCalled || mock.DeployCalled {
+			t.Fatalf("%s: creds and deploy should not be called", name)
+		}
+		if d := testDeployRecord(t, core); d != nil {
+			t.Fatalf("%s: bad: %#v", name, d)
+		}
+	}
+}
+
+func TestCoreDeploy_info(t *testing.T) {
+	infra := new(infrastructure.Mock)
+	mock := new(app.Mock)
+	core := testCore(t, mock)
+	core.infras["aws"] = testInfraFactory(infra)
+	testInfraReady(t, core)
+
+	// info不需要认证，也不改变部署状态
+	if err := core.Deploy("info", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infra.CredsCalled || infra.VerifyCredsCalled {
+		t.Fatal("creds should not be requested")
+	}
+	if n := core.ui.(*testUi).InputCounts["creds_password"]; n != 0 {
+		t.Fatalf("password asked %d times", n)
+	}
+	if !mock.DeployCalled || mock.DeployContext.Action != "info" {
+		t.Fatalf("bad: %#v", mock.DeployContext)
+	}
+	if d := testDeployRecord(t, core); d != nil {
+		t.Fatalf("bad: %#v", d)
+	}
+}
+
+func TestCoreDeploy_infraOutputs(t *testing.T) {
+	mock := new(app.Mock)
+	core := testCore(t, mock)
+	core.infras["aws"] = testInfraFactory(new(infrastructure.Mock))
+
+	// infrastructure只创建了一部分
+	record := &directory.Infra{
+		Lookup: directory.Lookup{Infra: "aws"},
+		State:  directory.InfraStatePartial,
+	}
+	if err := core.dir.PutInfra(record); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err := core.Deploy("", nil)
+	if err == nil || err.Error() != i18n.T("core.infra.partial") {
+		t.Fatalf("bad: %v", err)
+	}
+	if mock.DeployCalled {
+		t.Fatal("deploy should not be called")
+	}
+
+	// 创建好之后应用可以读取输出
+	record.State = directory.InfraStateReady
+	record.SetOutputs(directory.Outputs{
+		"subnet_ids": directory.ListOutput([]string{"subnet-1", "subnet-2"}),
+	})
+	if err := core.dir.PutInfra(record); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.Deploy("", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mock.DeployContext.Infra == nil {
+		t.Fatal("infra should be set")
+	}
+	outputs, err := mock.DeployContext.InfraOutputs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if l := outputs["subnet_ids"].List(); len(l) != 2 {
+		t.Fatalf("bad: %#v", outputs)
+	}
+}
+
+func TestCoreStatus(t *testing.T) {
+	core := testCore(t, new(app.Mock))
+
+	status, err := core.Status()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !status.Compiled || status.Stale {
+		t.Fatalf("bad: %#v", status)
+	}
+	if status.Dev != StatusNotCreated || status.Infra != StatusNotCreated {
+		t.Fatalf("bad: %#v", status)
+	}
+	if status.Build != nil {
+		t.Fatalf("bad: %#v", status.Build)
+	}
+	if len(status.Deploys) != 1 || status.Deploys[0].State != StatusNotDeployed {
+		t.Fatalf("bad: %#v", status.Deploys)
+	}
+
+	// 修改Appfile，infrastructure创建了一部分
+	if err := ioutil.WriteFile(core.appfile.Path, []byte("foo"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = core.dir.PutInfra(&directory.Infra{
+		Lookup: directory.Lookup{Infra: "aws"},
+		State:  directory.InfraStatePartial,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	status, err = core.Status()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !status.Stale {
+		t.Fatal("should be stale")
+	}
+	if status.Infra != StatusPartial {
+		t.Fatalf("bad: %#v", status.Infra)
+	}
+
+	// otto infra check 发现了漂移
+	err = core.dir.PutInfra(&directory.Infra{
+		Lookup: directory.Lookup{Infra: "aws"},
+		State:  directory.InfraStateDrifted,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	status, err = core.Status()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if status.Infra != StatusDrifted {
+		t.Fatalf("bad: %#v", status.Infra)
+	}
+}
+
+// testCore 返回一个只有一个应用的Core，应用的实现是给定的mock
+func testCore(t *testing.T, mock *app.Mock) *Core {
+	dir := t.TempDir()
+
+	f := &appfile.File{
+		ID:   "foo",
+		Path: filepath.Join(dir, "Appfile"),
+		Application: &appfile.Application{
+			Name: "foo",
+			Type: "test",
+		},
+		Project: &appfile.Project{
+			Name:           "foo",
+			Infrastructure: "aws",
+		},
+		Infrastructure: []*appfile.Infrastructure{
+			&appfile.Infrastructure{
+				Name:   "aws",
+				Type:   "aws",
+				Flavor: "simple",
+			},
+		},
+	}
+
+	graph := new(dag.AcyclicGraph)
+	graph.Add(&appfile.CompiledGraphVertex{File: f, NameValue: "foo"})
+
+	compileDir := filepath.Join(dir, "compiled")
+	if err := os.MkdirAll(compileDir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	core, err := NewCore(&CoreConfig{
+		DataDir:    filepath.Join(dir, "data"),
+		LocalDir:   filepath.Join(dir, "local"),
+		CompileDir: compileDir,
+		Appfile:    &appfile.Compiled{File: f, Graph: graph},
+		Directory:  &directory.BoltBackend{Dir: filepath.Join(dir, "directory")},
+		Apps: map[app.Tuple]app.Factory{
+			app.Tuple{App: "test", Infra: "aws", InfraFlavor: "simple"}: func() (app.App, error) {
+				return mock, nil
+			},
+		},
+		Infrastructures: map[string]infrastructure.Factory{},
+		Ui: &testUi{
+			Inputs: map[string]string{"creds_password": "password"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return core
+}
+
+func testInfraFactory(infra infrastructure.Infrastructure) infrastructure.Factory {
+	return func() (infrastructure.Infrastructure, error) {
+		return infra, nil
+	}
+}
+
+func testDevState(t *testing.T, c *Core, expected DevState) {
+	state, err := readDevState(c.localDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if state != expected {
+		t.Fatalf("bad state: %q, expected %q", state, expected)
+	}
+}
+
+// testInfraReady 记录一个已经创建好的infrastructure
+func testInfraReady(t *testing.T, c *Core) {
+	err := c.dir.PutInfra(&directory.Infra{
+		Lookup: directory.Lookup{Infra: "aws"},
+		State:  directory.InfraStateReady,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// testAppLookup 是testCore中root应用构建和部署记录的Lookup
+var testAppLookup = directory.Lookup{
+	AppID:       "foo",
+	Infra:       "aws",
+	InfraFlavor: "simple",
+}
+
+// testDeployRecord 返回目录中root应用的部署记录
+func testDeployRecord(t *testing.T, c *Core) *directory.Deploy {
+	d, err := c.dir.GetDeploy(&directory.Deploy{Lookup: testAppLookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return d
+}
+
+// testBuildRecord 返回目录中root应用的构建记录
+func testBuildRecord(t *testing.T, c *Core) *directory.Build {
+	b, err := c.dir.GetBuild(&directory.Build{Lookup: testAppLookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return b
+}
+
+// testDeployApp 在部署时记录目录中的部署记录，然后交给Mock处理
+type testDeployApp struct {
+	*app.Mock
+
+	Core   *Core
+	Record *directory.Deploy
+}
+
+func (a *testDeployApp) Deploy(ctx *app.Context) error {
+	d, err := a.Core.dir.GetDeploy(&directory.Deploy{Lookup: testAppLookup})
+	if err != nil {
+		return err
+	}
+
+	a.Record = d
+	return a.Mock.Deploy(ctx)
+}
+
+// testUi 是测试用的ui.Ui，丢弃所有输出。Input按照InputOpts.Id
+// 返回Inputs中的值，并记录每个Id被询问的次数
+type testUi struct {
+	Inputs      map[string]string
+	InputCounts map[string]int
+}
+
+func (u *testUi) Header(string)  {}
+func (u *testUi) Message(string) {}
+func (u *testUi) Raw(string)     {}
+
+func (u *testUi) Input(opts *ui.InputOpts) (string, error) {
+	if u.InputCounts == nil {
+		u.InputCounts = make(map[string]int)
+	}
+	u.InputCounts[opts.Id]++
+
+	return u.Inputs[opts.Id], nil
+}
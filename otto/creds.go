@@ -0,0 +1,201 @@
+package otto
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/otto/ui"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"github.com/kuuyee/otto-learn/infrastructure"
+)
+
+// creds 读取保存的加密认证，如果没有保存过，通过infrastructure
+// 实现向用户(或环境)查询认证，验证之后加密保存。无论哪种情况，在
+// 使用认证之前都会先验证认证
+func (c *Core) creds(
+	infra infrastructure.Infrastructure,
+	infraCtx *infrastructure.Context) error {
+	infraCtx.Ui.Header(i18n.T("creds.header"))
+
+	path := c.credsPath(infraCtx)
+	_, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		infraCtx.Ui.Message(i18n.T("creds.cached"))
+
+		creds, err := c.credsRead(infraCtx, path)
+		if err != nil {
+			return err
+		}
+
+		// 给infrastructure一个机会验证认证是否OK，以便尽早失败
+		infraCtx.InfraCreds = creds
+		if err := infra.VerifyCreds(infraCtx); err != nil {
+			return i18n.Errorf("creds.verify_err_rotate", err)
+		}
+
+		return nil
+	}
+
+	infraCtx.Ui.Message(i18n.T("creds.not_found"))
+	return c.credsQuery(infra, infraCtx, path)
+}
+
+// ClearCreds 删除当前infrastructure保存的加密认证。下次需要认证
+// 的时候会重新询问
+func (c *Core) ClearCreds() error {
+	_, infraCtx, err := c.infra()
+	if err != nil {
+		return err
+	}
+
+	path := c.credsPath(infraCtx)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return i18n.Errorf("creds.clear_err", err)
+	}
+
+	c.credsLock.Lock()
+	c.credsPassword = ""
+	c.credsLock.Unlock()
+
+	c.ui.Header(i18n.T("creds.cleared", infraCtx.Infra.Name))
+	return nil
+}
+
+// RotateCreds 重新询问当前infrastructure的认证和加密密码，验证
+// 之后替换保存的加密认证
+func (c *Core) RotateCreds() error {
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return err
+	}
+
+	infraCtx.Ui.Header(i18n.T("creds.rotate_header"))
+	infraCtx.Ui.Message(i18n.T("creds.rotate_warning"))
+
+	// 先查询但不保存，验证通过之后才替换保存的认证
+	path := c.credsPath(infraCtx)
+	creds, err := infra.Creds(infraCtx)
+	if err != nil {
+		return err
+	}
+
+	infraCtx.InfraCreds = creds
+	if err := infra.VerifyCreds(infraCtx); err != nil {
+		return i18n.Errorf("creds.verify_err", err)
+	}
+
+	if err := c.credsWrite(infraCtx, path, creds, true); err != nil {
+		return err
+	}
+
+	c.ui.Header(i18n.T("creds.rotated", infraCtx.Infra.Name))
+	return nil
+}
+
+// credsPath 返回infrastructure加密认证的保存路径。认证保存在
+// 全局数据目录中，按照infrastructure名字区分
+func (c *Core) credsPath(infraCtx *infrastructure.Context) string {
+	return filepath.Join(c.dataDir, "creds", infraCtx.Infra.Name)
+}
+
+// credsRead 询问密码并解密保存的认证
+func (c *Core) credsRead(
+	infraCtx *infrastructure.Context, path string) (map[string]string, error) {
+	password, err := c.credsPass(infraCtx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds map[string]string
+	plaintext, err := cryptRead(path, password)
+	if err == nil {
+		err = json.Unmarshal(plaintext, &creds)
+	}
+	if err != nil {
+		// 密码可能是错的，不再使用
+		c.credsLock.Lock()
+		c.credsPassword = ""
+		c.credsLock.Unlock()
+
+		return nil, i18n.Errorf("creds.read_err", err)
+	}
+
+	return creds, nil
+}
+
+// credsQuery 通过infrastructure实现查询认证，验证通过之后才加密保存，
+// 这样错误的认证不会被保存下来
+func (c *Core) credsQuery(
+	infra infrastructure.Infrastructure,
+	infraCtx *infrastructure.Context,
+	path string) error {
+	creds, err := infra.Creds(infraCtx)
+	if err != nil {
+		return err
+	}
+
+	infraCtx.InfraCreds = creds
+	if err := infra.VerifyCreds(infraCtx); err != nil {
+		return i18n.Errorf("creds.verify_err", err)
+	}
+
+	return c.credsWrite(infraCtx, path, creds, false)
+}
+
+// credsWrite 询问加密密码，加密并保存认证
+func (c *Core) credsWrite(
+	infraCtx *infrastructure.Context,
+	path string, creds map[string]string, newPass bool) error {
+	password, err := c.credsPass(infraCtx, newPass)
+	if err != nil {
+		return err
+	}
+
+	// creds是map[string]string，编码不会失败
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := cryptWrite(path, password, plaintext); err != nil {
+		return i18n.Errorf("creds.write_err", err)
+	}
+
+	return nil
+}
+
+// credsPass 返回加密认证的密码。每次运行只询问一次，之后使用
+// 已经输入的密码。force为true时总是询问新的密码
+func (c *Core) credsPass(infraCtx *infrastructure.Context, force bool) (string, error) {
+	c.credsLock.Lock()
+	defer c.credsLock.Unlock()
+
+	if c.credsPassword != "" && !force {
+		return c.credsPassword, nil
+	}
+
+	password, err := infraCtx.Ui.Input(&ui.InputOpts{
+		Id:          "creds_password",
+		Query:       i18n.T("creds.pass_query"),
+		Description: i18n.T("creds.pass_desc"),
+		Hide:        true,
+		EnvVars:     []string{"OTTO_CREDS_PASSWORD"},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 空的密码不能用来加密。不要重新询问，密码来自-var或者环境变量
+	// 的时候每次得到的都是空的，会一直循环下去
+	if password == "" {
+		return "", i18n.Errorf("creds.pass_empty")
+	}
+
+	c.credsPassword = password
+	return password, nil
+}
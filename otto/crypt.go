@@ -0,0 +1,108 @@
+package otto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kuuyee/otto-learn/helper/i18n"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// cryptSaltLen 是KDF使用的salt长度
+	cryptSaltLen = 32
+
+	// scrypt的参数，cryptKeyLen是生成的AES-256密钥长度
+	cryptScryptN = 16384
+	cryptScryptR = 8
+	cryptScryptP = 1
+	cryptKeyLen  = 32
+)
+
+// cryptWrite 用password加密data并写入path
+//
+// 密钥通过scrypt从password生成，数据使用AES-GCM加密，所以
+// 数据被修改或者密码错误时都可以检查出来。文件格式是:
+// salt + nonce + 密文
+func cryptWrite(path string, password string, data []byte) error {
+	salt := make([]byte, cryptSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+
+	gcm, err := cryptCipher(password, salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	out := make([]byte, 0, len(salt)+len(nonce)+len(data)+gcm.Overhead())
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	out = gcm.Seal(out, nonce, data, nil)
+
+	// 只有当前用户可以读写
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, out, 0600)
+}
+
+// cryptRead 读取path中用cryptWrite加密的数据并用password解密
+func cryptRead(path string, password string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < cryptSaltLen {
+		return nil, i18n.Errorf("creds.crypt_format_err")
+	}
+	salt := data[:cryptSaltLen]
+	data = data[cryptSaltLen:]
+
+	gcm, err := cryptCipher(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, i18n.Errorf("creds.crypt_format_err")
+	}
+	nonce := data[:gcm.NonceSize()]
+	data = data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, i18n.Errorf("creds.crypt_decrypt_err")
+	}
+
+	return plaintext, nil
+}
+
+// cryptCipher 从password和salt生成密钥，返回AES-GCM
+func cryptCipher(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(
+		[]byte(password), salt,
+		cryptScryptN, cryptScryptR, cryptScryptP, cryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
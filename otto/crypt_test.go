@@ -0,0 +1,25 @@
+package otto
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCrypt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds")
+	if err := cryptWrite(path, "password", []byte("foo")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := cryptRead(path, "password")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "foo" {
+		t.Fatalf("bad: %s", data)
+	}
+
+	if _, err := cryptRead(path, "wrong"); err == nil {
+		t.Fatal("should error with the wrong password")
+	}
+}
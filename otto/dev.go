@@ -0,0 +1,82 @@
+package otto
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DevState 是开发环境的状态
+type DevState string
+
+const (
+	DevStateNone   DevState = ""       // 没有创建
+	DevStateReady  DevState = "ready"  // 已经创建并运行
+	DevStateHalted DevState = "halted" // 已经创建但是停止了
+)
+
+// devStateFile 是开发环境状态在LocalDir中的文件名
+const devStateFile = "dev.json"
+
+// devStatus 是保存在LocalDir中的开发环境状态
+type devStatus struct {
+	State   DevState  `json:"state"`
+	Updated time.Time `json:"updated"`
+}
+
+// readDevState 从LocalDir读取开发环境状态，如果没有
+// 记录返回DevStateNone
+func readDevState(dir string) (DevState, error) {
+	f, err := os.Open(filepath.Join(dir, devStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DevStateNone, nil
+		}
+
+		return DevStateNone, err
+	}
+	defer f.Close()
+
+	var status devStatus
+	if err := json.NewDecoder(f).Decode(&status); err != nil {
+		return DevStateNone, err
+	}
+
+	return status.State, nil
+}
+
+// writeDevState 把开发环境状态写入LocalDir。DevStateNone会删除
+// 状态文件
+func writeDevState(dir string, state DevState) error {
+	path := filepath.Join(dir, devStateFile)
+	if state == DevStateNone {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// 格式化JSON数据，以便容易检查
+	data, err := json.MarshalIndent(&devStatus{
+		State:   state,
+		Updated: time.Now().UTC(),
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
@@ -0,0 +1,164 @@
+package otto
+
+import (
+	"time"
+
+	"github.com/kuuyee/otto-learn/appfile"
+	"github.com/kuuyee/otto-learn/directory"
+	"github.com/kuuyee/otto-learn/helper/i18n"
+)
+
+// Status 是整个项目的状态汇总，由Core.Status返回
+type Status struct {
+	// Compiled 表示Appfile是否已经编译。Stale 表示编译之后
+	// Appfile是否改变过
+	Compiled bool `json:"compiled"`
+	Stale    bool `json:"stale"`
+
+	// 应用、项目和infrastructure的信息
+	App         string `json:"app,omitempty"`
+	AppType     string `json:"app_type,omitempty"`
+	Project     string `json:"project,omitempty"`
+	InfraType   string `json:"infra_type,omitempty"`
+	InfraFlavor string `json:"infra_flavor,omitempty"`
+
+	// Dev 是开发环境的状态，Infra 是infrastructure的状态
+	Dev   string `json:"dev,omitempty"`
+	Infra string `json:"infra,omitempty"`
+
+	// Build 是最后一次构建，如果没有构建过是nil
+	Build *BuildStatus `json:"build"`
+
+	// Deploys 是Appfile中每个应用的部署状态
+	Deploys []*DeployStatus `json:"deploys"`
+}
+
+// BuildStatus 是最后一次构建的状态
+type BuildStatus struct {
+	State string    `json:"state"`
+	Time  time.Time `json:"time"`
+}
+
+// DeployStatus 是单个应用的部署状态
+type DeployStatus struct {
+	App   string `json:"app"`
+	State string `json:"state"`
+}
+
+// 状态的取值
+const (
+	StatusNotCreated  = "not_created"
+	StatusReady       = "ready"
+	StatusHalted      = "halted"
+	StatusPartial     = "partial"
+	StatusDrifted     = "drifted"
+	StatusInvalid     = "invalid"
+	StatusSuccess     = "success"
+	StatusFailed      = "failed"
+	StatusDeployed    = "deployed"
+	StatusNotDeployed = "not_deployed"
+)
+
+// Status 从目录服务和本地数据目录收集整个项目的状态
+func (c *Core) Status() (*Status, error) {
+	infraConfig := c.appfile.ActiveInfrastructure()
+	if infraConfig == nil {
+		return nil, i18n.Errorf(
+			"core.infra.not_found", c.appfile.Project.Infrastructure)
+	}
+
+	result := &Status{
+		Compiled:    true,
+		App:         c.appfile.Application.Name,
+		AppType:     c.appfile.Application.Type,
+		Project:     c.appfile.Project.Name,
+		InfraType:   infraConfig.Type,
+		InfraFlavor: infraConfig.Flavor,
+	}
+
+	// 比较Appfile的hash判断编译是否过期
+	hash, err := c.appfile.Hash()
+	if err != nil {
+		return nil, i18n.Errorf("core.hash_err", err)
+	}
+	result.Stale = hash != c.appfileCompiled.Hash
+
+	// 开发环境
+	devState, err := readDevState(c.localDir)
+	if err != nil {
+		return nil, i18n.Errorf("core.dev.state_read_err", err)
+	}
+	switch devState {
+	case DevStateReady:
+		result.Dev = StatusReady
+	case DevStateHalted:
+		result.Dev = StatusHalted
+	default:
+		result.Dev = StatusNotCreated
+	}
+
+	// Infrastructure
+	infra, err := c.dir.GetInfra(&directory.Infra{
+		Lookup: directory.Lookup{Infra: infraConfig.Name}})
+	if err != nil {
+		return nil, i18n.Errorf("core.infra.lookup_err", err)
+	}
+	switch {
+	case infra == nil:
+		result.Infra = StatusNotCreated
+	case infra.State == directory.InfraStateReady:
+		result.Infra = StatusReady
+	case infra.State == directory.InfraStatePartial:
+		result.Infra = StatusPartial
+	case infra.State == directory.InfraStateDrifted:
+		result.Infra = StatusDrifted
+	default:
+		result.Infra = StatusInvalid
+	}
+
+	// 最后一次构建
+	build, err := c.dir.GetBuild(&directory.Build{
+		Lookup: c.appLookup(c.appfile, infraConfig)})
+	if err != nil {
+		return nil, i18n.Errorf("core.build.lookup_err", err)
+	}
+	if build != nil {
+		result.Build = &BuildStatus{State: StatusFailed, Time: build.Time}
+		if build.IsSuccessful() {
+			result.Build.State = StatusSuccess
+		}
+	}
+
+	// 每个应用的部署
+	for _, raw := range c.appfileCompiled.Graph.Vertices() {
+		f := raw.(*appfile.CompiledGraphVertex).File
+		deploy, err := c.dir.GetDeploy(&directory.Deploy{
+			Lookup: c.appLookup(f, infraConfig)})
+		if err != nil {
+			return nil, i18n.Errorf("core.deploy.lookup_err", err)
+		}
+
+		state := StatusNotDeployed
+		if deploy.IsDeployed() {
+			state = StatusDeployed
+		} else if deploy.IsFailed() {
+			state = StatusFailed
+		}
+
+		result.Deploys = append(result.Deploys, &DeployStatus{
+			App:   f.Application.Name,
+			State: state,
+		})
+	}
+
+	return result, nil
+}
+
+// appLookup 返回应用在infrastructure上的目录查询信息
+func (c *Core) appLookup(f *appfile.File, infra *appfile.Infrastructure) directory.Lookup {
+	return directory.Lookup{
+		AppID:       f.ID,
+		Infra:       infra.Name,
+		InfraFlavor: infra.Flavor,
+	}
+}
@@ -35,20 +35,20 @@ func panicHandler(logF *os.File) panicwrap.HandlerFunc {
 		// 创建Log文件
 		f, err := os.Create("crash.log")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "创建log文件报错：", err)
+			fmt.Fprintf(os.Stderr, "创建log文件报错：%s\n", err)
 			return
 		}
 		defer f.Close()
 
 		// 这是偏移未知到Log文件头部
 		if _, err = logF.Seek(0, 0); err != nil {
-			fmt.Fprintf(os.Stderr, "设置偏移位报错：", err)
+			fmt.Fprintf(os.Stderr, "设置偏移位报错：%s\n", err)
 			return
 		}
 
 		// 复制内容到log文件，包括刚产生的panic
 		if _, err = io.Copy(f, logF); err != nil {
-			fmt.Fprintf(os.Stderr, "写入log文件报错：", err)
+			fmt.Fprintf(os.Stderr, "写入log文件报错：%s\n", err)
 			return
 		}
 
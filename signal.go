@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -11,10 +10,8 @@ import (
 // 如果不这样做，那么我们执行的像Terraform或Vagrant命令如果收不到ctrl-C
 // 命令就不会优雅的被清理
 func initSignalHandlers() {
-	fmt.Printf("[KuuYee_DEBUG]====> %s\n", "main.go/initSignalHandlers/14")
 	signalCh := make(chan os.Signal, 2)
 	signal.Notify(signalCh, os.Interrupt)
-	fmt.Printf("[KuuYee_DEBUG]====> %s\n", "main.go/initSignalHandlers/17")
 	go func() {
 		for {
 			<-signalCh